@@ -2,14 +2,20 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 	"github.com/goplus/yap"
 	"context"
 	"encoding/json"
 	"net/http"
 	"github.com/goplus/community/internal/core"
+	"github.com/goplus/community/internal/feed"
+	"github.com/goplus/community/internal/sitemap"
+	"github.com/goplus/community/markdown"
 	"github.com/goplus/community/translation"
 	"golang.org/x/text/language"
 	_ "github.com/joho/godotenv/autoload"
@@ -28,448 +34,1473 @@ type community struct {
 }
 //line cmd/gopcomm/community_yap.gox:31
 func (this *community) MainEntry() {
-//line cmd/gopcomm/community_yap.gox:31:1
+//line cmd/gopcomm/community_yap.gox:34:1
 	todo := context.TODO()
-//line cmd/gopcomm/community_yap.gox:32:1
+//line cmd/gopcomm/community_yap.gox:35:1
 	endpoint := os.Getenv("GOP_COMMUNITY_ENDPOINT")
-//line cmd/gopcomm/community_yap.gox:33:1
+//line cmd/gopcomm/community_yap.gox:36:1
 	domain := os.Getenv("GOP_COMMUNITY_DOMAIN")
-//line cmd/gopcomm/community_yap.gox:34:1
+//line cmd/gopcomm/community_yap.gox:37:1
 	xLog := xlog.New("")
-//line cmd/gopcomm/community_yap.gox:38:1
-	this.Static__0("/static")
 //line cmd/gopcomm/community_yap.gox:40:1
-	this.Get("/success", func(ctx *yap.Context) {
+	conf := &core.Config{}
 //line cmd/gopcomm/community_yap.gox:41:1
+	var err error
+//line cmd/gopcomm/community_yap.gox:42:1
+	this.community, err = core.New(todo, conf)
+//line cmd/gopcomm/community_yap.gox:43:1
+	if err != nil {
+//line cmd/gopcomm/community_yap.gox:44:1
+		xLog.Error("init community error:", err)
+//line cmd/gopcomm/community_yap.gox:45:1
+		os.Exit(1)
+	}
+//line cmd/gopcomm/community_yap.gox:47:1
+	this.trans = translation.New(os.Getenv("NIUTRANS_API_KEY"), "", "")
+//line cmd/gopcomm/community_yap.gox:51:1
+	this.Static__0("/static")
+//line cmd/gopcomm/community_yap.gox:53:1
+	this.Get("/success", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:54:1
 		ctx.Yap__1("2xx", map[string]interface {
 		}{})
 	})
-//line cmd/gopcomm/community_yap.gox:44:1
+//line cmd/gopcomm/community_yap.gox:57:1
 	this.Get("/error", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:45:1
+//line cmd/gopcomm/community_yap.gox:58:1
 		ctx.Yap__1("4xx", map[string]interface {
 		}{})
 	})
-//line cmd/gopcomm/community_yap.gox:48:1
+//line cmd/gopcomm/community_yap.gox:61:1
 	this.Get("/failed", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:49:1
+//line cmd/gopcomm/community_yap.gox:62:1
 		ctx.Yap__1("5xx", map[string]interface {
 		}{})
 	})
-//line cmd/gopcomm/community_yap.gox:52:1
+//line cmd/gopcomm/community_yap.gox:65:1
 	this.Get("/demo", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:53:1
+//line cmd/gopcomm/community_yap.gox:66:1
 		ctx.Yap__1("demo", map[string]interface {
 		}{})
 	})
-//line cmd/gopcomm/community_yap.gox:56:1
-	this.Get("/p/:id", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:59:1
-		// todo middleware
+//line cmd/gopcomm/community_yap.gox:69:1
+	this.Get("/p/:id", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:71:1
 		// Get User Info
-		var user *core.User
-//line cmd/gopcomm/community_yap.gox:60:1
-		token, err := core.GetToken(ctx)
-//line cmd/gopcomm/community_yap.gox:61:1
-		if err == nil {
-//line cmd/gopcomm/community_yap.gox:62:1
-			user, err = this.community.GetUser(token.Value)
-//line cmd/gopcomm/community_yap.gox:63:1
-			if err != nil {
-//line cmd/gopcomm/community_yap.gox:64:1
-				xLog.Error("get user error:", err)
-			}
-		}
-//line cmd/gopcomm/community_yap.gox:68:1
+		user := core.CurrentUser(ctx)
+//line cmd/gopcomm/community_yap.gox:72:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:74:1
 		id := ctx.Param("id")
-//line cmd/gopcomm/community_yap.gox:69:1
+//line cmd/gopcomm/community_yap.gox:75:1
 		article, _ := this.community.Article(todo, id)
-//line cmd/gopcomm/community_yap.gox:70:1
-		ctx.Yap__1("article", map[string]interface {
-		}{"User": user, "ID": id, "Title": article.Title, "Content": article.HtmlUrl, "Tags": article.Tags, "Cover": article.Cover, "Mtime": article.Mtime.Format(layoutUS), "Author": article.User})
-	})
+//line cmd/gopcomm/community_yap.gox:77:1
+		if !article.VisibleTo(uid) {
+//line cmd/gopcomm/community_yap.gox:78:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:79:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:82:1
+		// views are counted once per user, or per address for visitors
+		viewer := uid
 //line cmd/gopcomm/community_yap.gox:83:1
-	this.Get("/getArticle/:id", func(ctx *yap.Context) {
+		if viewer == "" {
 //line cmd/gopcomm/community_yap.gox:84:1
+			viewer, _, _ = net.SplitHostPort(ctx.Request.RemoteAddr)
+		}
+//line cmd/gopcomm/community_yap.gox:86:1
+		this.community.ViewArticle(id, viewer)
+//line cmd/gopcomm/community_yap.gox:87:1
+		liked, bookmarked, _ := this.community.Engagement(todo, uid, id)
+//line cmd/gopcomm/community_yap.gox:88:1
+		comments, _ := this.community.CountComments(todo, id)
+//line cmd/gopcomm/community_yap.gox:90:1
+		editable, _ := this.community.CanEditable(todo, uid, id)
+//line cmd/gopcomm/community_yap.gox:91:1
+		moderator := this.community.Can(todo, uid, core.ActionDeleteComment, "")
+//line cmd/gopcomm/community_yap.gox:92:1
+		ctx.Yap__1("article", map[string]interface {
+		}{"User": user, "Uid": uid, "ID": id, "Comments": comments, "Likes": article.Likes, "Bookmarks": article.Bookmarks, "Views": article.Views, "Liked": liked, "Bookmarked": bookmarked, "Editable": editable, "Hidden": article.Hidden, "Moderator": moderator, "Title": article.Title, "Content": article.HtmlUrl, "Tags": article.Tags, "Cover": article.Cover, "Mtime": article.Mtime.Format(layoutUS), "Author": article.User, "Meta": this.community.ArticleMeta(article, this.community.BaseURL(ctx.Request))})
+	}))
+//line cmd/gopcomm/community_yap.gox:116:1
+	this.Get("/getArticle/:id", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:117:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:118:1
 		id := ctx.Param("id")
-//line cmd/gopcomm/community_yap.gox:85:1
+//line cmd/gopcomm/community_yap.gox:119:1
 		article, _ := this.community.Article(todo, id)
-//line cmd/gopcomm/community_yap.gox:86:1
+//line cmd/gopcomm/community_yap.gox:120:1
+		if !article.VisibleTo(uid) {
+//line cmd/gopcomm/community_yap.gox:121:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 404, "err": "article not found"})
+//line cmd/gopcomm/community_yap.gox:125:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:127:1
 		ctx.Json__1(map[string]interface {
 		}{"code": 200, "data": article})
+	}))
+//line cmd/gopcomm/community_yap.gox:134:1
+	this.Get("/admin", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:135:1
+		ctx.Redirect("/admin/reports", http.StatusFound)
 	})
-//line cmd/gopcomm/community_yap.gox:92:1
-	this.Get("/user/:id", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:93:1
+//line cmd/gopcomm/community_yap.gox:138:1
+	this.Get("/admin/reports", this.community.RequirePermission(core.ActionModerate, func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:139:1
+		user := core.CurrentUser(ctx)
+//line cmd/gopcomm/community_yap.gox:140:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:141:1
+		status := ctx.Param("status")
+//line cmd/gopcomm/community_yap.gox:142:1
+		if status == "" {
+//line cmd/gopcomm/community_yap.gox:143:1
+			status = core.ReportOpen
+		}
+//line cmd/gopcomm/community_yap.gox:145:1
+		reports, next, err := this.community.Reports(todo, uid, status, ctx.Param("from"), limitConst)
+//line cmd/gopcomm/community_yap.gox:146:1
+		if err == core.ErrPermission {
+//line cmd/gopcomm/community_yap.gox:147:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:148:1
+			return
+		} else if err != nil && err != io.EOF {
+//line cmd/gopcomm/community_yap.gox:150:1
+			xLog.Error("moderation error:", err)
+//line cmd/gopcomm/community_yap.gox:151:1
+			ctx.Yap__1("5xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:152:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:154:1
+		open, _ := this.community.CountReports(todo, uid, core.ReportOpen)
+//line cmd/gopcomm/community_yap.gox:155:1
+		ctx.Yap__1("admin", map[string]interface {
+		}{"User": user, "Tab": "reports", "Open": open, "Next": next, "Status": status, "Reports": reports})
+	}))
+//line cmd/gopcomm/community_yap.gox:165:1
+	this.Get("/admin/audit", this.community.RequirePermission(core.ActionModerate, func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:166:1
+		user := core.CurrentUser(ctx)
+//line cmd/gopcomm/community_yap.gox:167:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:168:1
+		entries, next, err := this.community.AuditLog(todo, uid, ctx.Param("from"), limitConst)
+//line cmd/gopcomm/community_yap.gox:169:1
+		if err == core.ErrPermission {
+//line cmd/gopcomm/community_yap.gox:170:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:171:1
+			return
+		} else if err != nil && err != io.EOF {
+//line cmd/gopcomm/community_yap.gox:173:1
+			xLog.Error("moderation error:", err)
+//line cmd/gopcomm/community_yap.gox:174:1
+			ctx.Yap__1("5xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:175:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:177:1
+		open, _ := this.community.CountReports(todo, uid, core.ReportOpen)
+//line cmd/gopcomm/community_yap.gox:178:1
+		ctx.Yap__1("admin", map[string]interface {
+		}{"User": user, "Tab": "audit", "Open": open, "Next": next, "Entries": entries})
+	}))
+//line cmd/gopcomm/community_yap.gox:188:1
+	this.Post("/admin/moderate", this.community.RequirePermission(core.ActionModerate, func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:189:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:190:1
+		err := this.community.Moderate(todo, uid, ctx.Param("kind"), ctx.Param("id"), ctx.Param("action"), ctx.Param("note"))
+//line cmd/gopcomm/community_yap.gox:191:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:192:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:196:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:198:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": ctx.Param("action")})
+	}))
+//line cmd/gopcomm/community_yap.gox:205:1
+	this.Post("/admin/ban", this.community.RequirePermission(core.ActionBanUser, func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:206:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:207:1
+		err := this.community.Ban(todo, uid, ctx.Param("user"), ctx.Param("reason"))
+//line cmd/gopcomm/community_yap.gox:208:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:209:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:213:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:215:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": ctx.Param("user")})
+	}))
+//line cmd/gopcomm/community_yap.gox:222:1
+	this.Post("/admin/unban", this.community.RequirePermission(core.ActionBanUser, func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:223:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:224:1
+		err := this.community.Unban(todo, uid, ctx.Param("user"), ctx.Param("note"))
+//line cmd/gopcomm/community_yap.gox:225:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:226:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:230:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:232:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": ctx.Param("user")})
+	}))
+//line cmd/gopcomm/community_yap.gox:238:1
+	this.Get("/user/:id", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:239:1
 		id := ctx.Param("id")
-//line cmd/gopcomm/community_yap.gox:95:1
+//line cmd/gopcomm/community_yap.gox:241:1
 		userClaim, err := this.community.GetUserClaim(id)
-//line cmd/gopcomm/community_yap.gox:96:1
+//line cmd/gopcomm/community_yap.gox:242:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:97:1
+//line cmd/gopcomm/community_yap.gox:243:1
 			xLog.Error("get current user error:", err)
 		}
-//line cmd/gopcomm/community_yap.gox:101:1
-		// todo middleware
+//line cmd/gopcomm/community_yap.gox:246:1
 		// get user by token
-		var user *core.User
-//line cmd/gopcomm/community_yap.gox:102:1
-		token, err := core.GetToken(ctx)
-//line cmd/gopcomm/community_yap.gox:103:1
-		if err == nil {
-//line cmd/gopcomm/community_yap.gox:104:1
-			user, err = this.community.GetUser(token.Value)
-//line cmd/gopcomm/community_yap.gox:105:1
-			if err != nil {
-//line cmd/gopcomm/community_yap.gox:106:1
-				xLog.Error("get user error:", err)
-			}
-		}
-//line cmd/gopcomm/community_yap.gox:110:1
-		items, _ := this.community.GetArticlesByUid(todo, id)
-//line cmd/gopcomm/community_yap.gox:111:1
+		user := core.CurrentUser(ctx)
+//line cmd/gopcomm/community_yap.gox:247:1
+		viewer := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:249:1
+		// get article list published by uid, drafts included for the author
+		items, _, next, _ := this.community.GetArticlesByUid(todo, id, viewer, core.MarkBegin, limitConst)
+//line cmd/gopcomm/community_yap.gox:250:1
+		bookmarks, _, bookmarksNext, _ := this.community.Bookmarks(todo, id, core.MarkBegin, limitConst)
+//line cmd/gopcomm/community_yap.gox:251:1
+		bookmarksJson, _ := json.Marshal(&bookmarks)
+//line cmd/gopcomm/community_yap.gox:253:1
+		// follows
+		followingCount, followersCount, _ := this.community.CountFollows(todo, id)
+//line cmd/gopcomm/community_yap.gox:254:1
+		following, _ := this.community.Following(todo, id, 0, limitConst)
+//line cmd/gopcomm/community_yap.gox:255:1
+		followers, _ := this.community.Followers(todo, id, 0, limitConst)
+//line cmd/gopcomm/community_yap.gox:256:1
+		isFollowing, _ := this.community.IsFollowing(todo, viewer, id)
+//line cmd/gopcomm/community_yap.gox:257:1
+		followingJson, _ := json.Marshal(&following)
+//line cmd/gopcomm/community_yap.gox:258:1
+		followersJson, _ := json.Marshal(&followers)
+//line cmd/gopcomm/community_yap.gox:259:1
 		userClaimJson, _ := json.Marshal(&userClaim)
-//line cmd/gopcomm/community_yap.gox:112:1
+//line cmd/gopcomm/community_yap.gox:260:1
 		itemsJson, _ := json.Marshal(&items)
-//line cmd/gopcomm/community_yap.gox:113:1
+//line cmd/gopcomm/community_yap.gox:261:1
 		ctx.Yap__1("user", map[string]interface {
-		}{"Id": id, "CurrentUser": strings.Replace(string(userClaimJson), `\"`, `"`, -1), "User": user, "Items": strings.Replace(string(itemsJson), `\"`, `"`, -1)})
-	})
-//line cmd/gopcomm/community_yap.gox:121:1
+		}{"Id": id, "CurrentUser": strings.Replace(string(userClaimJson), `\"`, `"`, -1), "User": user, "Items": strings.Replace(string(itemsJson), `\"`, `"`, -1), "Next": next, "Bookmarks": strings.Replace(string(bookmarksJson), `\"`, `"`, -1), "BookmarksNext": bookmarksNext, "Viewer": viewer, "FollowingCount": followingCount, "FollowersCount": followersCount, "Following": strings.Replace(string(followingJson), `\"`, `"`, -1), "Followers": strings.Replace(string(followersJson), `\"`, `"`, -1), "IsFollowing": isFollowing})
+	}))
+//line cmd/gopcomm/community_yap.gox:278:1
 	this.Get("/add", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:122:1
+//line cmd/gopcomm/community_yap.gox:279:1
 		ctx.Yap__1("edit", map[string]interface {
 		}{})
 	})
-//line cmd/gopcomm/community_yap.gox:125:1
-	this.Get("/delete", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:126:1
+//line cmd/gopcomm/community_yap.gox:283:1
+	this.Post("/delete", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:284:1
 		id := ctx.Param("id")
-//line cmd/gopcomm/community_yap.gox:127:1
-		token, err := core.GetToken(ctx)
-//line cmd/gopcomm/community_yap.gox:128:1
-		uid, err := this.community.ParseJwtToken(token.Value)
-//line cmd/gopcomm/community_yap.gox:129:1
-		if err != nil {
-//line cmd/gopcomm/community_yap.gox:130:1
-			xLog.Error("token parse error")
-//line cmd/gopcomm/community_yap.gox:131:1
-			ctx.Json__1(map[string]interface {
-			}{"code": 0, "err": err.Error()})
-		}
-//line cmd/gopcomm/community_yap.gox:136:1
-		err = this.community.DeleteArticle(todo, uid, id)
-//line cmd/gopcomm/community_yap.gox:137:1
+//line cmd/gopcomm/community_yap.gox:285:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:286:1
+		err := this.community.DeleteArticle(todo, uid, id)
+//line cmd/gopcomm/community_yap.gox:287:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:138:1
+//line cmd/gopcomm/community_yap.gox:288:1
 			ctx.Json__1(map[string]interface {
 			}{"code": 0, "err": "delete failed"})
 		} else {
-//line cmd/gopcomm/community_yap.gox:143:1
+//line cmd/gopcomm/community_yap.gox:293:1
 			ctx.Json__1(map[string]interface {
 			}{"code": 200, "msg": "delete success"})
 		}
-	})
-//line cmd/gopcomm/community_yap.gox:150:1
-	this.Get("/", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:152:1
+	}))
+//line cmd/gopcomm/community_yap.gox:300:1
+	this.Get("/", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:302:1
 		// Get User Info
-		var user *core.User
-//line cmd/gopcomm/community_yap.gox:153:1
-		token, err := core.GetToken(ctx)
-//line cmd/gopcomm/community_yap.gox:154:1
-		if err == nil {
-//line cmd/gopcomm/community_yap.gox:155:1
-			user, err = this.community.GetUser(token.Value)
-//line cmd/gopcomm/community_yap.gox:156:1
-			if err != nil {
-//line cmd/gopcomm/community_yap.gox:157:1
-				xLog.Error("get user error:", err)
-			}
-		}
-//line cmd/gopcomm/community_yap.gox:161:1
-		articles, next, _ := this.community.ListArticle(todo, core.MarkBegin, limitConst, "")
-//line cmd/gopcomm/community_yap.gox:162:1
+		user := core.CurrentUser(ctx)
+//line cmd/gopcomm/community_yap.gox:303:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:305:1
+		// Get Article Info, with the scheduled articles of the user
+		articles, _, next, _ := this.community.ListArticle(todo, core.MarkBegin, limitConst, "", uid)
+//line cmd/gopcomm/community_yap.gox:306:1
 		articlesJson, _ := json.Marshal(&articles)
-//line cmd/gopcomm/community_yap.gox:163:1
+//line cmd/gopcomm/community_yap.gox:307:1
 		ctx.Yap__1("home", map[string]interface {
 		}{"User": user, "Items": strings.Replace(string(articlesJson), `\"`, `"`, -1), "Next": next})
-	})
-//line cmd/gopcomm/community_yap.gox:170:1
-	this.Get("/get", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:171:1
+	}))
+//line cmd/gopcomm/community_yap.gox:314:1
+	this.Get("/get", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:315:1
 		from := ctx.Param("from")
-//line cmd/gopcomm/community_yap.gox:172:1
+//line cmd/gopcomm/community_yap.gox:316:1
 		limit := ctx.Param("limit")
-//line cmd/gopcomm/community_yap.gox:173:1
+//line cmd/gopcomm/community_yap.gox:317:1
 		searchValue := ctx.Param("value")
-//line cmd/gopcomm/community_yap.gox:175:1
+//line cmd/gopcomm/community_yap.gox:318:1
+		tag := ctx.Param("tag")
+//line cmd/gopcomm/community_yap.gox:319:1
+		author := ctx.Param("uid")
+//line cmd/gopcomm/community_yap.gox:320:1
+		bookmarkedBy := ctx.Param("bookmarks")
+//line cmd/gopcomm/community_yap.gox:321:1
+		feed := ctx.Param("feed")
+//line cmd/gopcomm/community_yap.gox:323:1
 		limitInt, err := strconv.Atoi(limit)
-//line cmd/gopcomm/community_yap.gox:176:1
+//line cmd/gopcomm/community_yap.gox:324:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:177:1
+//line cmd/gopcomm/community_yap.gox:325:1
 			limitInt = limitConst
 		}
-//line cmd/gopcomm/community_yap.gox:180:1
-		articles, next, _ := this.community.ListArticle(todo, from, limitInt, searchValue)
-//line cmd/gopcomm/community_yap.gox:182:1
+//line cmd/gopcomm/community_yap.gox:327:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:329:1
+		var articles []*core.ArticleEntry
+//line cmd/gopcomm/community_yap.gox:330:1
+		var prev, next string
+//line cmd/gopcomm/community_yap.gox:331:1
+		if tag != "" {
+//line cmd/gopcomm/community_yap.gox:332:1
+			articles, prev, next, _ = this.community.ArticlesByTag(todo, tag, from, limitInt, uid)
+		} else if feed != "" {
+//line cmd/gopcomm/community_yap.gox:334:1
+			articles, prev, next, _ = this.community.Feed(todo, uid, from, limitInt)
+		} else if bookmarkedBy != "" {
+//line cmd/gopcomm/community_yap.gox:336:1
+			articles, prev, next, _ = this.community.Bookmarks(todo, bookmarkedBy, from, limitInt)
+		} else if author != "" {
+//line cmd/gopcomm/community_yap.gox:338:1
+			articles, prev, next, _ = this.community.GetArticlesByUid(todo, author, uid, from, limitInt)
+		} else {
+//line cmd/gopcomm/community_yap.gox:340:1
+			articles, prev, next, _ = this.community.ListArticle(todo, from, limitInt, searchValue, uid)
+		}
+//line cmd/gopcomm/community_yap.gox:343:1
 		ctx.Json__1(map[string]interface {
-		}{"code": 200, "items": articles, "next": next, "value": searchValue})
-	})
-//line cmd/gopcomm/community_yap.gox:190:1
-	this.Get("/search", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:191:1
-		searchValue := ctx.Param("value")
-//line cmd/gopcomm/community_yap.gox:192:1
-		if searchValue == "" {
-//line cmd/gopcomm/community_yap.gox:193:1
+		}{"code": 200, "items": articles, "prev": prev, "next": next, "value": searchValue, "tag": tag})
+	}))
+//line cmd/gopcomm/community_yap.gox:353:1
+	this.Get("/tag/:name", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:354:1
+		tag := ctx.Param("name")
+//line cmd/gopcomm/community_yap.gox:356:1
+		user := core.CurrentUser(ctx)
+//line cmd/gopcomm/community_yap.gox:357:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:359:1
+		articles, _, next, _ := this.community.ArticlesByTag(todo, tag, core.MarkBegin, limitConst, uid)
+//line cmd/gopcomm/community_yap.gox:360:1
+		articlesJson, _ := json.Marshal(&articles)
+//line cmd/gopcomm/community_yap.gox:361:1
+		ctx.Yap__1("home", map[string]interface {
+		}{"User": user, "Items": strings.Replace(string(articlesJson), `\"`, `"`, -1), "Tag": tag, "Next": next})
+	}))
+//line cmd/gopcomm/community_yap.gox:369:1
+	this.Get("/tags", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:370:1
+		tags, err := this.community.ListTags(todo)
+//line cmd/gopcomm/community_yap.gox:371:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:372:1
 			ctx.Json__1(map[string]interface {
-			}{"code": 400, "err": "value can not be ''."})
-		}
-//line cmd/gopcomm/community_yap.gox:200:1
-		// todo middleware
-		var user *core.User
-//line cmd/gopcomm/community_yap.gox:201:1
-		token, err := core.GetToken(ctx)
-//line cmd/gopcomm/community_yap.gox:202:1
-		if err == nil {
-//line cmd/gopcomm/community_yap.gox:203:1
-			user, err = this.community.GetUser(token.Value)
-//line cmd/gopcomm/community_yap.gox:204:1
-			if err != nil {
-//line cmd/gopcomm/community_yap.gox:205:1
-				xLog.Error("get user error:", err)
-			}
+			}{"code": 500, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:376:1
+			return
 		}
-//line cmd/gopcomm/community_yap.gox:209:1
-		articles, next, _ := this.community.ListArticle(todo, core.MarkBegin, limitConst, searchValue)
-//line cmd/gopcomm/community_yap.gox:210:1
+//line cmd/gopcomm/community_yap.gox:378:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "items": tags})
+	})
+//line cmd/gopcomm/community_yap.gox:385:1
+	this.Get("/feed", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:386:1
+		user := core.CurrentUser(ctx)
+//line cmd/gopcomm/community_yap.gox:387:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:388:1
+		articles, _, next, _ := this.community.Feed(todo, uid, core.MarkBegin, limitConst)
+//line cmd/gopcomm/community_yap.gox:389:1
 		articlesJson, _ := json.Marshal(&articles)
-//line cmd/gopcomm/community_yap.gox:211:1
+//line cmd/gopcomm/community_yap.gox:390:1
 		ctx.Yap__1("home", map[string]interface {
-		}{"User": user, "Items": strings.Replace(string(articlesJson), `\"`, `"`, -1), "Value": searchValue, "Next": next})
+		}{"User": user, "Items": strings.Replace(string(articlesJson), `\"`, `"`, -1), "Feed": true, "Next": next})
+	}))
+//line cmd/gopcomm/community_yap.gox:399:1
+	this.Get("/feed.xml", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:400:1
+		f, err := this.community.SyndicationFeed(todo, "")
+//line cmd/gopcomm/community_yap.gox:401:1
+		if err == core.ErrNotExist {
+//line cmd/gopcomm/community_yap.gox:402:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:403:1
+			return
+		} else if err != nil {
+//line cmd/gopcomm/community_yap.gox:405:1
+			xLog.Error("syndication feed error:", err)
+//line cmd/gopcomm/community_yap.gox:406:1
+			ctx.Yap__1("5xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:407:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:409:1
+		feed.Serve(ctx.ResponseWriter, ctx.Request, this.community.BaseURL(ctx.Request), this.community.HasSiteURL(), f, feed.Atom)
 	})
-//line cmd/gopcomm/community_yap.gox:219:1
-	this.Get("/edit/:id", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:220:1
-		token, err := core.GetToken(ctx)
-//line cmd/gopcomm/community_yap.gox:221:1
-		if err != nil {
-//line cmd/gopcomm/community_yap.gox:222:1
-			ctx.Json__1(map[string]interface {
-			}{"code": 500, "err": "no token"})
+//line cmd/gopcomm/community_yap.gox:412:1
+	this.Get("/rss.xml", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:413:1
+		f, err := this.community.SyndicationFeed(todo, "")
+//line cmd/gopcomm/community_yap.gox:414:1
+		if err == core.ErrNotExist {
+//line cmd/gopcomm/community_yap.gox:415:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:416:1
+			return
+		} else if err != nil {
+//line cmd/gopcomm/community_yap.gox:418:1
+			xLog.Error("syndication feed error:", err)
+//line cmd/gopcomm/community_yap.gox:419:1
+			ctx.Yap__1("5xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:420:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:422:1
+		feed.Serve(ctx.ResponseWriter, ctx.Request, this.community.BaseURL(ctx.Request), this.community.HasSiteURL(), f, feed.RSS)
+	})
+//line cmd/gopcomm/community_yap.gox:425:1
+	this.Get("/feed.json", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:426:1
+		f, err := this.community.SyndicationFeed(todo, "")
+//line cmd/gopcomm/community_yap.gox:427:1
+		if err == core.ErrNotExist {
+//line cmd/gopcomm/community_yap.gox:428:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:429:1
+			return
+		} else if err != nil {
+//line cmd/gopcomm/community_yap.gox:431:1
+			xLog.Error("syndication feed error:", err)
+//line cmd/gopcomm/community_yap.gox:432:1
+			ctx.Yap__1("5xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:433:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:435:1
+		feed.Serve(ctx.ResponseWriter, ctx.Request, this.community.BaseURL(ctx.Request), this.community.HasSiteURL(), f, feed.JSON)
+	})
+//line cmd/gopcomm/community_yap.gox:438:1
+	this.Get("/user/:id/feed.xml", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:439:1
+		f, err := this.community.SyndicationFeed(todo, ctx.Param("id"))
+//line cmd/gopcomm/community_yap.gox:440:1
+		if err == core.ErrNotExist {
+//line cmd/gopcomm/community_yap.gox:441:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:442:1
+			return
+		} else if err != nil {
+//line cmd/gopcomm/community_yap.gox:444:1
+			xLog.Error("syndication feed error:", err)
+//line cmd/gopcomm/community_yap.gox:445:1
+			ctx.Yap__1("5xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:446:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:448:1
+		feed.Serve(ctx.ResponseWriter, ctx.Request, this.community.BaseURL(ctx.Request), this.community.HasSiteURL(), f, feed.Atom)
+	})
+//line cmd/gopcomm/community_yap.gox:451:1
+	this.Get("/user/:id/rss.xml", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:452:1
+		f, err := this.community.SyndicationFeed(todo, ctx.Param("id"))
+//line cmd/gopcomm/community_yap.gox:453:1
+		if err == core.ErrNotExist {
+//line cmd/gopcomm/community_yap.gox:454:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:455:1
+			return
+		} else if err != nil {
+//line cmd/gopcomm/community_yap.gox:457:1
+			xLog.Error("syndication feed error:", err)
+//line cmd/gopcomm/community_yap.gox:458:1
+			ctx.Yap__1("5xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:459:1
+			return
 		}
-//line cmd/gopcomm/community_yap.gox:228:1
-		uid, err := this.community.ParseJwtToken(token.Value)
-//line cmd/gopcomm/community_yap.gox:229:1
+//line cmd/gopcomm/community_yap.gox:461:1
+		feed.Serve(ctx.ResponseWriter, ctx.Request, this.community.BaseURL(ctx.Request), this.community.HasSiteURL(), f, feed.RSS)
+	})
+//line cmd/gopcomm/community_yap.gox:464:1
+	this.Get("/user/:id/feed.json", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:465:1
+		f, err := this.community.SyndicationFeed(todo, ctx.Param("id"))
+//line cmd/gopcomm/community_yap.gox:466:1
+		if err == core.ErrNotExist {
+//line cmd/gopcomm/community_yap.gox:467:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:468:1
+			return
+		} else if err != nil {
+//line cmd/gopcomm/community_yap.gox:470:1
+			xLog.Error("syndication feed error:", err)
+//line cmd/gopcomm/community_yap.gox:471:1
+			ctx.Yap__1("5xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:472:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:474:1
+		feed.Serve(ctx.ResponseWriter, ctx.Request, this.community.BaseURL(ctx.Request), this.community.HasSiteURL(), f, feed.JSON)
+	})
+//line cmd/gopcomm/community_yap.gox:478:1
+	this.Get("/sitemap.xml", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:479:1
+		if ctx.Param("page") == "" {
+//line cmd/gopcomm/community_yap.gox:480:1
+			idx, err := this.community.SitemapIndex(todo)
+//line cmd/gopcomm/community_yap.gox:481:1
+			if err != nil {
+//line cmd/gopcomm/community_yap.gox:482:1
+				xLog.Error("sitemap error:", err)
+//line cmd/gopcomm/community_yap.gox:483:1
+				ctx.Yap__1("5xx", map[string]interface {
+				}{})
+//line cmd/gopcomm/community_yap.gox:484:1
+				return
+			}
+//line cmd/gopcomm/community_yap.gox:486:1
+			sitemap.Serve(ctx.ResponseWriter, ctx.Request, this.community.BaseURL(ctx.Request), this.community.HasSiteURL(), idx)
+//line cmd/gopcomm/community_yap.gox:487:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:489:1
+		page, err := strconv.Atoi(ctx.Param("page"))
+//line cmd/gopcomm/community_yap.gox:490:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:230:1
+//line cmd/gopcomm/community_yap.gox:491:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:492:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:494:1
+		s, err := this.community.Sitemap(todo, page)
+//line cmd/gopcomm/community_yap.gox:495:1
+		if err == core.ErrNotExist {
+//line cmd/gopcomm/community_yap.gox:496:1
+			ctx.Yap__1("4xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:497:1
+			return
+		} else if err != nil {
+//line cmd/gopcomm/community_yap.gox:499:1
+			xLog.Error("sitemap error:", err)
+//line cmd/gopcomm/community_yap.gox:500:1
+			ctx.Yap__1("5xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:501:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:503:1
+		sitemap.Serve(ctx.ResponseWriter, ctx.Request, this.community.BaseURL(ctx.Request), this.community.HasSiteURL(), s)
+	})
+//line cmd/gopcomm/community_yap.gox:506:1
+	this.Get("/robots.txt", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:507:1
+		ctx.ResponseWriter.Header().Set("Content-Type", "text/plain; charset=utf-8")
+//line cmd/gopcomm/community_yap.gox:508:1
+		fmt.Fprintf(ctx.ResponseWriter, "User-agent: *\nAllow: /\nSitemap: %s/sitemap.xml\n", this.community.BaseURL(ctx.Request))
+	})
+//line cmd/gopcomm/community_yap.gox:511:1
+	this.Get("/search", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:512:1
+		searchValue := ctx.Param("value")
+//line cmd/gopcomm/community_yap.gox:513:1
+		if searchValue == "" {
+//line cmd/gopcomm/community_yap.gox:514:1
 			ctx.Json__1(map[string]interface {
-			}{"code": 500, "err": err.Error()})
+			}{"code": 400, "err": "value can not be ''."})
+//line cmd/gopcomm/community_yap.gox:518:1
+			return
 		}
-//line cmd/gopcomm/community_yap.gox:236:1
+//line cmd/gopcomm/community_yap.gox:521:1
+		user := core.CurrentUser(ctx)
+//line cmd/gopcomm/community_yap.gox:522:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:524:1
+		articles, _, next, _ := this.community.ListArticle(todo, core.MarkBegin, limitConst, searchValue, uid)
+//line cmd/gopcomm/community_yap.gox:525:1
+		articlesJson, _ := json.Marshal(&articles)
+//line cmd/gopcomm/community_yap.gox:526:1
+		ctx.Yap__1("home", map[string]interface {
+		}{"User": user, "Items": strings.Replace(string(articlesJson), `\"`, `"`, -1), "Value": searchValue, "Next": next})
+	}))
+//line cmd/gopcomm/community_yap.gox:534:1
+	this.Get("/edit/:id", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:535:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:536:1
 		id := ctx.Param("id")
-//line cmd/gopcomm/community_yap.gox:237:1
+//line cmd/gopcomm/community_yap.gox:537:1
 		if id != "" {
-//line cmd/gopcomm/community_yap.gox:238:1
+//line cmd/gopcomm/community_yap.gox:538:1
 			if
-//line cmd/gopcomm/community_yap.gox:238:1
+//line cmd/gopcomm/community_yap.gox:538:1
 			editable, _ := this.community.CanEditable(todo, uid, id); !editable {
-//line cmd/gopcomm/community_yap.gox:239:1
+//line cmd/gopcomm/community_yap.gox:539:1
 				xLog.Error("no permissions")
-//line cmd/gopcomm/community_yap.gox:240:1
+//line cmd/gopcomm/community_yap.gox:540:1
 				http.Redirect(ctx.ResponseWriter, ctx.Request, "/error", http.StatusTemporaryRedirect)
+//line cmd/gopcomm/community_yap.gox:541:1
+				return
 			}
-//line cmd/gopcomm/community_yap.gox:242:1
+//line cmd/gopcomm/community_yap.gox:543:1
 			article, _ := this.community.Article(todo, id)
-//line cmd/gopcomm/community_yap.gox:243:1
+//line cmd/gopcomm/community_yap.gox:544:1
 			ctx.Yap__1("edit", article)
 		}
-	})
-//line cmd/gopcomm/community_yap.gox:247:1
-	this.Get("/getTrans", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:248:1
+	}))
+//line cmd/gopcomm/community_yap.gox:548:1
+	this.Get("/getTrans", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:549:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:550:1
 		id := ctx.Param("id")
-//line cmd/gopcomm/community_yap.gox:249:1
-		htmlUrl, err := this.community.TransHtmlUrl(todo, id)
-//line cmd/gopcomm/community_yap.gox:250:1
+//line cmd/gopcomm/community_yap.gox:551:1
+		htmlUrl, err := this.community.TransHtmlUrl(todo, uid, id)
+//line cmd/gopcomm/community_yap.gox:552:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:251:1
+//line cmd/gopcomm/community_yap.gox:553:1
 			ctx.Json__1(map[string]interface {
 			}{"code": 500, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:557:1
+			return
 		}
-//line cmd/gopcomm/community_yap.gox:256:1
+//line cmd/gopcomm/community_yap.gox:559:1
 		ctx.Json__1(map[string]interface {
 		}{"code": 200, "data": htmlUrl})
-	})
-//line cmd/gopcomm/community_yap.gox:263:1
-	this.Post("/commit", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:265:1
+	}))
+//line cmd/gopcomm/community_yap.gox:566:1
+	this.Post("/commit", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:568:1
 		trans := ctx.Param("trans")
-//line cmd/gopcomm/community_yap.gox:266:1
+//line cmd/gopcomm/community_yap.gox:569:1
 		id := ctx.Param("id")
-//line cmd/gopcomm/community_yap.gox:267:1
+//line cmd/gopcomm/community_yap.gox:570:1
 		mdData := ctx.Param("content")
-//line cmd/gopcomm/community_yap.gox:268:1
-		htmlData := ctx.Param("html")
-//line cmd/gopcomm/community_yap.gox:270:1
-		token, err := core.GetToken(ctx)
-//line cmd/gopcomm/community_yap.gox:271:1
+//line cmd/gopcomm/community_yap.gox:572:1
+		// render html on the server instead of trusting the browser
+		htmlData, err := markdown.Render(mdData)
+//line cmd/gopcomm/community_yap.gox:573:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:272:1
+//line cmd/gopcomm/community_yap.gox:574:1
+			xLog.Error("render markdown error:", err)
+//line cmd/gopcomm/community_yap.gox:575:1
+			htmlData = ctx.Param("html")
+		}
+//line cmd/gopcomm/community_yap.gox:578:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:580:1
+		// published unless saved as a draft
+		status, err := core.ParseStatus(ctx.Param("status"))
+//line cmd/gopcomm/community_yap.gox:581:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:582:1
 			ctx.Json__1(map[string]interface {
-			}{"code": 500, "err": "no token"})
+			}{"code": 400, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:586:1
+			return
 		}
-//line cmd/gopcomm/community_yap.gox:277:1
-		uid, err := this.community.ParseJwtToken(token.Value)
-//line cmd/gopcomm/community_yap.gox:278:1
+//line cmd/gopcomm/community_yap.gox:589:1
+		// scheduled if publishAt is in the future
+		var publishAt time.Time
+//line cmd/gopcomm/community_yap.gox:590:1
+		if at := ctx.Param("publishAt"); at != "" {
+//line cmd/gopcomm/community_yap.gox:591:1
+			publishAt, err = time.Parse(time.RFC3339, at)
+//line cmd/gopcomm/community_yap.gox:592:1
+			if err != nil {
+//line cmd/gopcomm/community_yap.gox:593:1
+				ctx.Json__1(map[string]interface {
+				}{"code": 400, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:597:1
+				return
+			}
+		}
+//line cmd/gopcomm/community_yap.gox:601:1
+		article := &core.Article{ArticleEntry: core.ArticleEntry{ID: id, Title: ctx.Param("title"), UId: uid, Cover: ctx.Param("cover"), Tags: ctx.Param("tags"), Abstract: ctx.Param("abstract"), Status: status, PublishAt: publishAt}, Content: mdData, HtmlData: htmlData}
+//line cmd/gopcomm/community_yap.gox:615:1
+		id, err = this.community.PutArticle(todo, uid, trans, article)
+//line cmd/gopcomm/community_yap.gox:616:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:279:1
+//line cmd/gopcomm/community_yap.gox:617:1
 			ctx.Json__1(map[string]interface {
-			}{"code": 500, "err": err.Error()})
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:621:1
+			return
 		}
-//line cmd/gopcomm/community_yap.gox:286:1
-		article := &core.Article{ArticleEntry: core.ArticleEntry{ID: id, Title: ctx.Param("title"), UId: uid, Cover: ctx.Param("cover"), Tags: ctx.Param("tags"), Abstract: ctx.Param("abstract")}, Content: mdData, HtmlData: htmlData}
-//line cmd/gopcomm/community_yap.gox:298:1
-		id, _ = this.community.PutArticle(todo, uid, trans, article)
-//line cmd/gopcomm/community_yap.gox:299:1
+//line cmd/gopcomm/community_yap.gox:623:1
 		ctx.Json__1(map[string]interface {
 		}{"code": 200, "data": id})
-	})
-//line cmd/gopcomm/community_yap.gox:307:1
-	this.Post("/translate", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:309:1
-		token, err := core.GetToken(ctx)
-//line cmd/gopcomm/community_yap.gox:310:1
+	}))
+//line cmd/gopcomm/community_yap.gox:631:1
+	this.Post("/publish", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:632:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:633:1
+		status, err := core.ParseStatus(ctx.Param("status"))
+//line cmd/gopcomm/community_yap.gox:634:1
+		if err != nil || status == core.StatusDraft {
+//line cmd/gopcomm/community_yap.gox:635:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 400, "err": "invalid status"})
+//line cmd/gopcomm/community_yap.gox:639:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:641:1
+		err = this.community.SetArticleStatus(todo, uid, ctx.Param("id"), status)
+//line cmd/gopcomm/community_yap.gox:642:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:311:1
+//line cmd/gopcomm/community_yap.gox:643:1
 			ctx.Json__1(map[string]interface {
-			}{"code": 500, "err": "no token"})
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:647:1
+			return
 		}
-//line cmd/gopcomm/community_yap.gox:316:1
-		uid, err := this.community.ParseJwtToken(token.Value)
-//line cmd/gopcomm/community_yap.gox:317:1
+//line cmd/gopcomm/community_yap.gox:649:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": status.String()})
+	}))
+//line cmd/gopcomm/community_yap.gox:656:1
+	this.Post("/unpublish", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:657:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:658:1
+		err := this.community.SetArticleStatus(todo, uid, ctx.Param("id"), core.StatusDraft)
+//line cmd/gopcomm/community_yap.gox:659:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:318:1
+//line cmd/gopcomm/community_yap.gox:660:1
 			ctx.Json__1(map[string]interface {
-			}{"code": 500, "err": err.Error()})
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:664:1
+			return
 		}
-//line cmd/gopcomm/community_yap.gox:324:1
+//line cmd/gopcomm/community_yap.gox:666:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": core.StatusDraft.String()})
+	}))
+//line cmd/gopcomm/community_yap.gox:673:1
+	this.Post("/schedule", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:674:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:675:1
+		publishAt, err := time.Parse(time.RFC3339, ctx.Param("publishAt"))
+//line cmd/gopcomm/community_yap.gox:676:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:677:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 400, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:681:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:683:1
+		err = this.community.ScheduleArticle(todo, uid, ctx.Param("id"), publishAt)
+//line cmd/gopcomm/community_yap.gox:684:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:685:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:689:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:691:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": core.StatusScheduled.String()})
+	}))
+//line cmd/gopcomm/community_yap.gox:698:1
+	this.Get("/revisions/:id", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:699:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:700:1
+		items, err := this.community.ArticleRevisions(todo, uid, ctx.Param("id"))
+//line cmd/gopcomm/community_yap.gox:701:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:702:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:706:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:708:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "items": items})
+	}))
+//line cmd/gopcomm/community_yap.gox:715:1
+	this.Get("/revisionDiff/:id", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:716:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:717:1
+		diff, err := this.community.RevisionDiff(todo, uid, ctx.Param("id"), ctx.Param("from"), ctx.Param("to"))
+//line cmd/gopcomm/community_yap.gox:718:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:719:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:723:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:725:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": diff})
+	}))
+//line cmd/gopcomm/community_yap.gox:731:1
+	this.Post("/restoreRevision", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:732:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:733:1
+		id := ctx.Param("id")
+//line cmd/gopcomm/community_yap.gox:734:1
+		err := this.community.RestoreRevision(todo, uid, id, ctx.Param("revision"))
+//line cmd/gopcomm/community_yap.gox:735:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:736:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:740:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:742:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": id})
+	}))
+//line cmd/gopcomm/community_yap.gox:749:1
+	this.Get("/comments/:id", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:750:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:751:1
+		limit, err := strconv.Atoi(ctx.Param("limit"))
+//line cmd/gopcomm/community_yap.gox:752:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:753:1
+			limit = limitConst
+		}
+//line cmd/gopcomm/community_yap.gox:755:1
+		items, next, err := this.community.ListComments(todo, ctx.Param("id"), ctx.Param("from"), limit, uid)
+//line cmd/gopcomm/community_yap.gox:756:1
+		if err != nil && err != io.EOF {
+//line cmd/gopcomm/community_yap.gox:757:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:761:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:763:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "items": items, "next": next})
+	}))
+//line cmd/gopcomm/community_yap.gox:771:1
+	this.Post("/comment", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:772:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:773:1
+		comment, err := this.community.PutComment(todo, uid, ctx.Param("article"), ctx.Param("parent"), ctx.Param("content"))
+//line cmd/gopcomm/community_yap.gox:774:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:775:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:779:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:781:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": comment})
+	}))
+//line cmd/gopcomm/community_yap.gox:787:1
+	this.Post("/editComment", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:788:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:789:1
+		comment, err := this.community.EditComment(todo, uid, ctx.Param("id"), ctx.Param("content"))
+//line cmd/gopcomm/community_yap.gox:790:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:791:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:795:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:797:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": comment})
+	}))
+//line cmd/gopcomm/community_yap.gox:803:1
+	this.Post("/deleteComment", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:804:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:805:1
+		id := ctx.Param("id")
+//line cmd/gopcomm/community_yap.gox:806:1
+		err := this.community.DeleteComment(todo, uid, id)
+//line cmd/gopcomm/community_yap.gox:807:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:808:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:812:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:814:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": id})
+	}))
+//line cmd/gopcomm/community_yap.gox:821:1
+	this.Post("/role", this.community.RequirePermission(core.ActionManageRoles, func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:822:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:823:1
+		var role core.Role
+//line cmd/gopcomm/community_yap.gox:824:1
+		if name := ctx.Param("role"); name != "" {
+//line cmd/gopcomm/community_yap.gox:825:1
+			r, err := core.ParseRole(name)
+//line cmd/gopcomm/community_yap.gox:826:1
+			if err != nil {
+//line cmd/gopcomm/community_yap.gox:827:1
+				ctx.Json__1(map[string]interface {
+				}{"code": 400, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:831:1
+				return
+			}
+//line cmd/gopcomm/community_yap.gox:833:1
+			role = r
+		}
+//line cmd/gopcomm/community_yap.gox:835:1
+		user := ctx.Param("user")
+//line cmd/gopcomm/community_yap.gox:836:1
+		err := this.community.SetRole(todo, uid, user, role)
+//line cmd/gopcomm/community_yap.gox:837:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:838:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:842:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:844:1
+		role, _ = this.community.Role(todo, user)
+//line cmd/gopcomm/community_yap.gox:845:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": role.String()})
+	}))
+//line cmd/gopcomm/community_yap.gox:852:1
+	this.Post("/report", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:853:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:854:1
+		err := this.community.ReportContent(todo, uid, ctx.Param("kind"), ctx.Param("id"), ctx.Param("reason"))
+//line cmd/gopcomm/community_yap.gox:855:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:856:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:860:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:862:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": ctx.Param("id")})
+	}))
+//line cmd/gopcomm/community_yap.gox:869:1
+	this.Post("/like", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:870:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:871:1
+		count, err := this.community.LikeArticle(todo, uid, ctx.Param("id"), true)
+//line cmd/gopcomm/community_yap.gox:872:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:873:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:877:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:879:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": count})
+	}))
+//line cmd/gopcomm/community_yap.gox:886:1
+	this.Post("/unlike", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:887:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:888:1
+		count, err := this.community.LikeArticle(todo, uid, ctx.Param("id"), false)
+//line cmd/gopcomm/community_yap.gox:889:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:890:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:894:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:896:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": count})
+	}))
+//line cmd/gopcomm/community_yap.gox:903:1
+	this.Post("/bookmark", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:904:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:905:1
+		count, err := this.community.BookmarkArticle(todo, uid, ctx.Param("id"), true)
+//line cmd/gopcomm/community_yap.gox:906:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:907:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:911:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:913:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": count})
+	}))
+//line cmd/gopcomm/community_yap.gox:920:1
+	this.Post("/unbookmark", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:921:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:922:1
+		count, err := this.community.BookmarkArticle(todo, uid, ctx.Param("id"), false)
+//line cmd/gopcomm/community_yap.gox:923:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:924:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:928:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:930:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": count})
+	}))
+//line cmd/gopcomm/community_yap.gox:937:1
+	this.Post("/follow", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:938:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:939:1
+		id := ctx.Param("id")
+//line cmd/gopcomm/community_yap.gox:940:1
+		if err := this.community.Follow(todo, uid, id, true); err != nil {
+//line cmd/gopcomm/community_yap.gox:941:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:945:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:947:1
+		_, followers, _ := this.community.CountFollows(todo, id)
+//line cmd/gopcomm/community_yap.gox:948:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": followers})
+	}))
+//line cmd/gopcomm/community_yap.gox:955:1
+	this.Post("/unfollow", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:956:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:957:1
+		id := ctx.Param("id")
+//line cmd/gopcomm/community_yap.gox:958:1
+		if err := this.community.Follow(todo, uid, id, false); err != nil {
+//line cmd/gopcomm/community_yap.gox:959:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:963:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:965:1
+		_, followers, _ := this.community.CountFollows(todo, id)
+//line cmd/gopcomm/community_yap.gox:966:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": followers})
+	}))
+//line cmd/gopcomm/community_yap.gox:973:1
+	this.Get("/notifications", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:974:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:975:1
+		limit, err := strconv.Atoi(ctx.Param("limit"))
+//line cmd/gopcomm/community_yap.gox:976:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:977:1
+			limit = limitConst
+		}
+//line cmd/gopcomm/community_yap.gox:979:1
+		from := ctx.Param("from")
+//line cmd/gopcomm/community_yap.gox:980:1
+		if from == "" {
+//line cmd/gopcomm/community_yap.gox:981:1
+			from = core.MarkBegin
+		}
+//line cmd/gopcomm/community_yap.gox:983:1
+		items, next, err := this.community.Notifications(todo, uid, from, limit)
+//line cmd/gopcomm/community_yap.gox:984:1
+		if err != nil && err != io.EOF {
+//line cmd/gopcomm/community_yap.gox:985:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:989:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:991:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "items": items, "next": next})
+	}))
+//line cmd/gopcomm/community_yap.gox:999:1
+	this.Get("/notifications/unread", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1000:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:1001:1
+		unread, err := this.community.UnreadCount(todo, uid)
+//line cmd/gopcomm/community_yap.gox:1002:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:1003:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:1007:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:1009:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": unread})
+	}))
+//line cmd/gopcomm/community_yap.gox:1016:1
+	this.Get("/notifications/events", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1017:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:1018:1
+		flusher, ok := ctx.ResponseWriter.(http.Flusher)
+//line cmd/gopcomm/community_yap.gox:1019:1
+		if !ok {
+//line cmd/gopcomm/community_yap.gox:1020:1
+			ctx.WriteHeader(http.StatusNotImplemented)
+//line cmd/gopcomm/community_yap.gox:1021:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:1023:1
+		unreadCh, cancel := this.community.SubscribeUnread(uid)
+//line cmd/gopcomm/community_yap.gox:1024:1
+		defer cancel()
+//line cmd/gopcomm/community_yap.gox:1025:1
+		unread, err := this.community.UnreadCount(todo, uid)
+//line cmd/gopcomm/community_yap.gox:1026:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:1027:1
+			ctx.WriteHeader(http.StatusInternalServerError)
+//line cmd/gopcomm/community_yap.gox:1028:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:1030:1
+		ctx.ResponseWriter.Header().Set("Content-Type", "text/event-stream")
+//line cmd/gopcomm/community_yap.gox:1031:1
+		ctx.ResponseWriter.Header().Set("Cache-Control", "no-cache")
+//line cmd/gopcomm/community_yap.gox:1032:1
+		keepAlive := time.NewTicker(30 * time.Second)
+//line cmd/gopcomm/community_yap.gox:1033:1
+		defer keepAlive.Stop()
+//line cmd/gopcomm/community_yap.gox:1034:1
+		for {
+//line cmd/gopcomm/community_yap.gox:1035:1
+			fmt.Fprintf(ctx.ResponseWriter, "event: unread\ndata: %d\n\n", unread)
+//line cmd/gopcomm/community_yap.gox:1036:1
+			flusher.Flush()
+//line cmd/gopcomm/community_yap.gox:1037:1
+			select {
+//line cmd/gopcomm/community_yap.gox:1038:1
+			case unread = <-unreadCh:
+//line cmd/gopcomm/community_yap.gox:1039:1
+			case <-keepAlive.C:
+//line cmd/gopcomm/community_yap.gox:1040:1
+			case <-ctx.Context().Done():
+//line cmd/gopcomm/community_yap.gox:1041:1
+				return
+			}
+		}
+	}))
+//line cmd/gopcomm/community_yap.gox:1047:1
+	this.Post("/markRead", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1048:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:1049:1
+		var ids []string
+//line cmd/gopcomm/community_yap.gox:1050:1
+		if s := ctx.Param("ids"); s != "" {
+//line cmd/gopcomm/community_yap.gox:1051:1
+			ids = strings.Split(s, ",")
+		}
+//line cmd/gopcomm/community_yap.gox:1053:1
+		if err := this.community.MarkRead(todo, uid, ids); err != nil {
+//line cmd/gopcomm/community_yap.gox:1054:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:1058:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:1060:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200})
+	}))
+//line cmd/gopcomm/community_yap.gox:1066:1
+	this.Get("/prefs", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1067:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:1068:1
+		prefs, err := this.community.NotificationPrefs(todo, uid)
+//line cmd/gopcomm/community_yap.gox:1069:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:1070:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:1074:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:1076:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": prefs})
+	}))
+//line cmd/gopcomm/community_yap.gox:1083:1
+	this.Post("/prefs", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1084:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:1085:1
+		prefs := &core.NotificationPrefs{EmailReplies: ctx.Param("replies") == "true", EmailDigest: ctx.Param("digest") == "true"}
+//line cmd/gopcomm/community_yap.gox:1089:1
+		if err := this.community.SetNotificationPrefs(todo, uid, prefs); err != nil {
+//line cmd/gopcomm/community_yap.gox:1090:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:1094:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:1096:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200, "data": prefs})
+	}))
+//line cmd/gopcomm/community_yap.gox:1103:1
+	this.Post("/translate", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1105:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:1106:1
 		mdData := ctx.Param("content")
-//line cmd/gopcomm/community_yap.gox:325:1
-		htmlData := ctx.Param("html")
-//line cmd/gopcomm/community_yap.gox:326:1
+//line cmd/gopcomm/community_yap.gox:1107:1
+		htmlData, err := markdown.Render(mdData)
+//line cmd/gopcomm/community_yap.gox:1108:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:1109:1
+			xLog.Error("render markdown error:", err)
+//line cmd/gopcomm/community_yap.gox:1110:1
+			htmlData = ctx.Param("html")
+		}
+//line cmd/gopcomm/community_yap.gox:1112:1
 		id := ctx.Param("id")
-//line cmd/gopcomm/community_yap.gox:328:1
+//line cmd/gopcomm/community_yap.gox:1114:1
 		transData, err := this.trans.TranslateMarkdownText(mdData, language.Chinese, language.English)
-//line cmd/gopcomm/community_yap.gox:329:1
+//line cmd/gopcomm/community_yap.gox:1115:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:330:1
+//line cmd/gopcomm/community_yap.gox:1116:1
 			ctx.Json__1(map[string]interface {
 			}{"code": 500, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:1120:1
+			return
 		}
-//line cmd/gopcomm/community_yap.gox:335:1
+//line cmd/gopcomm/community_yap.gox:1122:1
 		id, _ = this.community.SaveHtml(todo, uid, htmlData, mdData, id)
-//line cmd/gopcomm/community_yap.gox:336:1
+//line cmd/gopcomm/community_yap.gox:1123:1
 		ctx.Json__1(map[string]interface {
 		}{"code": 200, "id": id, "data": transData})
-	})
-//line cmd/gopcomm/community_yap.gox:343:1
-	this.Get("/getMedia/:id", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:344:1
+	}))
+//line cmd/gopcomm/community_yap.gox:1131:1
+	this.Get("/getMedia/:id", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1132:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:1133:1
 		mediaId := ctx.Param("id")
-//line cmd/gopcomm/community_yap.gox:346:1
-		fileKey, _ := this.community.GetMediaUrl(context.Background(), mediaId)
-//line cmd/gopcomm/community_yap.gox:348:1
+//line cmd/gopcomm/community_yap.gox:1134:1
+		fileKey, err := this.community.GetVisibleMediaUrl(context.Background(), uid, mediaId)
+//line cmd/gopcomm/community_yap.gox:1135:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:1136:1
+			http.NotFound(ctx.ResponseWriter, ctx.Request)
+//line cmd/gopcomm/community_yap.gox:1137:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:1140:1
 		http.Redirect(ctx.ResponseWriter, ctx.Request, domain+fileKey, http.StatusTemporaryRedirect)
-	})
-//line cmd/gopcomm/community_yap.gox:351:1
-	this.Get("/getMediaUrl/:id", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:352:1
+	}))
+//line cmd/gopcomm/community_yap.gox:1143:1
+	this.Get("/getMediaUrl/:id", this.community.OptionalAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1144:1
+		uid := core.UserId(ctx)
+//line cmd/gopcomm/community_yap.gox:1145:1
 		id := ctx.Param("id")
-//line cmd/gopcomm/community_yap.gox:353:1
-		fileKey, err := this.community.GetMediaUrl(todo, id)
-//line cmd/gopcomm/community_yap.gox:354:1
+//line cmd/gopcomm/community_yap.gox:1146:1
+		fileKey, err := this.community.GetVisibleMediaUrl(todo, uid, id)
+//line cmd/gopcomm/community_yap.gox:1147:1
 		htmlUrl := fmt.Sprintf("%s%s", domain, fileKey)
-//line cmd/gopcomm/community_yap.gox:355:1
+//line cmd/gopcomm/community_yap.gox:1148:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:356:1
+//line cmd/gopcomm/community_yap.gox:1149:1
 			ctx.Json__1(map[string]interface {
 			}{"code": 500, "err": "have no html media"})
+//line cmd/gopcomm/community_yap.gox:1153:1
+			return
 		}
-//line cmd/gopcomm/community_yap.gox:361:1
+//line cmd/gopcomm/community_yap.gox:1155:1
 		ctx.Json__1(map[string]interface {
 		}{"code": 200, "url": htmlUrl})
-	})
-//line cmd/gopcomm/community_yap.gox:367:1
-	this.Post("/upload", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:368:1
+	}))
+//line cmd/gopcomm/community_yap.gox:1161:1
+	this.Post("/upload", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1162:1
 		core.UploadFile(ctx, this.community)
-	})
-//line cmd/gopcomm/community_yap.gox:371:1
+	}))
+//line cmd/gopcomm/community_yap.gox:1165:1
 	this.Get("/login", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:376:1
-		redirectURL := fmt.Sprintf("%s/%s", ctx.Request.Referer(), "callback")
-//line cmd/gopcomm/community_yap.gox:378:1
-		loginURL := this.community.RedirectToCasdoor(redirectURL)
-//line cmd/gopcomm/community_yap.gox:379:1
+//line cmd/gopcomm/community_yap.gox:1168:1
+		returnTo := ctx.URL.Query().Get("redirect_url")
+//line cmd/gopcomm/community_yap.gox:1169:1
+		if returnTo == "" {
+//line cmd/gopcomm/community_yap.gox:1170:1
+			returnTo = ctx.Request.Referer()
+		}
+//line cmd/gopcomm/community_yap.gox:1173:1
+		loginURL, err := this.community.RedirectToCasdoor(ctx, returnTo)
+//line cmd/gopcomm/community_yap.gox:1174:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:1175:1
+			xLog.Error("redirect to casdoor error:", err)
+//line cmd/gopcomm/community_yap.gox:1176:1
+			ctx.Yap__1("5xx", map[string]interface {
+			}{})
+//line cmd/gopcomm/community_yap.gox:1177:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:1179:1
 		ctx.Redirect(loginURL, http.StatusFound)
 	})
-//line cmd/gopcomm/community_yap.gox:383:1
-	this.Get("/logout", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:384:1
-		err := core.RemoveToken(ctx)
-//line cmd/gopcomm/community_yap.gox:385:1
+//line cmd/gopcomm/community_yap.gox:1184:1
+	this.Get("/login/local", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1185:1
+		this.community.LocalLogin(ctx)
+	})
+//line cmd/gopcomm/community_yap.gox:1191:1
+	this.Post("/logout", func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1192:1
+		err := this.community.RemoveToken(ctx)
+//line cmd/gopcomm/community_yap.gox:1193:1
+		if err == core.ErrPermission {
+//line cmd/gopcomm/community_yap.gox:1194:1
+			core.Forbidden(ctx)
+//line cmd/gopcomm/community_yap.gox:1195:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:1197:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:386:1
+//line cmd/gopcomm/community_yap.gox:1198:1
 			xLog.Error("remove token error:", err)
 		}
-//line cmd/gopcomm/community_yap.gox:390:1
-		http.Redirect(ctx.ResponseWriter, ctx.Request, fmt.Sprintf("/"), http.StatusFound)
+//line cmd/gopcomm/community_yap.gox:1202:1
+		http.Redirect(ctx.ResponseWriter, ctx.Request, fmt.Sprintf("/"), http.StatusSeeOther)
 	})
-//line cmd/gopcomm/community_yap.gox:393:1
+//line cmd/gopcomm/community_yap.gox:1206:1
+	this.Post("/logout/all", this.community.RequireAuth(func(ctx *yap.Context) {
+//line cmd/gopcomm/community_yap.gox:1207:1
+		err := this.community.SignOutEverywhere(ctx)
+//line cmd/gopcomm/community_yap.gox:1208:1
+		if err != nil {
+//line cmd/gopcomm/community_yap.gox:1209:1
+			ctx.Json__1(map[string]interface {
+			}{"code": 0, "err": err.Error()})
+//line cmd/gopcomm/community_yap.gox:1213:1
+			return
+		}
+//line cmd/gopcomm/community_yap.gox:1215:1
+		ctx.Json__1(map[string]interface {
+		}{"code": 200})
+	}))
+//line cmd/gopcomm/community_yap.gox:1220:1
 	this.Get("/callback", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:394:1
-		err := core.SetToken(ctx)
-//line cmd/gopcomm/community_yap.gox:395:1
+//line cmd/gopcomm/community_yap.gox:1221:1
+		returnTo, err := this.community.SetToken(ctx)
+//line cmd/gopcomm/community_yap.gox:1222:1
 		if err != nil {
-//line cmd/gopcomm/community_yap.gox:396:1
+//line cmd/gopcomm/community_yap.gox:1223:1
 			xLog.Error("set token error:", err)
+//line cmd/gopcomm/community_yap.gox:1224:1
+			returnTo = "/"
 		}
-//line cmd/gopcomm/community_yap.gox:401:1
-		http.Redirect(ctx.ResponseWriter, ctx.Request, fmt.Sprintf("/"), http.StatusFound)
+//line cmd/gopcomm/community_yap.gox:1228:1
+		http.Redirect(ctx.ResponseWriter, ctx.Request, returnTo, http.StatusFound)
 	})
-//line cmd/gopcomm/community_yap.gox:404:1
-	conf := &core.Config{}
-//line cmd/gopcomm/community_yap.gox:405:1
-	this.community, _ = core.New(todo, conf)
-//line cmd/gopcomm/community_yap.gox:406:1
-	this.trans = translation.New(os.Getenv("NIUTRANS_API_KEY"), "", "")
-//line cmd/gopcomm/community_yap.gox:407:1
-	core.CasdoorConfigInit()
-//line cmd/gopcomm/community_yap.gox:410:1
+//line cmd/gopcomm/community_yap.gox:1232:1
 	this.Handle("/", func(ctx *yap.Context) {
-//line cmd/gopcomm/community_yap.gox:411:1
+//line cmd/gopcomm/community_yap.gox:1233:1
 		ctx.Yap__1("4xx", map[string]interface {
 		}{})
 	})
-//line cmd/gopcomm/community_yap.gox:414:1
+//line cmd/gopcomm/community_yap.gox:1236:1
 	xLog.Info("Started in endpoint: ", endpoint)
-//line cmd/gopcomm/community_yap.gox:417:1
+//line cmd/gopcomm/community_yap.gox:1239:1
 	this.Run(endpoint, func(h http.Handler) http.Handler {
-//line cmd/gopcomm/community_yap.gox:419:1
+//line cmd/gopcomm/community_yap.gox:1241:1
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-//line cmd/gopcomm/community_yap.gox:420:1
+//line cmd/gopcomm/community_yap.gox:1242:1
 			defer func() {
-//line cmd/gopcomm/community_yap.gox:421:1
+//line cmd/gopcomm/community_yap.gox:1243:1
 				if
-//line cmd/gopcomm/community_yap.gox:421:1
+//line cmd/gopcomm/community_yap.gox:1243:1
 				err := recover(); err != nil {
-//line cmd/gopcomm/community_yap.gox:422:1
+//line cmd/gopcomm/community_yap.gox:1244:1
 					http.Redirect(w, r, "/failed", http.StatusFound)
 				}
 			}()
-//line cmd/gopcomm/community_yap.gox:426:1
+//line cmd/gopcomm/community_yap.gox:1248:1
 			h.ServeHTTP(w, r)
 		})
 	})
@@ -0,0 +1,56 @@
+// CSRF defence: the requests which change something on the site, those
+// other than GET and HEAD, carry the CSRF token of the session, which the
+// site hands to the page in the csrf cookie.
+(function () {
+    function csrfToken() {
+        const m = document.cookie.match(/(?:^|;\s*)csrf=([^;]*)/);
+        return m ? decodeURIComponent(m[1]) : "";
+    }
+
+    function needsToken(method, url) {
+        if (/^(GET|HEAD|OPTIONS)$/i.test(method || "GET")) {
+            return false;
+        }
+        return new URL(url, location.href).origin === location.origin;
+    }
+
+    const fetch = window.fetch;
+    window.fetch = function (input, init) {
+        init = init || {};
+        const request = input instanceof Request ? input : null;
+        const method = init.method || (request ? request.method : "GET");
+        if (needsToken(method, request ? request.url : String(input))) {
+            const headers = new Headers(init.headers || (request ? request.headers : undefined));
+            headers.set("X-CSRF-Token", csrfToken());
+            init = Object.assign({}, init, { headers });
+        }
+        return fetch.call(this, input, init);
+    };
+
+    // for the editor, which posts by XMLHttpRequest
+    const open = XMLHttpRequest.prototype.open;
+    const send = XMLHttpRequest.prototype.send;
+    XMLHttpRequest.prototype.open = function (method, url) {
+        this.csrf = needsToken(method, url);
+        return open.apply(this, arguments);
+    };
+    XMLHttpRequest.prototype.send = function () {
+        if (this.csrf) {
+            this.setRequestHeader("X-CSRF-Token", csrfToken());
+        }
+        return send.apply(this, arguments);
+    };
+
+    // for the forms, such as the one signing out
+    document.addEventListener("submit", function (e) {
+        const form = e.target;
+        if (!needsToken(form.method, form.action) || form.elements.csrf) {
+            return;
+        }
+        const input = document.createElement("input");
+        input.type = "hidden";
+        input.name = "csrf";
+        input.value = csrfToken();
+        form.appendChild(input);
+    }, true);
+})();
@@ -0,0 +1,98 @@
+// Notification center of the header: the unread badge is kept up to date
+// by server-sent events, falling back to polling where they don't work.
+(function () {
+    const bell = document.getElementById("notificationBell");
+    if (!bell) {
+        return;
+    }
+    const badge = document.getElementById("unreadBadge");
+    const panel = document.getElementById("notificationPanel");
+    const list = document.getElementById("notificationList");
+
+    function setUnread(n) {
+        badge.textContent = n > 99 ? "99+" : String(n);
+        badge.classList.toggle("hidden", n === 0);
+    }
+
+    function pollUnread() {
+        fetch("/notifications/unread")
+        .then(res => res.json())
+        .then(todos => {
+            if (todos.code === 200) {
+                setUnread(todos.data);
+            }
+        });
+    }
+
+    if (window.EventSource) {
+        const events = new EventSource("/notifications/events");
+        events.addEventListener("unread", e => setUnread(Number(e.data)));
+        events.onerror = () => {
+            if (events.readyState === EventSource.CLOSED) {
+                window.setInterval(pollUnread, 60000);
+            }
+        };
+    } else {
+        pollUnread();
+        window.setInterval(pollUnread, 60000);
+    }
+
+    const describe = {
+        comment: "commented on",
+        reply: "replied to you on",
+        like: "liked",
+        follow: "started following you",
+    };
+
+    function renderItem(n) {
+        const li = document.createElement("li");
+        li.className = "px-4 py-2 text-sm text-gray-700" + (n.Read ? "" : " bg-blue-50");
+        const actor = document.createElement("a");
+        actor.href = "/user/" + encodeURIComponent(n.ActorID);
+        actor.className = "font-medium text-gray-900";
+        actor.textContent = n.Actor.Name;
+        li.append(actor, " " + describe[n.Kind] + " ");
+        if (n.ArticleID) {
+            const article = document.createElement("a");
+            article.href = "/p/" + n.ArticleID + (n.CommentID ? "#comments" : "");
+            article.className = "text-blue-600";
+            article.textContent = n.ArticleTitle;
+            li.append(article);
+        }
+        return li;
+    }
+
+    function loadNotifications() {
+        fetch("/notifications")
+        .then(res => res.json())
+        .then(todos => {
+            if (todos.code !== 200) {
+                return;
+            }
+            list.replaceChildren(...todos.items.map(renderItem));
+            if (todos.items.length === 0) {
+                const li = document.createElement("li");
+                li.className = "px-4 py-2 text-sm text-gray-400";
+                li.textContent = "No notifications yet.";
+                list.append(li);
+            }
+        });
+    }
+
+    bell.addEventListener("click", () => {
+        panel.classList.toggle("hidden");
+        if (!panel.classList.contains("hidden")) {
+            loadNotifications();
+        }
+    });
+
+    document.getElementById("markAllRead").addEventListener("click", () => {
+        fetch("/markRead", { method: "POST" })
+        .then(res => res.json())
+        .then(todos => {
+            if (todos.code === 200) {
+                list.querySelectorAll(".bg-blue-50").forEach(li => li.classList.remove("bg-blue-50"));
+            }
+        });
+    });
+})();
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/goplus/yap"
+)
+
+// Handler is a yap route handler, as taken by the auth middlewares.
+type Handler = func(ctx *yap.Context)
+
+type userKey struct{}
+
+// CurrentUser returns the user a request is made by, as resolved by
+// RequireAuth or OptionalAuth. It's nil for visitors.
+func CurrentUser(ctx *yap.Context) *User {
+	user, _ := ctx.Context().Value(userKey{}).(*User)
+	return user
+}
+
+// UserId returns the id of the current user, or "" for visitors.
+func UserId(ctx *yap.Context) string {
+	if user := CurrentUser(ctx); user != nil {
+		return user.Id
+	}
+	return ""
+}
+
+// Authenticate returns the user of the session of a request. It returns
+// ErrNotExist if there's no session, or if its token is invalid.
+func (p *Community) Authenticate(ctx *yap.Context) (*User, error) {
+	token, err := p.GetToken(ctx)
+	if err != nil {
+		return nil, ErrNotExist
+	}
+	user, err := p.GetUser(token)
+	if err != nil {
+		return nil, ErrNotExist
+	}
+	return user, nil
+}
+
+// OptionalAuth wraps handle to resolve the current user if the request
+// carries a valid token. Visitors are passed on without a user.
+func (p *Community) OptionalAuth(handle Handler) Handler {
+	return func(ctx *yap.Context) {
+		if user, err := p.Authenticate(ctx); err == nil {
+			p.ensureCSRFCookie(ctx)
+			withUser(ctx, user)
+		}
+		handle(ctx)
+	}
+}
+
+// RequireAuth wraps handle to resolve the current user, and responds 401
+// without calling handle to visitors. Requests other than GET and HEAD are
+// responded 403 unless they carry the CSRF token of the session, see
+// checkCSRF.
+func (p *Community) RequireAuth(handle Handler) Handler {
+	return func(ctx *yap.Context) {
+		user, err := p.Authenticate(ctx)
+		if err != nil {
+			Unauthorized(ctx)
+			return
+		}
+		if !p.checkCSRF(ctx) {
+			Forbidden(ctx)
+			return
+		}
+		p.ensureCSRFCookie(ctx)
+		withUser(ctx, user)
+		handle(ctx)
+	}
+}
+
+// RequirePermission wraps handle like RequireAuth, and also responds 403
+// without calling handle to users who may not do action on others'
+// content, see Community.Can.
+func (p *Community) RequirePermission(action Action, handle Handler) Handler {
+	return p.RequireAuth(func(ctx *yap.Context) {
+		if !p.Can(ctx.Context(), UserId(ctx), action, "") {
+			Forbidden(ctx)
+			return
+		}
+		handle(ctx)
+	})
+}
+
+func withUser(ctx *yap.Context, user *User) {
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Context(), userKey{}, user))
+}
+
+// Unauthorized responds 401 to a request made by a visitor. Pages are
+// redirected to the login page instead, which returns to them.
+func Unauthorized(ctx *yap.Context) {
+	if isPage(ctx) {
+		ctx.Redirect("/login?redirect_url="+url.QueryEscape(ctx.URL.RequestURI()), http.StatusFound)
+		return
+	}
+	ctx.JSON(http.StatusUnauthorized, map[string]interface{}{
+		"code": http.StatusUnauthorized,
+		"err":  "unauthorized",
+	})
+}
+
+// Forbidden responds 403 to a request the user isn't allowed to make.
+func Forbidden(ctx *yap.Context) {
+	if isPage(ctx) {
+		ctx.ResponseWriter.Header().Set("Content-Type", "text/html")
+		ctx.ResponseWriter.WriteHeader(http.StatusForbidden)
+		ctx.YAP(http.StatusForbidden, "4xx", map[string]interface{}{})
+		return
+	}
+	ctx.JSON(http.StatusForbidden, map[string]interface{}{
+		"code": http.StatusForbidden,
+		"err":  "forbidden",
+	})
+}
+
+// isPage reports whether a request is a browser navigation rather than an
+// api call made by a script.
+func isPage(ctx *yap.Context) bool {
+	return ctx.Method == http.MethodGet && ctx.Accept("text/html") != ""
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/goplus/yap"
+)
+
+// initTestCasdoor makes casdoor accept the tokens signed by the returned
+// key, standing for the tokens casdoor issues.
+func initTestCasdoor(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "casdoor"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	casdoorsdk.InitConfig("http://casdoor.test", "client", "secret", string(cert), "goplus", "community")
+	return key
+}
+
+// signTestToken returns a token of user uid signed by key.
+func signTestToken(t *testing.T, key *rsa.PrivateKey, uid string) string {
+	claims := &casdoorsdk.Claims{
+		User: casdoorsdk.User{Id: uid, Name: "user" + uid},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// serveAuth calls the handler wrapped by auth, and returns the response and
+// the id of the user the handler was called with, or "-" if it wasn't called.
+func serveAuth(auth func(Handler) Handler, req *http.Request) (*httptest.ResponseRecorder, string) {
+	uid := "-"
+	w := httptest.NewRecorder()
+	auth(func(ctx *yap.Context) {
+		uid = UserId(ctx)
+		if user := CurrentUser(ctx); user != nil && user.Name != "user"+uid {
+			uid = "?"
+		}
+	})(&yap.Context{Request: req, ResponseWriter: w})
+	return w, uid
+}
+
+var testSessions int
+
+// newAuthRequest returns a request of a session holding token, with the
+// CSRF token of the session, or of a visitor if token is empty.
+func newAuthRequest(t *testing.T, community *Community, method, token string, page bool) *http.Request {
+	req := httptest.NewRequest(method, "/", nil)
+	if token != "" {
+		testSessions++
+		s := &Session{ID: "session" + strconv.Itoa(testSessions), AccessToken: token, Expiry: time.Now().Add(time.Hour)}
+		if err := community.store.InsertSession(context.TODO(), s); err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: s.ID})
+		req.Header.Set(csrfHeader, community.csrfToken(s.ID))
+	}
+	if page {
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	}
+	return req
+}
+
+func TestRequireAuth(t *testing.T) {
+	community := newTestCommunity(t)
+	key := initTestCasdoor(t)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	for _, token := range []string{"", "invalid", signTestToken(t, other, "1")} {
+		w, uid := serveAuth(community.RequireAuth, newAuthRequest(t, community, "POST", token, false))
+		if uid != "-" {
+			t.Errorf("RequireAuth() called the handler with token %q", token)
+		}
+		var ret struct {
+			Code int    `json:"code"`
+			Err  string `json:"err"`
+		}
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("RequireAuth() responded %d with token %q, expected: %d", w.Code, token, http.StatusUnauthorized)
+		} else if err := json.Unmarshal(w.Body.Bytes(), &ret); err != nil || ret.Code != http.StatusUnauthorized {
+			t.Errorf("RequireAuth() responded %q, expected code %d", w.Body, http.StatusUnauthorized)
+		}
+	}
+
+	// sessions which don't exist, or were revoked
+	req := newAuthRequest(t, community, "POST", "", false)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "revoked"})
+	if w, uid := serveAuth(community.RequireAuth, req); uid != "-" || w.Code != http.StatusUnauthorized {
+		t.Errorf("RequireAuth() of a revoked session responded %d", w.Code)
+	}
+
+	// pages are redirected to the login page instead
+	w, uid := serveAuth(community.RequireAuth, newAuthRequest(t, community, "GET", "", true))
+	if uid != "-" || w.Code != http.StatusFound || w.Header().Get("Location") != "/login?redirect_url=%2F" {
+		t.Errorf("RequireAuth() of a page responded %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w, uid = serveAuth(community.RequireAuth, newAuthRequest(t, community, "POST", signTestToken(t, key, "1"), false))
+	if uid != "1" {
+		t.Errorf("RequireAuth() resolved user %q and responded %d, expected: 1", uid, w.Code)
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	community := newTestCommunity(t)
+	key := initTestCasdoor(t)
+
+	for _, token := range []string{"", "invalid"} {
+		if _, uid := serveAuth(community.OptionalAuth, newAuthRequest(t, community, "GET", token, true)); uid != "" {
+			t.Errorf("OptionalAuth() resolved user %q with token %q, expected a visitor", uid, token)
+		}
+	}
+	if _, uid := serveAuth(community.OptionalAuth, newAuthRequest(t, community, "GET", signTestToken(t, key, "2"), true)); uid != "2" {
+		t.Errorf("OptionalAuth() resolved user %q, expected: 2", uid)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	community := newTestCommunity(t)
+	key := initTestCasdoor(t)
+	moderate := func(handle Handler) Handler {
+		return community.RequirePermission(ActionModerate, handle)
+	}
+
+	w, uid := serveAuth(moderate, newAuthRequest(t, community, "POST", "", false))
+	if uid != "-" || w.Code != http.StatusUnauthorized {
+		t.Errorf("RequirePermission() responded %d to a visitor, expected: %d", w.Code, http.StatusUnauthorized)
+	}
+	// authors may not moderate
+	w, uid = serveAuth(moderate, newAuthRequest(t, community, "POST", signTestToken(t, key, "1"), false))
+	if uid != "-" || w.Code != http.StatusForbidden {
+		t.Errorf("RequirePermission() responded %d to an author, expected: %d", w.Code, http.StatusForbidden)
+	}
+	if err := community.store.SetRole(context.TODO(), "1", RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+	w, uid = serveAuth(moderate, newAuthRequest(t, community, "POST", signTestToken(t, key, "1"), false))
+	if uid != "1" {
+		t.Errorf("RequirePermission() responded %d to a moderator", w.Code)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	community := newTestCommunity(t)
+	key := initTestCasdoor(t)
+	token := signTestToken(t, key, "1")
+
+	tests := []struct {
+		method string
+		csrf   string // "" for the token of the session
+		uid    string
+	}{
+		{"POST", "", "1"},
+		{"POST", "-", "-"}, // no token
+		{"POST", "forged", "-"},
+		{"GET", "-", "1"},
+		{"GET", "forged", "1"},
+	}
+	for _, tt := range tests {
+		req := newAuthRequest(t, community, tt.method, token, false)
+		switch tt.csrf {
+		case "":
+		case "-":
+			req.Header.Del(csrfHeader)
+		default:
+			req.Header.Set(csrfHeader, tt.csrf)
+		}
+		w, uid := serveAuth(community.RequireAuth, req)
+		if uid != tt.uid {
+			t.Errorf("RequireAuth() of %s with CSRF token %q resolved user %q and responded %d, expected: %q", tt.method, tt.csrf, uid, w.Code, tt.uid)
+		}
+		if tt.uid == "-" && w.Code != http.StatusForbidden {
+			t.Errorf("RequireAuth() of %s with CSRF token %q responded %d, expected: %d", tt.method, tt.csrf, w.Code, http.StatusForbidden)
+		}
+	}
+
+	// the tokens of sessions differ, and are signed by the secret
+	if community.csrfToken("a") == community.csrfToken("b") {
+		t.Errorf("csrfToken() is the same for different sessions")
+	}
+	other := newTestCommunity(t)
+	other.secret = []byte("another secret")
+	if community.csrfToken("a") == other.csrfToken("a") {
+		t.Errorf("csrfToken() is the same for different secrets")
+	}
+}
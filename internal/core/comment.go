@@ -0,0 +1,256 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goplus/community/markdown"
+)
+
+// MaxCommentLen is the maximum length of a comment in runes.
+const MaxCommentLen = 10000
+
+var (
+	errEmptyComment   = errors.New("core: empty comment")
+	errCommentTooLong = errors.New("core: comment too long")
+)
+
+// Comment is a comment on an article, or a reply to another comment.
+type Comment struct {
+	ID        string
+	ArticleID string
+	ParentID  string // "" for a top-level comment
+	UId       string
+	User      User
+	Content   string // in markdown, "" once deleted
+	Html      string // rendered and sanitized Content
+	Deleted   bool
+	Hidden    bool // by a moderator, listed without content
+	Ctime     time.Time
+	Mtime     time.Time
+
+	Replies []*Comment // oldest first
+}
+
+// renderComment renders the markdown of a comment into safe html.
+func renderComment(content string) (html string, err error) {
+	if content == "" {
+		return "", errEmptyComment
+	}
+	if utf8.RuneCountInString(content) > MaxCommentLen {
+		return "", errCommentTooLong
+	}
+	html, err = markdown.Render(content)
+	if err != nil {
+		return
+	}
+	return markdown.Sanitize(html), nil
+}
+
+// PutComment adds a comment by uid on article articleId, which replies to
+// comment parentId if not empty.
+func (p *Community) PutComment(ctx context.Context, uid, articleId, parentId, content string) (*Comment, error) {
+	if err := p.authorize(ctx, uid, ActionCreateComment, uid); err != nil {
+		return &Comment{}, err
+	}
+	article, _, err := p.store.GetArticle(ctx, articleId)
+	if err != nil {
+		return &Comment{}, err
+	}
+	if !article.VisibleTo(uid) {
+		return &Comment{}, ErrNotExist
+	}
+	var parent *Comment
+	if parentId != "" {
+		if parent, err = p.store.GetComment(ctx, parentId); err != nil {
+			return &Comment{}, err
+		}
+		if parent.ArticleID != articleId || parent.Deleted {
+			return &Comment{}, ErrNotExist
+		}
+	}
+	content = strings.TrimSpace(content)
+	html, err := renderComment(content)
+	if err != nil {
+		return &Comment{}, err
+	}
+	c := &Comment{ArticleID: articleId, ParentID: parentId, UId: uid, Content: content, Html: html}
+	id, err := p.store.InsertComment(ctx, c)
+	if err != nil {
+		return &Comment{}, err
+	}
+	p.notify(ctx, &Notification{UId: article.UId, ActorID: uid, Kind: NotifyComment, ArticleID: articleId, CommentID: id})
+	if parent != nil && parent.UId != article.UId {
+		p.notify(ctx, &Notification{UId: parent.UId, ActorID: uid, Kind: NotifyReply, ArticleID: articleId, CommentID: id})
+	}
+	return p.comment(ctx, id)
+}
+
+// comment returns comment id with its author.
+func (p *Community) comment(ctx context.Context, id string) (*Comment, error) {
+	c, err := p.store.GetComment(ctx, id)
+	if err != nil {
+		return c, err
+	}
+	c.User = p.authorsOf([]string{c.UId})[c.UId]
+	return c, nil
+}
+
+// EditComment replaces the content of comment id, which uid wrote.
+func (p *Community) EditComment(ctx context.Context, uid, id, content string) (*Comment, error) {
+	c, err := p.store.GetComment(ctx, id)
+	if err != nil {
+		return c, err
+	}
+	if c.Deleted {
+		return &Comment{}, ErrNotExist
+	}
+	if err = p.authorize(ctx, uid, ActionEditComment, c.UId); err != nil {
+		return &Comment{}, err
+	}
+	content = strings.TrimSpace(content)
+	html, err := renderComment(content)
+	if err != nil {
+		return &Comment{}, err
+	}
+	if err = p.store.UpdateComment(ctx, id, content, html); err != nil {
+		return &Comment{}, err
+	}
+	return p.comment(ctx, id)
+}
+
+// DeleteComment deletes comment id, which uid wrote or moderates. The
+// replies to it are kept, and it is shown as deleted until they are deleted
+// too.
+func (p *Community) DeleteComment(ctx context.Context, uid, id string) error {
+	return p.deleteComment(ctx, uid, id, "")
+}
+
+// deleteComment deletes comment id by uid, and records its deletion by a
+// moderator with a note in the audit log.
+func (p *Community) deleteComment(ctx context.Context, uid, id, note string) error {
+	c, err := p.store.GetComment(ctx, id)
+	if err != nil {
+		return err
+	}
+	if c.Deleted {
+		return ErrNotExist
+	}
+	if err = p.authorize(ctx, uid, ActionDeleteComment, c.UId); err != nil {
+		return err
+	}
+	if err = p.store.DeleteComment(ctx, id); err != nil {
+		return err
+	}
+	p.resolveReports(ctx, KindComment, id, ReportResolved)
+	if uid != c.UId {
+		p.audit(ctx, uid, AuditDelete, KindComment, id, note)
+	}
+	return nil
+}
+
+// CountComments counts the comments on article id which aren't deleted or
+// hidden.
+func (p *Community) CountComments(ctx context.Context, id string) (int, error) {
+	return p.store.CountComments(ctx, id)
+}
+
+// ListComments lists the threads of comments on article id visible to
+// viewer from a position, which is MarkBegin or the next cursor of the
+// previous page. Threads are listed newest first with all their replies.
+func (p *Community) ListComments(ctx context.Context, id, from string, limit int, viewer string) (items []*Comment, next string, err error) {
+	if from == MarkEnd || limit <= 0 {
+		return []*Comment{}, MarkEnd, nil
+	}
+	article, _, err := p.store.GetArticle(ctx, id)
+	if err != nil {
+		return []*Comment{}, from, err
+	}
+	if !article.VisibleTo(viewer) {
+		return []*Comment{}, from, ErrNotExist
+	}
+	var c *Cursor
+	if from != MarkBegin {
+		if c, err = ParseCursor(from); err != nil || c.Backward {
+			return []*Comment{}, from, errInvalidCursor
+		}
+	}
+	roots, err := p.store.ListComments(ctx, id, c, limit+1)
+	if err != nil {
+		return []*Comment{}, from, err
+	}
+	next = MarkEnd
+	if len(roots) > limit {
+		roots = roots[:limit]
+		last := roots[limit-1]
+		lastId, _ := strconv.ParseInt(last.ID, 10, 64)
+		next = (&Cursor{Ctime: last.Ctime, ID: lastId}).String()
+	}
+	if len(roots) == 0 {
+		return []*Comment{}, MarkEnd, io.EOF
+	}
+
+	rootIds := make([]string, len(roots))
+	for i, root := range roots {
+		rootIds[i] = root.ID
+	}
+	replies, err := p.store.ListReplies(ctx, rootIds)
+	if err != nil {
+		return []*Comment{}, from, err
+	}
+	all := append(roots, replies...)
+	uids := make([]string, len(all))
+	byId := make(map[string]*Comment, len(all))
+	for i, c := range all {
+		uids[i] = c.UId
+		byId[c.ID] = c
+	}
+	authors := p.authorsOf(uids)
+	for _, c := range all {
+		c.User = authors[c.UId]
+	}
+	for _, reply := range replies {
+		if parent, ok := byId[reply.ParentID]; ok {
+			parent.Replies = append(parent.Replies, reply)
+		}
+	}
+	items = pruneComments(roots)
+	return items, next, nil
+}
+
+// pruneComments drops the deleted and hidden comments without replies left,
+// and clears the content of the hidden ones kept.
+func pruneComments(items []*Comment) []*Comment {
+	ret := items[:0]
+	for _, c := range items {
+		c.Replies = pruneComments(c.Replies)
+		if c.Hidden {
+			c.Content, c.Html = "", ""
+		}
+		if !(c.Deleted || c.Hidden) || len(c.Replies) > 0 {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPutComment(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	id := putTestArticle(t, community, "1", "Test")
+	community.SetArticleStatus(todo, "1", id, StatusPublished)
+	draft := putTestArticle(t, community, "1", "Draft")
+
+	c, err := community.PutComment(todo, "2", id, "", "**Nice** <script>alert(1)</script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.User.Name != "user2" || strings.Contains(c.Html, "<script") || !strings.Contains(c.Html, "<strong>Nice</strong>") {
+		t.Errorf("PutComment() returned %+v", c)
+	}
+
+	tests := []struct {
+		uid, article, parent, content string
+		expectedErr                   error
+	}{
+		{"", id, "", "hi", ErrPermission},
+		{"2", id, "", "  ", errEmptyComment},
+		{"2", id, "", strings.Repeat("a", MaxCommentLen+1), errCommentTooLong},
+		{"2", "100", "", "hi", ErrNotExist},
+		{"2", draft, "", "hi", ErrNotExist},
+		{"1", draft, "", "hi", nil},
+		{"2", id, "100", "hi", ErrNotExist},
+		{"2", draft, c.ID, "hi", ErrNotExist}, // parent on another article
+		{"3", id, c.ID, "reply", nil},
+	}
+	for _, tt := range tests {
+		_, err := community.PutComment(todo, tt.uid, tt.article, tt.parent, tt.content)
+		if err != tt.expectedErr {
+			t.Errorf("PutComment(%s, %s, %s) returned err: %v, expected: %v", tt.uid, tt.article, tt.parent, err, tt.expectedErr)
+		}
+	}
+}
+
+func TestEditComment(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	id := putTestArticle(t, community, "1", "Test")
+	c, err := community.PutComment(todo, "1", id, "", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = community.EditComment(todo, "2", c.ID, "hello"); err != ErrPermission {
+		t.Errorf("EditComment() by another user returned err: %v, expected: %v", err, ErrPermission)
+	}
+	edited, err := community.EditComment(todo, "1", c.ID, "hello")
+	if err != nil || edited.Content != "hello" || edited.Html != "<p>hello</p>\n" {
+		t.Errorf("EditComment() returned %+v, %v", edited, err)
+	}
+
+	if err = community.DeleteComment(todo, "2", c.ID); err != ErrPermission {
+		t.Errorf("DeleteComment() by another user returned err: %v, expected: %v", err, ErrPermission)
+	}
+	if err = community.DeleteComment(todo, "1", c.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{
+		community.DeleteComment(todo, "1", c.ID),
+		func() error { _, err := community.EditComment(todo, "1", c.ID, "again"); return err }(),
+		func() error { _, err := community.PutComment(todo, "1", id, c.ID, "reply"); return err }(),
+	} {
+		if err != ErrNotExist {
+			t.Errorf("changing a deleted comment returned err: %v, expected: %v", err, ErrNotExist)
+		}
+	}
+	if deleted, _ := community.store.GetComment(todo, c.ID); !deleted.Deleted || deleted.Content != "" || deleted.Html != "" {
+		t.Errorf("DeleteComment() left %+v", deleted)
+	}
+}
+
+func TestListComments(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	id := putTestArticle(t, community, "1", "Test")
+	community.SetArticleStatus(todo, "1", id, StatusPublished)
+
+	put := func(parent, content string) string {
+		c, err := community.PutComment(todo, "1", id, parent, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.ID
+	}
+	a := put("", "a")
+	a1 := put(a, "a1")
+	put(a1, "a1a")
+	put(a, "a2")
+	b := put("", "b")
+	put(b, "b1")
+	put("", "c")
+	d := put("", "d")
+	community.DeleteComment(todo, "1", a1) // kept for its reply
+	community.DeleteComment(todo, "1", d)  // dropped
+
+	// threads as content(replies...)
+	var format func(items []*Comment) string
+	format = func(items []*Comment) string {
+		var parts []string
+		for _, c := range items {
+			s := c.Content
+			if c.Deleted {
+				s = "-"
+			}
+			if len(c.Replies) > 0 {
+				s += "(" + format(c.Replies) + ")"
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	var pages []string
+	from := MarkBegin
+	for from != MarkEnd {
+		items, next, err := community.ListComments(todo, id, from, 2, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, format(items))
+		from = next
+	}
+	want := []string{"c", "b(b1) a(-(a1a) a2)"}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("ListComments() returned pages %q, expected: %q", pages, want)
+	}
+
+	if total, err := community.CountComments(todo, id); err != nil || total != 6 {
+		t.Errorf("CountComments() returned %d, %v, expected: 6", total, err)
+	}
+
+	empty := putTestArticle(t, community, "1", "Empty")
+	if _, next, err := community.ListComments(todo, empty, MarkBegin, 2, "1"); err != io.EOF || next != MarkEnd {
+		t.Errorf("ListComments() of no comments returned %s, %v, expected: %s, %v", next, err, MarkEnd, io.EOF)
+	}
+	if _, _, err := community.ListComments(todo, empty, MarkBegin, 2, "2"); err != ErrNotExist {
+		t.Errorf("ListComments() of a draft by another user returned err: %v, expected: %v", err, ErrNotExist)
+	}
+}
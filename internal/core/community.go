@@ -18,18 +18,20 @@ package core
 
 import (
 	"context"
-	"database/sql"
+	"crypto/rand"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	cmail "github.com/goplus/community/internal/mail"
+	"github.com/goplus/community/markdown"
 	"github.com/qiniu/x/xlog"
 
 	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/goplus/yap"
 	"gocloud.dev/blob"
 	"golang.org/x/oauth2"
 )
@@ -40,38 +42,144 @@ var (
 )
 
 type Config struct {
-	Driver string // database driver. default is `mysql`.
+	Driver string // database driver: `mysql` (default) or `sqlite`.
 	DSN    string // database data source name
 	CAS    string // casdoor database data source name
 	BlobUS string // blob URL scheme
+
+	// SiteURL is the absolute URL of the site, for links in emails and
+	// feeds. It defaults to $GOP_COMMUNITY_SITE_URL.
+	SiteURL string
+
+	// Mailer sends emails. It defaults to the mailer of the URL
+	// $GOP_COMMUNITY_MAILER (see mail.Open), and emails are disabled if
+	// that is empty too.
+	Mailer cmail.Mailer
+
+	// ScheduleInterval is how often scheduled articles are checked for
+	// publishing. It defaults to one minute.
+	ScheduleInterval time.Duration
+
+	// UserCacheTTL is how long the user profiles fetched from casdoor are
+	// cached. It defaults to five minutes.
+	UserCacheTTL time.Duration
+
+	// ViewFlushInterval is how often the views counted in memory are saved.
+	// It defaults to one minute.
+	ViewFlushInterval time.Duration
+
+	// DigestInterval is how often the due digests are sent. It defaults to
+	// one hour.
+	DigestInterval time.Duration
+
+	// Secret signs the CSRF tokens of sessions. It defaults to
+	// $GOP_COMMUNITY_SECRET, and to a random secret if that is empty too,
+	// which invalidates the tokens of the pages open on restart.
+	Secret string
+
+	// Identity logs users in and keeps their profiles. It defaults to a
+	// LocalProvider of the users in the JSON file $GOP_COMMUNITY_USERS,
+	// signing its tokens with Secret, and to casdoor if that is empty.
+	Identity IdentityProvider
+}
+
+// Status is the publishing state of an article.
+type Status int
+
+const (
+	StatusDraft     Status = iota // visible to its author only
+	StatusPublished               // listed and visible to everyone
+	StatusUnlisted                // visible to everyone with the link, not listed
+	StatusArchived                // visible to everyone with the link, not listed
+	StatusScheduled               // published at PublishAt, visible to its author until then
+)
+
+var statusNames = [...]string{
+	StatusDraft:     "draft",
+	StatusPublished: "published",
+	StatusUnlisted:  "unlisted",
+	StatusArchived:  "archived",
+	StatusScheduled: "scheduled",
+}
+
+func (s Status) String() string {
+	if s >= 0 && int(s) < len(statusNames) {
+		return statusNames[s]
+	}
+	return "Status(" + strconv.Itoa(int(s)) + ")"
+}
+
+// ParseStatus parses the name of a status. An empty name is StatusPublished.
+func ParseStatus(name string) (Status, error) {
+	if name == "" {
+		return StatusPublished, nil
+	}
+	for s, n := range statusNames {
+		if n == name {
+			return Status(s), nil
+		}
+	}
+	return 0, fmt.Errorf("core: invalid article status %q", name)
 }
 
 type ArticleEntry struct {
-	ID       string
-	Title    string
-	UId      string
-	Cover    string
-	Tags     string
-	User     User
-	Abstract string
-	Ctime    time.Time
-	Mtime    time.Time
+	ID        string
+	Title     string
+	UId       string
+	Cover     string
+	Tags      string
+	User      User
+	Abstract  string
+	Status    Status
+	PublishAt time.Time // when a scheduled article goes live, zero if not scheduled
+	Ctime     time.Time
+	Mtime     time.Time
+
+	Likes     int
+	Bookmarks int
+	Views     int64 // flushed views only, see Community.ViewArticle
+
+	Hidden bool // by a moderator, visible to its author only
+
+	Snippet string // html of the content matching a search, in search results only
+
+	score float64 // rank in search results
+}
+
+// VisibleTo reports whether the user uid may read the article.
+func (a *ArticleEntry) VisibleTo(uid string) bool {
+	if a.Hidden {
+		return uid != "" && a.UId == uid
+	}
+	if a.Status == StatusDraft || a.Status == StatusScheduled {
+		return uid != "" && a.UId == uid
+	}
+	return true
 }
 
 type Article struct {
 	ArticleEntry
-	Content string // in markdown
-	// Status  int    // published or draft
+	Content  string // in markdown
 	HtmlUrl  string // parsed html file url
 	HtmlData string
 }
 
 type Community struct {
-	bucket        *blob.Bucket
-	db            *sql.DB
-	domain        string
-	casdoorConfig *CasdoorConfig
-	xLog          *xlog.Logger
+	bucket   *blob.Bucket
+	store    Store
+	domain   string
+	idp      IdentityProvider
+	xLog     *xlog.Logger
+	users    *userCache
+	views    *viewCounter
+	feeds    *feedCache
+	notifier *notifier
+	mailer   *mailer
+	siteURL  string
+	tokens   *oauthTokens
+	secret   []byte
+
+	stopWorkers context.CancelFunc
 }
 type CasdoorConfig struct {
 	endPoint         string
@@ -95,10 +203,12 @@ func New(ctx context.Context, conf *Config) (ret *Community, err error) {
 	if conf == nil {
 		conf = new(Config)
 	}
-	casdoorConf := casdoorConfigInit()
 	driver := conf.Driver
 	dsn := conf.DSN
 	bus := conf.BlobUS
+	if driver == "" {
+		driver = os.Getenv("GOP_COMMUNITY_DRIVER")
+	}
 	if driver == "" {
 		driver = "mysql"
 	}
@@ -109,49 +219,117 @@ func New(ctx context.Context, conf *Config) (ret *Community, err error) {
 		bus = os.Getenv("GOP_COMMUNITY_BLOBUS")
 	}
 	domain := os.Getenv("GOP_COMMUNITY_DOMAIN")
+	siteURL := conf.SiteURL
+	if siteURL == "" {
+		siteURL = os.Getenv("GOP_COMMUNITY_SITE_URL")
+	}
+	var mails *mailer
+	if conf.Mailer != nil {
+		mails = &mailer{Mailer: conf.Mailer}
+	} else if mailURL := os.Getenv("GOP_COMMUNITY_MAILER"); mailURL != "" {
+		var m cmail.Mailer
+		if m, err = cmail.Open(mailURL); err != nil {
+			xLog.Error(err)
+			return
+		}
+		mails = &mailer{Mailer: m}
+	}
 
 	bucket, err := blob.OpenBucket(ctx, bus)
 	if err != nil {
 		xLog.Error(err)
 		return
 	}
-	db, err := sql.Open(driver, dsn)
+	store, err := OpenStore(driver, dsn)
 	if err != nil {
 		xLog.Error(err)
 		return
 	}
-	return &Community{bucket, db, domain, casdoorConf, xLog}, nil
+	if err = store.Migrate(ctx, LatestVersion); err != nil {
+		xLog.Error(err)
+		store.Close()
+		return
+	}
+	interval := conf.ScheduleInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ttl := conf.UserCacheTTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	flushInterval := conf.ViewFlushInterval
+	if flushInterval <= 0 {
+		flushInterval = time.Minute
+	}
+	digestInterval := conf.DigestInterval
+	if digestInterval <= 0 {
+		digestInterval = time.Hour
+	}
+	secret := []byte(conf.Secret)
+	if len(secret) == 0 {
+		secret = []byte(os.Getenv("GOP_COMMUNITY_SECRET"))
+	}
+	if len(secret) == 0 {
+		xLog.Warn("no secret configured, CSRF tokens are invalidated on restart")
+		secret = make([]byte, 32)
+		if _, err = rand.Read(secret); err != nil {
+			store.Close()
+			return
+		}
+	}
+	idp := conf.Identity
+	if idp == nil {
+		if file := os.Getenv("GOP_COMMUNITY_USERS"); file != "" {
+			xLog.Warn("logging in the users of", file, "without casdoor")
+			var local *LocalProvider
+			if local, err = LoadLocalProvider(file, secret); err != nil {
+				xLog.Error(err)
+				store.Close()
+				return
+			}
+			idp = local
+		} else {
+			idp = casdoorConfigInit()
+		}
+	}
+	users := newUserCache(fetchUser(idp), ttl)
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	ret = &Community{bucket, store, domain, idp, xLog, users, newViewCounter(), newFeedCache(), newNotifier(), mails, strings.TrimSuffix(siteURL, "/"), newOAuthTokens(idp), secret, stopWorkers}
+	go ret.runScheduler(workerCtx, interval)
+	go ret.runViewFlusher(workerCtx, flushInterval)
+	go ret.runDigester(workerCtx, digestInterval)
+	go ret.runSessionPurger(workerCtx, time.Hour)
+	return ret, nil
 }
 
-func (p *Community) getTotal(ctx context.Context, searchValue string) (total int, err error) {
-	if searchValue != "" {
-		sqlStr := "select count(*) from article where title like ?"
-		err = p.db.QueryRow(sqlStr, "%"+searchValue+"%").Scan(&total)
-	} else {
-		sqlStr := "select count(*) from article"
-		err = p.db.QueryRow(sqlStr).Scan(&total)
+// Close stops the scheduler, waits for the emails being sent, saves the
+// views counted in memory and closes the store and the bucket.
+func (p *Community) Close() error {
+	p.stopWorkers()
+	if p.mailer != nil {
+		p.mailer.wg.Wait()
 	}
-	return
+	if err := p.flushViews(context.Background()); err != nil {
+		p.xLog.Error("flush views error:", err)
+	}
+	if err := p.store.Close(); err != nil {
+		return err
+	}
+	return p.bucket.Close()
 }
 
 // Article returns an article.
 func (p *Community) Article(ctx context.Context, id string) (article *Article, err error) {
-	article = &Article{}
-	var htmlId string
-	sqlStr := "select id,title,user_id,cover,tags,abstract,content,html_id,ctime,mtime from article where id=?"
-	err = p.db.QueryRow(sqlStr, id).Scan(&article.ID, &article.Title, &article.UId, &article.Cover, &article.Tags, &article.Abstract, &article.Content, &htmlId, &article.Ctime, &article.Mtime)
-	if err == sql.ErrNoRows {
+	article, htmlId, err := p.store.GetArticle(ctx, id)
+	if err == ErrNotExist {
 		p.xLog.Warn("not found the article")
 		return article, ErrNotExist
 	} else if err != nil {
 		return article, err
 	}
 	// add author info
-	user, err := p.GetUserById(article.UId)
-	if err != nil {
-		return
-	}
-	article.User = *user
+	p.addAuthors([]*ArticleEntry{&article.ArticleEntry})
 	// get html url
 	fileKey, err := p.GetMediaUrl(ctx, htmlId)
 	article.HtmlUrl = fmt.Sprintf("%s%s", p.domain, fileKey)
@@ -162,12 +340,18 @@ func (p *Community) Article(ctx context.Context, id string) (article *Article, e
 	return
 }
 
-// TransHtmlUrl get translation html url
-func (p *Community) TransHtmlUrl(ctx context.Context, id string) (htmlUrl string, err error) {
-	var htmlId string
-	sqlStr := "select trans_html_id from article where id=?"
-	err = p.db.QueryRow(sqlStr, id).Scan(&htmlId)
-	if err == sql.ErrNoRows {
+// TransHtmlUrl get translation html url of article id, if it's visible to
+// uid.
+func (p *Community) TransHtmlUrl(ctx context.Context, uid, id string) (htmlUrl string, err error) {
+	article, _, err := p.store.GetArticle(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if !article.VisibleTo(uid) {
+		return "", ErrNotExist
+	}
+	htmlId, err := p.store.GetTransHtmlId(ctx, id)
+	if err == ErrNotExist {
 		p.xLog.Warn("not found the translation html")
 		return "", ErrNotExist
 	}
@@ -182,264 +366,261 @@ func (p *Community) TransHtmlUrl(ctx context.Context, id string) (htmlUrl string
 	return
 }
 
-// CanEditable determine whether the user has the permission to operate.
+// CanEditable determine whether the user has the permission to operate:
+// the author and moderators may edit an article.
 func (p *Community) CanEditable(ctx context.Context, uid, id string) (editable bool, err error) {
-	sqlStr := "select id from article where id=? and user_id = ?"
-	err = p.db.QueryRow(sqlStr, id, uid).Scan(&id)
+	article, _, err := p.store.GetArticle(ctx, id)
 	if err != nil {
 		return false, ErrPermission
 	}
+	if err = p.authorize(ctx, uid, ActionEditArticle, article.UId); err != nil {
+		return false, ErrPermission
+	}
 	return true, nil
 }
 
 // SaveHtml upload origin html(string) to media for html id and save id to database
 func (p *Community) SaveHtml(ctx context.Context, uid, htmlStr, mdData, id string) (articleId string, err error) {
-	htmlId, err := p.SaveMedia(ctx, uid, []byte(htmlStr))
+	author, err := p.authorizePut(ctx, uid, id)
+	if err != nil {
+		return "", err
+	}
+	// the html belongs to the author, whose medias go with the article
+	htmlId, err := p.uploadHtml(ctx, author, htmlStr)
 	if id == "" {
-		// save to database
-		sqlStr := "insert into article (user_id, html_id, ctime, mtime, content) values (?, ?, ?)"
-		res, err := p.db.Exec(sqlStr, uid, htmlId, time.Now(), time.Now(), mdData)
-		if err != nil {
-			return "", err
-		}
-		articleId, err := res.LastInsertId()
-		return strconv.FormatInt(articleId, 10), nil
+		// save to database, the article is a draft until it is committed
+		article := &Article{ArticleEntry: ArticleEntry{UId: uid, Status: StatusDraft}, Content: mdData}
+		return p.store.InsertArticle(ctx, article, htmlId)
 	}
-	sqlStr := "update article set content=?, html_id=?, mtime=? where id=?"
-	_, err = p.db.Exec(sqlStr, mdData, htmlId, time.Now(), id)
+	err = p.store.UpdateContent(ctx, uid, id, mdData, htmlId)
 	if err != nil {
 		return "", err
 	}
 	return id, err
 }
 
-// uploadHtml upload html(string) to media for html id
+// uploadHtml sanitize html(string) and upload it to media for html id
 func (p *Community) uploadHtml(ctx context.Context, uid, htmlStr string) (htmlId int64, err error) {
-	htmlId, err = p.SaveMedia(ctx, uid, []byte(htmlStr))
+	htmlId, err = p.SaveMedia(ctx, uid, []byte(markdown.Sanitize(htmlStr)))
 	return
 }
 
+// authorizePut returns the author of article id, or uid for a new article
+// (id == ""). It returns ErrPermission unless uid may add the article or
+// edit it.
+func (p *Community) authorizePut(ctx context.Context, uid, id string) (author string, err error) {
+	if id == "" {
+		return uid, p.authorize(ctx, uid, ActionCreateArticle, uid)
+	}
+	article, _, err := p.store.GetArticle(ctx, id)
+	if err != nil {
+		return "", ErrPermission
+	}
+	if err = p.authorize(ctx, uid, ActionEditArticle, article.UId); err != nil {
+		return "", ErrPermission
+	}
+	return article.UId, nil
+}
+
 // PutArticle adds new article (ID == "") or edits an existing article (ID != "").
+// The author of an edited article stays the same.
 func (p *Community) PutArticle(ctx context.Context, uid string, trans string, article *Article) (id string, err error) {
-	htmlId, err := p.uploadHtml(ctx, uid, article.HtmlData)
+	author, err := p.authorizePut(ctx, uid, article.ID)
+	if err != nil {
+		return "", err
+	}
+	// the html belongs to the author, whose medias go with the article
+	htmlId, err := p.uploadHtml(ctx, author, article.HtmlData)
 	if err != nil {
 		htmlId = 0
 	}
+	// articles to be published later are scheduled
+	if article.PublishAt.After(time.Now()) {
+		article.Status = StatusScheduled
+	} else if article.Status == StatusScheduled {
+		return "", errNoPublishTime
+	}
 	// new article
+	article.UId = author
+	article.Tags = strings.Join(ParseTags(article.Tags), ",")
 	if article.ID == "" {
-		sqlStr := "insert into article (title, ctime, mtime, user_id, tags, abstract, cover, content, html_id) values (?, ?, ?, ?, ?, ?, ?, ?,?)"
-		res, err := p.db.Exec(sqlStr, &article.Title, time.Now(), time.Now(), uid, &article.Tags, &article.Abstract, &article.Cover, &article.Content, htmlId)
-		if err != nil {
-			return "", err
-		}
-		idInt, err := res.LastInsertId()
-		return strconv.FormatInt(idInt, 10), nil
+		return p.store.InsertArticle(ctx, article, htmlId)
 	}
 	if trans != "" {
 		// add article except html_id, content (trans)
-		sqlStr := "update article set title=?, mtime=?, ctime=?, tags=?, abstract=?, cover=?, trans_content=?, trans_html_id=? where id=?"
-		_, err = p.db.Exec(sqlStr, &article.Title, time.Now(), time.Now(), &article.Tags, &article.Abstract, &article.Cover, &article.Content, htmlId, &article.ID)
+		err = p.store.UpdateTransArticle(ctx, article, htmlId)
 		return article.ID, err
 	}
 
 	// edit article
-	sqlStr := "update article set title=?, mtime=?, tags=?, abstract=?, cover=?, content=?, html_id=? where id=?"
-	_, err = p.db.Exec(sqlStr, &article.Title, time.Now(), &article.Tags, &article.Abstract, &article.Cover, &article.Content, htmlId, &article.ID)
+	err = p.store.UpdateArticle(ctx, uid, article, htmlId)
 	return article.ID, err
 }
 
-func (p *Community) deleteMedias(ctx context.Context, uid, id string) (err error) {
-	// get htmlIds
-	var htmlIds []string
-	sqlStr := "select html_id from article where id=? and user_id=?"
-	rows, err := p.db.Query(sqlStr, id, uid)
-	defer rows.Close()
-	for rows.Next() {
-		var htmlId string
-		rows.Scan(&htmlId)
-		htmlIds = append(htmlIds, htmlId)
-	}
-	err = p.DelMedias(ctx, uid, htmlIds)
-	return
+// SetArticleStatus publishes, unpublishes or archives the article. Use
+// ScheduleArticle to publish it later.
+func (p *Community) SetArticleStatus(ctx context.Context, uid, id string, status Status) (err error) {
+	if editable, _ := p.CanEditable(ctx, uid, id); !editable {
+		return ErrPermission
+	}
+	if status < StatusDraft || status >= StatusScheduled {
+		return fmt.Errorf("core: invalid article status %d", status)
+	}
+	return p.store.SetArticleStatus(ctx, id, status)
 }
 
-// DeleteArticle delete the article.
-// func (p *Community) DeleteArticle(ctx context.Context, uid, id string) (err error) {
-// 	// get htmlId
-// 	err = p.deleteMedias(ctx, uid ,id)
-// 	if err != nil{
-// 		return
-// 	}
-// 	sqlStr := "delete from article where id=? and user_id=?"
-// 	_, err = p.db.Exec(sqlStr, id, uid)
-// 	return
-// }
-
-// DeleteArticle delete the article.
+// DeleteArticle delete the article. The author and moderators may delete it.
 func (p *Community) DeleteArticle(ctx context.Context, uid, id string) (err error) {
-	// Start a transaction
-	tx, err := p.db.BeginTx(ctx, nil)
-	if err != nil {
-		return
-	}
-	defer func() {
-		if err != nil {
-			// Rollback if there's an error
-			tx.Rollback()
-		}
-	}()
+	return p.deleteArticle(ctx, uid, id, "")
+}
 
-	// Delete medias (calling deleteMedias within the transaction)
-	err = p.deleteMedias(ctx, uid, id)
+// deleteArticle deletes article id by uid, and records its deletion by a
+// moderator with a note in the audit log.
+func (p *Community) deleteArticle(ctx context.Context, uid, id, note string) (err error) {
+	article, _, err := p.store.GetArticle(ctx, id)
 	if err != nil {
-		return err
+		return ErrPermission
 	}
-
-	// Delete article
-	sqlStr := "delete from article where id=? and user_id=?"
-	_, err = tx.ExecContext(ctx, sqlStr, id, uid)
-	if err != nil {
+	if err = p.authorize(ctx, uid, ActionDeleteArticle, article.UId); err != nil {
+		return ErrPermission
+	}
+	// delete the article with its html medias in a transaction
+	if err = p.store.DeleteArticle(ctx, article.UId, id); err != nil {
 		return err
 	}
-
-	// Commit the transaction
-	err = tx.Commit()
-	return
+	p.resolveReports(ctx, KindArticle, id, ReportResolved)
+	if uid != article.UId {
+		p.audit(ctx, uid, AuditDelete, KindArticle, id, note)
+	}
+	return nil
 }
 
-// // DeleteArticles delete the articles by uid.
-// func (p *Community) DeleteArticles(ctx context.Context, uid string) (err error) {
-// 	// get htmlIds
-// 	var htmlIds []string
-// 	sqlStr := "select html_id from article where user_id=?"
-// 	rows, err := p.db.Query(sqlStr, uid)
-// 	defer rows.Close()
-// 	for rows.Next() {
-// 		var htmlId string
-// 		err = rows.Scan(&htmlId)
-// 		if err != nil {
-// 			return
-// 		}
-// 		htmlIds = append(htmlIds, htmlId)
-// 	}
-// 	sqlStr = "delete from article where user_id=?"
-// 	_, err = p.db.Exec(sqlStr, uid)
-// 	// delete the media
-// 	err = p.DelMedias(ctx, uid, htmlIds)
-// 	return
-// }
-
 const (
 	MarkBegin = ""
 	MarkEnd   = "eof"
 )
 
+// listedStatuses are the statuses of articles shown in public listings.
+var listedStatuses = []Status{StatusPublished}
+
 // Articles lists articles from a position.
 func (p *Community) Articles(ctx context.Context, page int, limit int, searchValue string) (items []*ArticleEntry, total int, err error) {
-	total, err = p.getTotal(ctx, searchValue)
+	filter := &ArticleFilter{Search: searchValue, Statuses: listedStatuses}
+	total, err = p.store.CountArticles(ctx, filter)
 	if err != nil || total == 0 {
 		return []*ArticleEntry{}, 0, err
 	}
 
-	sqlStr := "select id, title, ctime, user_id, tags, abstract, cover from article order by ctime desc limit ? offset ?"
-	rows, err := p.db.Query(sqlStr, limit, (page-1)*limit)
-	if searchValue != "" {
-		sqlStr := "select id, title, ctime, user_id, tags, abstract, cover from article where title like ? order by ctime desc limit ? offset ?"
-		rows, err = p.db.Query(sqlStr, "%"+searchValue+"%", limit, (page-1)*limit)
-	}
+	items, err = p.store.ListArticles(ctx, filter, (page-1)*limit, limit)
 	if err != nil {
 		return []*ArticleEntry{}, 0, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		article := &ArticleEntry{}
-		err := rows.Scan(&article.ID, &article.Title, &article.Ctime, &article.UId, &article.Tags, &article.Abstract, &article.Cover)
-		if err != nil {
-			return []*ArticleEntry{}, 0, err
-		}
-		// add author info
-		user, err := p.GetUserById(article.UId)
-		if err != nil {
-			return []*ArticleEntry{}, 0, err
-		}
-		article.User = *user
-
-		items = append(items, article)
-	}
+	p.addAuthors(items)
 	return items, total, nil
 }
 
-// ListArticle lists articles from a position.
-func (p *Community) ListArticle(ctx context.Context, from string, limit int, searchValue string) (items []*ArticleEntry, next string, err error) {
-	if from == MarkBegin {
-		from = "0"
-	} else if from == MarkEnd {
-		return []*ArticleEntry{}, from, nil
+// ListArticle lists articles from a position, which is MarkBegin or a
+// cursor returned as prev or next. The scheduled articles of viewer are
+// listed too.
+func (p *Community) ListArticle(ctx context.Context, from string, limit int, searchValue, viewer string) (items []*ArticleEntry, prev, next string, err error) {
+	filter := &ArticleFilter{Search: searchValue, Statuses: listedStatuses, Viewer: viewer}
+	return p.listArticles(ctx, filter, from, limit)
+}
+
+// listArticles lists articles selected by filter from a position. prev and
+// next are the cursors of the previous and next pages, MarkEnd at the ends.
+func (p *Community) listArticles(ctx context.Context, filter *ArticleFilter, from string, limit int) (items []*ArticleEntry, prev, next string, err error) {
+	if from == MarkEnd {
+		return []*ArticleEntry{}, MarkEnd, MarkEnd, nil
 	}
-	fromInt, err := strconv.Atoi(from)
-	if err != nil {
-		return []*ArticleEntry{}, from, err
+	var c *Cursor
+	if from != MarkBegin {
+		if c, err = ParseCursor(from); err != nil {
+			return []*ArticleEntry{}, MarkEnd, MarkEnd, err
+		}
 	}
-	
-	sqlStr := "select id, title, ctime, user_id, tags, abstract, cover from article where title like ? order by ctime desc limit ? offset ?"
-	rows, err := p.db.Query(sqlStr, "%"+searchValue+"%", limit, fromInt)
+
+	// one more to know if there is a page after this one
+	items, err = p.store.PageArticles(ctx, filter, c, limit+1)
 	if err != nil {
-		return []*ArticleEntry{}, from, err
+		return []*ArticleEntry{}, from, from, err
+	}
+	more := len(items) > limit
+	if more {
+		if c != nil && c.Backward {
+			items = items[1:]
+		} else {
+			items = items[:limit]
+		}
 	}
+	// have no article
+	if len(items) == 0 {
+		return []*ArticleEntry{}, MarkEnd, MarkEnd, io.EOF
+	}
+	p.addAuthors(items)
 
-	defer rows.Close()
-
-	var rowLen int
-	for rows.Next() {
-		article := &ArticleEntry{}
-		err := rows.Scan(&article.ID, &article.Title, &article.Ctime, &article.UId, &article.Tags, &article.Abstract, &article.Cover)
-		if err != nil {
-			return []*ArticleEntry{}, from, err
+	prev, next = MarkEnd, MarkEnd
+	first, last := items[0], items[len(items)-1]
+	switch {
+	case c == nil:
+		if more {
+			next = cursorOf(last, false).String()
 		}
-		// add author info
-		user, err := p.GetUserById(article.UId)
-		if err != nil {
-			return []*ArticleEntry{}, from, err
+	case c.Backward:
+		if more {
+			prev = cursorOf(first, true).String()
+		}
+		next = cursorOf(last, false).String()
+	default:
+		prev = cursorOf(first, true).String()
+		if more {
+			next = cursorOf(last, false).String()
 		}
-		article.User = *user
-
-		items = append(items, article)
-		rowLen++
 	}
-	// have no article
-	if rowLen == 0 {
-		return []*ArticleEntry{}, MarkEnd, io.EOF
+	return items, prev, next, nil
+}
+
+// GetArticlesByUid get articles by user id from a position like ListArticle.
+// Only the author (viewer == uid) sees the articles which are not published.
+func (p *Community) GetArticlesByUid(ctx context.Context, uid, viewer, from string, limit int) (items []*ArticleEntry, prev, next string, err error) {
+	filter := &ArticleFilter{UId: uid, Statuses: listedStatuses}
+	if viewer == uid {
+		filter.Statuses = nil
+		filter.Hidden = true
 	}
-	next = strconv.Itoa(fromInt + rowLen)
-	return items, next, nil
+	return p.listArticles(ctx, filter, from, limit)
 }
 
-// GetArticlesByUid get articles by user id.
-func (p *Community) GetArticlesByUid(ctx context.Context, uid string) (items []*ArticleEntry, err error) {
-	sqlStr := "select id, title, ctime, user_id, tags, abstract, cover from article where user_id = ?"
-	rows, err := p.db.Query(sqlStr, uid)
-	if err != nil {
-		return []*ArticleEntry{}, err
+// addAuthors adds author info to articles.
+func (p *Community) addAuthors(items []*ArticleEntry) {
+	uids := make([]string, len(items))
+	for i, article := range items {
+		uids[i] = article.UId
+	}
+	authors := p.authorsOf(uids)
+	for _, article := range items {
+		article.User = authors[article.UId]
 	}
-	defer rows.Close()
+}
 
-	for rows.Next() {
-		article := &ArticleEntry{}
-		err := rows.Scan(&article.ID, &article.Title, &article.Ctime, &article.UId, &article.Tags, &article.Abstract, &article.Cover)
-		if err != nil {
-			return []*ArticleEntry{}, err
-		}
-		// add author info
-		user, err := p.GetUserById(article.UId)
-		if err != nil {
-			return []*ArticleEntry{}, err
+// authorsOf looks the users uids up in one batch. Users failed to look up
+// are shown as a placeholder.
+func (p *Community) authorsOf(uids []string) map[string]User {
+	users, err := p.GetUsersByIds(uids)
+	if err != nil {
+		p.xLog.Warn("get authors error:", err)
+	}
+	authors := make(map[string]User, len(uids))
+	for _, uid := range uids {
+		if user, ok := users[uid]; ok {
+			author := *user
+			author.Email = "" // authors are shown to everyone
+			authors[uid] = author
+		} else {
+			authors[uid] = placeholderUser(uid)
 		}
-		article.User = *user
-
-		items = append(items, article)
 	}
-	return items, nil
+	return authors
 }
 
 func casdoorConfigInit() *CasdoorConfig {
@@ -462,30 +643,22 @@ func casdoorConfigInit() *CasdoorConfig {
 	}
 }
 
-func (a *Community) RedirectToCasdoor(redirect string) (loginURL string) {
-	// TODO: Check whitelist from referer
-	responseType := "code"
-	scope := "read"
-	state := "casdoor"
-	redirectEncodeURL := url.QueryEscape(redirect)
-
-	loginURL = fmt.Sprintf(
-		"%s/login/oauth/authorize?client_id=%s&response_type=%s&redirect_uri=%s&scope=%s&state=%s",
-		a.casdoorConfig.endPoint,
-		a.casdoorConfig.clientId,
-		responseType,
-		redirectEncodeURL,
-		scope,
-		state,
-	)
-
-	fmt.Println("loginURL", loginURL)
-
-	return loginURL
+// RedirectToCasdoor returns the login page of the identity provider, which
+// calls back the site once the user logs in. The oauth state is a random
+// nonce kept in a cookie of the browser, which SetToken checks, and carries
+// the page to return to, returnTo if it passes SafeRedirect.
+func (a *Community) RedirectToCasdoor(ctx *yap.Context, returnTo string) (loginURL string, err error) {
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	state := encodeState(nonce, a.SafeRedirect(ctx.Request, returnTo))
+	a.setCookie(ctx, stateCookie, state, int(stateTTL/time.Second), false)
+	return a.idp.LoginURL(a.BaseURL(ctx.Request)+"/callback", state), nil
 }
 
 func (a *Community) GetAccessToken(code, state string) (token *oauth2.Token, err error) {
-	token, err = casdoorsdk.GetOAuthToken(code, state)
+	token, err = a.idp.GetOAuthToken(code, state)
 	if err != nil {
 		a.xLog.Error(err)
 
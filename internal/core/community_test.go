@@ -18,18 +18,75 @@ package core
 
 import (
 	"context"
+	"io"
+	"reflect"
+	"strconv"
 	"testing"
 	"time"
 )
 
-func TestPutArticle(t *testing.T) {
-	conf := &Config{}
-	todo := context.TODO()
-	community, err := New(todo, conf)
+// newTestCommunity returns a Community backed by an in-memory sqlite database
+// and an in-memory blob bucket.
+func newTestCommunity(t *testing.T) *Community {
+	conf := &Config{
+		Driver: "sqlite",
+		DSN:    ":memory:",
+		BlobUS: "mem://",
+	}
+	community, err := New(context.TODO(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { community.Close() })
+	community.users = newUserCache(fetchTestUsers, time.Minute)
+	return community
+}
+
+// fetchTestUsers stands for casdoor in tests, where user "404" doesn't exist.
+func fetchTestUsers(uids []string) (map[string]*User, error) {
+	users := make(map[string]*User, len(uids))
+	for _, uid := range uids {
+		if uid != "404" {
+			users[uid] = &User{Id: uid, Name: "user" + uid, Email: uid + "@example.com"}
+		}
+	}
+	return users, nil
+}
 
+// putTestArticle adds an article written by uid and returns its id.
+func putTestArticle(t *testing.T, community *Community, uid, title string) string {
+	article := &Article{
+		ArticleEntry: ArticleEntry{
+			Title: title,
+			Cover: "cover1",
+			Tags:  "tag1",
+		},
+		Content:  "This is a test article.",
+		HtmlData: "<p>This is a test article.</p>",
+	}
+	id, err := community.PutArticle(context.TODO(), uid, "", article)
 	if err != nil {
-		t.Skip(err)
+		t.Fatal(err)
+	}
+	return id
+}
+
+// putTestArticles adds n published articles written by uid, from the oldest
+// to the newest, and returns their ids.
+func putTestArticles(t *testing.T, community *Community, uid string, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = putTestArticle(t, community, uid, "Test Article "+strconv.Itoa(i))
+		if err := community.SetArticleStatus(context.TODO(), uid, ids[i], StatusPublished); err != nil {
+			t.Fatal(err)
+		}
 	}
+	return ids
+}
+
+func TestPutArticle(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
 
 	// test data
 	article := &Article{
@@ -43,7 +100,7 @@ func TestPutArticle(t *testing.T) {
 		},
 		Content: "This is a test article.",
 	}
-	articleUpdate := &Article{
+	articleTrans := &Article{
 		ArticleEntry: ArticleEntry{
 			ID:    "1",
 			Title: "Test Article",
@@ -53,7 +110,19 @@ func TestPutArticle(t *testing.T) {
 			Ctime: time.Now(),
 			Mtime: time.Now(),
 		},
-		Content: "This is a test article.",
+		Content: "This is a translated article.",
+	}
+	articleUpdate := &Article{
+		ArticleEntry: ArticleEntry{
+			ID:    "1",
+			Title: "Test Article Updated",
+			UId:   "1",
+			Cover: "cover2",
+			Tags:  "tag2",
+			Ctime: time.Now(),
+			Mtime: time.Now(),
+		},
+		Content: "This is an updated article.",
 	}
 
 	tests := []struct {
@@ -62,28 +131,56 @@ func TestPutArticle(t *testing.T) {
 		article    *Article
 		expectedID string
 	}{
-		{"1", "", article, "1"},       // insert
-		{"1", "trans", article, "1"},  // insert trans
-		{"1", "", articleUpdate, "1"}, // update
+		{"1", "", article, "1"},           // insert
+		{"1", "trans", articleTrans, "1"}, // insert trans
+		{"1", "", articleUpdate, "1"},     // update
 	}
 
 	for _, tt := range tests {
-		id, _ := community.PutArticle(todo, tt.uid, tt.trans, tt.article)
+		id, err := community.PutArticle(todo, tt.uid, tt.trans, tt.article)
 
+		if err != nil {
+			t.Errorf("PutArticle(%s, %s, %+v) returned error: %v", tt.uid, tt.trans, tt.article, err)
+		}
 		if id != tt.expectedID {
 			t.Errorf("PutArticle(%s, %s, %+v) returned ID %s, expected: %s", tt.uid, tt.trans, tt.article, id, tt.expectedID)
 		}
 	}
-}
 
-func TestCanEditable(t *testing.T) {
-	conf := &Config{}
-	todo := context.TODO()
-	community, err := New(todo, conf)
+	got, _, err := community.store.GetArticle(todo, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != articleUpdate.Title || got.Content != articleUpdate.Content || got.Tags != articleUpdate.Tags {
+		t.Errorf("GetArticle(1) returned %+v, expected: %+v", got, articleUpdate)
+	}
 
+	// an edit by a moderator keeps the article and its html with the author,
+	// so that they are deleted together
+	community.store.SetRole(todo, "3", RoleModerator)
+	edit := &Article{ArticleEntry: ArticleEntry{ID: "1", Title: "Moderated"}, HtmlData: "<p>moderated</p>"}
+	if _, err = community.PutArticle(todo, "3", "", edit); err != nil {
+		t.Fatal(err)
+	}
+	got, htmlId, err := community.store.GetArticle(todo, "1")
 	if err != nil {
-		t.Skip(err)
+		t.Fatal(err)
+	}
+	if owner, err := community.store.GetFileOwner(todo, htmlId); got.UId != "1" || owner != "1" || err != nil {
+		t.Errorf("PutArticle() by a moderator saved author %s and html of %s, %v, expected: 1", got.UId, owner, err)
+	}
+	if err = community.DeleteArticle(todo, "1", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = community.store.GetFileKey(todo, htmlId); err != ErrNotExist {
+		t.Errorf("GetFileKey(%s) of a deleted article returned err: %v, expected: %v", htmlId, err, ErrNotExist)
 	}
+}
+
+func TestCanEditable(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	putTestArticle(t, community, "1", "Test Article")
 
 	// test data
 	tests := []struct {
@@ -94,25 +191,25 @@ func TestCanEditable(t *testing.T) {
 	}{
 		{"1", "1", true, nil},
 		{"2", "1", false, ErrPermission},
+		{"1", "2", false, ErrPermission},
 	}
 
 	for _, tt := range tests {
-		canEdit, _ := community.CanEditable(todo, tt.uid, tt.articleID)
+		canEdit, err := community.CanEditable(todo, tt.uid, tt.articleID)
 
 		if canEdit != tt.expectedEdit {
 			t.Errorf("CanEditable(%s, %s) returned %t, expected: %t", tt.uid, tt.articleID, canEdit, tt.expectedEdit)
 		}
+		if err != tt.expectedError {
+			t.Errorf("CanEditable(%s, %s) returned err is %v, expected: %v", tt.uid, tt.articleID, err, tt.expectedError)
+		}
 	}
 }
 
 func TestArticle(t *testing.T) {
-	conf := &Config{}
 	todo := context.TODO()
-	community, err := New(todo, conf)
-
-	if err != nil {
-		t.Skip(err)
-	}
+	community := newTestCommunity(t)
+	putTestArticle(t, community, "1", "Test Article")
 
 	// test data
 	tests := []struct {
@@ -137,13 +234,8 @@ func TestArticle(t *testing.T) {
 }
 
 func TestSaveHtml(t *testing.T) {
-	conf := &Config{}
 	todo := context.TODO()
-	community, err := New(todo, conf)
-
-	if err != nil {
-		t.Skip(err)
-	}
+	community := newTestCommunity(t)
 
 	// test data
 	tests := []struct {
@@ -154,8 +246,8 @@ func TestSaveHtml(t *testing.T) {
 		expectedArticleID string
 		expectedError     error
 	}{
-		{"1", "<html><body><p>Hello, World!<p></body></html>", "##Hello, World!", "", "15", nil},
-		{"1", "<html><body><p>Hello, World!<p></body></html>", "##Hello, World!", "15", "15", nil},
+		{"1", "<html><body><p>Hello, World!<p></body></html>", "##Hello, World!", "", "1", nil},
+		{"1", "<html><body><p>Hello, World!<p></body></html>", "##Hello, World!", "1", "1", nil},
 	}
 
 	for _, tt := range tests {
@@ -171,50 +263,51 @@ func TestSaveHtml(t *testing.T) {
 }
 
 func TestListArticle(t *testing.T) {
-	conf := &Config{}
 	todo := context.TODO()
-	community, err := New(todo, conf)
-
-	if err != nil {
-		t.Skip(err)
-	}
+	community := newTestCommunity(t)
+	ids := putTestArticles(t, community, "1", 6)
 
+	// each page starts from the next of the one before
 	tests := []struct {
-		from         string
-		limit        int
-		expectedLen  int
-		expectedNext string
+		limit       int
+		expectedLen int
+		expectedEnd bool
 	}{
-		{MarkBegin, 5, 5, "5"},
-		{"5", 5, 1, "6"},
-		{"6", 5, 0, MarkEnd},
+		{5, 5, false},
+		{5, 1, true},
+		{5, 0, true},
 	}
 
+	from := MarkBegin
 	for _, tt := range tests {
-		items, next, err := community.ListArticle(todo, tt.from, tt.limit, "")
+		items, _, next, err := community.ListArticle(todo, from, tt.limit, "", "")
 
 		if err != nil {
-			t.Errorf("ListArticle(%s, %d) returned error: %v", tt.from, tt.limit, err)
+			t.Errorf("ListArticle(%s, %d) returned error: %v", from, tt.limit, err)
 		}
 
 		if len(items) != tt.expectedLen {
-			t.Errorf("ListArticle(%s, %d) returned %d items, expected %d", tt.from, tt.limit, len(items), tt.expectedLen)
+			t.Errorf("ListArticle(%s, %d) returned %d items, expected %d", from, tt.limit, len(items), tt.expectedLen)
 		}
 
-		if next != tt.expectedNext {
-			t.Errorf("ListArticle(%s, %d) returned next %s, expected %s", tt.from, tt.limit, next, tt.expectedNext)
+		if (next == MarkEnd) != tt.expectedEnd {
+			t.Errorf("ListArticle(%s, %d) returned next %s, expected the end: %t", from, tt.limit, next, tt.expectedEnd)
 		}
+		from = next
+	}
+
+	// there is nothing after the oldest article
+	oldest, _, _ := community.store.GetArticle(todo, ids[0])
+	from = cursorOf(&oldest.ArticleEntry, false).String()
+	if items, _, next, err := community.ListArticle(todo, from, 5, "", ""); err != io.EOF || len(items) != 0 || next != MarkEnd {
+		t.Errorf("ListArticle(%s, 5) returned %d items, next %s, err: %v, expected: %v", from, len(items), next, err, io.EOF)
 	}
 }
 
 func TestDeleteArticle(t *testing.T) {
-	conf := &Config{}
 	todo := context.TODO()
-	community, err := New(todo, conf)
-
-	if err != nil {
-		t.Skip(err)
-	}
+	community := newTestCommunity(t)
+	putTestArticle(t, community, "1", "Test Article")
 
 	// test data
 	tests := []struct {
@@ -222,8 +315,9 @@ func TestDeleteArticle(t *testing.T) {
 		articleID   string
 		expectedErr error
 	}{
-		{"22", "2", ErrPermission}, // no permission
+		{"22", "1", ErrPermission}, // no permission
 		{"1", "1", nil},
+		{"1", "1", ErrPermission}, // deleted
 	}
 
 	for _, tt := range tests {
@@ -236,13 +330,9 @@ func TestDeleteArticle(t *testing.T) {
 }
 
 func TestArticles(t *testing.T) {
-	conf := &Config{}
 	todo := context.TODO()
-	community, err := New(todo, conf)
-
-	if err != nil {
-		t.Skip(err)
-	}
+	community := newTestCommunity(t)
+	putTestArticles(t, community, "1", 10)
 
 	// test data
 	tests := []struct {
@@ -256,8 +346,11 @@ func TestArticles(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		_, total, _ := community.Articles(todo, tt.page, tt.limit, tt.searchValue)
+		_, total, err := community.Articles(todo, tt.page, tt.limit, tt.searchValue)
 
+		if err != nil {
+			t.Errorf("Articles(%d, %d, %s) returned err: %v", tt.page, tt.limit, tt.searchValue, err)
+		}
 		if total != tt.expectedTotal {
 			t.Errorf("Articles(%d, %d, %s) returned total: %d, expected: %d", tt.page, tt.limit, tt.searchValue, total, tt.expectedTotal)
 		}
@@ -265,27 +358,256 @@ func TestArticles(t *testing.T) {
 }
 
 func TestGetArticlesByUid(t *testing.T) {
-	conf := &Config{}
 	todo := context.TODO()
-	community, err := New(todo, conf)
-
-	if err != nil {
-		t.Skip(err)
-	}
+	community := newTestCommunity(t)
+	putTestArticles(t, community, "1", 1)
 
 	// test data
 	tests := []struct {
 		uid           string
+		viewer        string
 		expectedError error
 	}{
-		{"1", nil},
+		{"1", "", nil},
+		{"1", "1", nil},
+		{"2", "", io.EOF},
 	}
 
 	for _, tt := range tests {
-		_, err := community.GetArticlesByUid(todo, tt.uid)
+		_, _, _, err := community.GetArticlesByUid(todo, tt.uid, tt.viewer, MarkBegin, 10)
 
 		if err != tt.expectedError {
-			t.Errorf("GetArticlesByUid(%s) returned err: %v, expected: %v", tt.uid, err, tt.expectedError)
+			t.Errorf("GetArticlesByUid(%s, %s) returned err: %v, expected: %v", tt.uid, tt.viewer, err, tt.expectedError)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Status
+		isErr    bool
+	}{
+		{"", StatusPublished, false},
+		{"draft", StatusDraft, false},
+		{"published", StatusPublished, false},
+		{"unlisted", StatusUnlisted, false},
+		{"archived", StatusArchived, false},
+		{"scheduled", StatusScheduled, false},
+		{"deleted", 0, true},
+	}
+	for _, tt := range tests {
+		status, err := ParseStatus(tt.name)
+		if (err != nil) != tt.isErr || status != tt.expected {
+			t.Errorf("ParseStatus(%s) returned %v, %v, expected: %v", tt.name, status, err, tt.expected)
+		}
+		if err == nil && tt.name != "" && status.String() != tt.name {
+			t.Errorf("%v.String() returned %s, expected: %s", status, status.String(), tt.name)
+		}
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	tests := []struct {
+		status   Status
+		uid      string
+		expected bool
+	}{
+		{StatusDraft, "1", true},
+		{StatusDraft, "2", false},
+		{StatusDraft, "", false},
+		{StatusPublished, "", true},
+		{StatusUnlisted, "2", true},
+		{StatusArchived, "", true},
+		{StatusScheduled, "1", true},
+		{StatusScheduled, "", false},
+	}
+	for _, tt := range tests {
+		article := &ArticleEntry{UId: "1", Status: tt.status}
+		if got := article.VisibleTo(tt.uid); got != tt.expected {
+			t.Errorf("VisibleTo(%s) of %v article returned %t, expected: %t", tt.uid, tt.status, got, tt.expected)
+		}
+	}
+}
+
+func TestSetArticleStatus(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	id := putTestArticle(t, community, "1", "Test Article")
+
+	// drafts are not listed
+	if _, total, _ := community.Articles(todo, 1, 10, ""); total != 0 {
+		t.Errorf("Articles() returned total: %d, expected: 0", total)
+	}
+
+	tests := []struct {
+		uid         string
+		status      Status
+		expectedErr error
+	}{
+		{"2", StatusPublished, ErrPermission},
+		{"1", StatusPublished, nil},
+		{"1", StatusArchived, nil},
+		{"1", StatusDraft, nil},
+	}
+	for _, tt := range tests {
+		err := community.SetArticleStatus(todo, tt.uid, id, tt.status)
+		if err != tt.expectedErr {
+			t.Errorf("SetArticleStatus(%s, %s, %v) returned err: %v, expected: %v", tt.uid, id, tt.status, err, tt.expectedErr)
+			continue
+		}
+		if err == nil {
+			article, _, _ := community.store.GetArticle(todo, id)
+			if article.Status != tt.status {
+				t.Errorf("SetArticleStatus(%s, %s, %v) left status %v", tt.uid, id, tt.status, article.Status)
+			}
+		}
+	}
+	if err := community.SetArticleStatus(todo, "1", id, Status(10)); err == nil {
+		t.Errorf("SetArticleStatus(1, %s, 10) returned nil error", id)
+	}
+}
+
+func TestGetVisibleMediaUrl(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	id := putTestArticle(t, community, "1", "Test Article")
+	_, draftHtml, _ := community.store.GetArticle(todo, id)
+	image, err := community.SaveMedia(todo, "1", []byte("image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the html of drafts is visible to their author only
+	if _, err := community.GetVisibleMediaUrl(todo, "2", draftHtml); err != ErrNotExist {
+		t.Errorf("GetVisibleMediaUrl(2, %s) of a draft returned err: %v, expected: %v", draftHtml, err, ErrNotExist)
+	}
+	if _, err := community.GetVisibleMediaUrl(todo, "1", draftHtml); err != nil {
+		t.Errorf("GetVisibleMediaUrl(1, %s) of a draft returned err: %v", draftHtml, err)
+	}
+	if _, err := community.TransHtmlUrl(todo, "2", id); err != ErrNotExist {
+		t.Errorf("TransHtmlUrl(2, %s) of a draft returned err: %v, expected: %v", id, err, ErrNotExist)
+	}
+	// images are of no article
+	if _, err := community.GetVisibleMediaUrl(todo, "", strconv.FormatInt(image, 10)); err != nil {
+		t.Errorf("GetVisibleMediaUrl() of an image returned err: %v", err)
+	}
+
+	community.SetArticleStatus(todo, "1", id, StatusPublished)
+	article, _, _ := community.store.GetArticle(todo, id)
+	article.Content = "This is an edit."
+	article.HtmlData = "<p>This is an edit.</p>"
+	if _, err := community.PutArticle(todo, "1", "", article); err != nil {
+		t.Fatal(err)
+	}
+	_, html, _ := community.store.GetArticle(todo, id)
+	if _, err := community.GetVisibleMediaUrl(todo, "", html); err != nil {
+		t.Errorf("GetVisibleMediaUrl(%s) of a published article returned err: %v", html, err)
+	}
+	// the html of old revisions is visible to the editors only
+	if _, err := community.GetVisibleMediaUrl(todo, "2", draftHtml); err != ErrNotExist {
+		t.Errorf("GetVisibleMediaUrl(2, %s) of an old revision returned err: %v, expected: %v", draftHtml, err, ErrNotExist)
+	}
+	if _, err := community.GetVisibleMediaUrl(todo, "1", draftHtml); err != nil {
+		t.Errorf("GetVisibleMediaUrl(1, %s) of an old revision returned err: %v", draftHtml, err)
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	id := putTestArticle(t, community, "1", "Test Article")
+
+	article, _, _ := community.store.GetArticle(todo, id)
+	article.Title = "Test Article Updated"
+	article.Content = "This is a bad edit."
+	if _, err := community.PutArticle(todo, "1", "", article); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := community.ArticleRevisions(todo, "2", id); err != ErrPermission {
+		t.Errorf("ArticleRevisions(2, %s) returned err: %v, expected: %v", id, err, ErrPermission)
+	}
+	revs, err := community.ArticleRevisions(todo, "1", id)
+	if err != nil || len(revs) != 2 {
+		t.Fatalf("ArticleRevisions(1, %s) returned %d revisions, %v, expected: 2", id, len(revs), err)
+	}
+	first := revs[1].ID
+
+	diff, err := community.RevisionDiff(todo, "1", id, first, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DiffLine{{DiffDelete, "This is a test article."}, {DiffInsert, "This is a bad edit."}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("RevisionDiff(%s) returned %v, expected: %v", first, diff, want)
+	}
+	if _, err = community.RevisionDiff(todo, "1", id, "100", ""); err != ErrNotExist {
+		t.Errorf("RevisionDiff(100) returned err: %v, expected: %v", err, ErrNotExist)
+	}
+
+	if err = community.RestoreRevision(todo, "2", id, first); err != ErrPermission {
+		t.Errorf("RestoreRevision(2, %s, %s) returned err: %v, expected: %v", id, first, err, ErrPermission)
+	}
+	if err = community.RestoreRevision(todo, "1", id, first); err != nil {
+		t.Fatal(err)
+	}
+	article, _, _ = community.store.GetArticle(todo, id)
+	if article.Title != "Test Article" || article.Content != "This is a test article." {
+		t.Errorf("RestoreRevision(%s) left title %s, content %s", first, article.Title, article.Content)
+	}
+	if revs, _ = community.ArticleRevisions(todo, "1", id); len(revs) != 3 {
+		t.Errorf("ArticleRevisions() returned %d revisions after restore, expected: 3", len(revs))
+	}
+}
+
+func TestScheduleArticle(t *testing.T) {
+	todo := context.TODO()
+	conf := &Config{
+		Driver:           "sqlite",
+		DSN:              ":memory:",
+		BlobUS:           "mem://",
+		ScheduleInterval: 10 * time.Millisecond,
+	}
+	community, err := New(todo, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer community.Close()
+	community.users = newUserCache(fetchTestUsers, time.Minute)
+
+	// a future publish time schedules the article
+	article := &Article{ArticleEntry: ArticleEntry{Title: "Test Article", PublishAt: time.Now().Add(time.Hour)}}
+	id, err := community.PutArticle(todo, "1", "", article)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := community.store.GetArticle(todo, id); got.Status != StatusScheduled || got.VisibleTo("2") || !got.VisibleTo("1") {
+		t.Errorf("PutArticle() saved status %v, expected: %v", got.Status, StatusScheduled)
+	}
+	if _, err = community.PutArticle(todo, "1", "", &Article{ArticleEntry: ArticleEntry{Status: StatusScheduled}}); err == nil {
+		t.Errorf("PutArticle() of a scheduled article without publish time returned nil error")
+	}
+	if err = community.SetArticleStatus(todo, "1", id, StatusScheduled); err == nil {
+		t.Errorf("SetArticleStatus(%v) returned nil error", StatusScheduled)
+	}
+
+	if err = community.ScheduleArticle(todo, "2", id, time.Now()); err != ErrPermission {
+		t.Errorf("ScheduleArticle(2, %s) returned err: %v, expected: %v", id, err, ErrPermission)
+	}
+	if err = community.ScheduleArticle(todo, "1", id, time.Now().Add(20*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	// the scheduler publishes the article once it is due
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got, _, _ := community.store.GetArticle(todo, id)
+		if got.Status == StatusPublished {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("scheduled article %s is %v, expected: %v", id, got.Status, StatusPublished)
 		}
+		time.Sleep(10 * time.Millisecond)
 	}
 }
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/goplus/yap"
+)
+
+const (
+	// csrfCookie is the cookie handing the CSRF token of the session to the
+	// scripts of the pages, which send it back in csrfHeader.
+	csrfCookie = "csrf"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// csrfToken returns the CSRF token of session id, which is signed by the
+// secret of the community so that it can't be forged for a session.
+func (p *Community) csrfToken(id string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte("csrf:" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkCSRF reports whether a request may change the state of the session
+// it's made in: safe methods may, and other requests if they carry the
+// CSRF token of the session in csrfHeader or in the csrf param.
+func (p *Community) checkCSRF(ctx *yap.Context) bool {
+	switch ctx.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	session, err := ctx.Request.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+	token := ctx.Request.Header.Get(csrfHeader)
+	if token == "" {
+		token = ctx.Request.FormValue("csrf")
+	}
+	return hmac.Equal([]byte(token), []byte(p.csrfToken(session.Value)))
+}
+
+// ensureCSRFCookie hands the CSRF token of the session of a request to the
+// page again if the page lacks it, as for sessions started before CSRF
+// tokens.
+func (p *Community) ensureCSRFCookie(ctx *yap.Context) {
+	session, err := ctx.Request.Cookie(sessionCookie)
+	if err != nil {
+		return
+	}
+	token := p.csrfToken(session.Value)
+	if cookie, err := ctx.Request.Cookie(csrfCookie); err == nil && cookie.Value == token {
+		return
+	}
+	p.setCookie(ctx, csrfCookie, token, int(sessionTTL/time.Second), true)
+}
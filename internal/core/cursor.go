@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidCursor = errors.New("core: invalid cursor")
+
+// Cursor is a position in a listing of articles, which are ordered newest
+// first by Ctime then ID, or best first by Score then Ctime and ID for search
+// results. A page starting at a cursor stays the same when articles are
+// added before it, except for search results whose scores change as articles
+// are indexed. Top-level comments are paged newest first the same way.
+type Cursor struct {
+	Score    float64 // of the search result
+	Ctime    time.Time
+	ID       int64
+	Backward bool // the page lists the articles before the position
+}
+
+// cursorOf returns the cursor at article, which pages forward or backward.
+func cursorOf(article *ArticleEntry, backward bool) *Cursor {
+	id, _ := strconv.ParseInt(article.ID, 10, 64)
+	return &Cursor{Score: article.score, Ctime: article.Ctime, ID: id, Backward: backward}
+}
+
+// String encodes c as an opaque string.
+func (c *Cursor) String() string {
+	dir := "f"
+	if c.Backward {
+		dir = "b"
+	}
+	s := strings.Join([]string{
+		dir,
+		c.Ctime.Format(time.RFC3339Nano),
+		strconv.FormatInt(c.ID, 10),
+		strconv.FormatFloat(c.Score, 'g', -1, 64),
+	}, "|")
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// ParseCursor decodes a cursor encoded by Cursor.String.
+func ParseCursor(s string) (c *Cursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	fields := strings.Split(string(data), "|")
+	if len(fields) != 4 || (fields[0] != "f" && fields[0] != "b") {
+		return nil, errInvalidCursor
+	}
+	c = &Cursor{Backward: fields[0] == "b"}
+	if c.Ctime, err = time.Parse(time.RFC3339Nano, fields[1]); err != nil {
+		return nil, errInvalidCursor
+	}
+	if c.ID, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return nil, errInvalidCursor
+	}
+	if c.Score, err = strconv.ParseFloat(fields[3], 64); err != nil {
+		return nil, errInvalidCursor
+	}
+	return c, nil
+}
+
+// where returns the condition selecting the articles after c in the listing
+// order, or before c if c.Backward.
+func (c *Cursor) where(search bool) (cond string, args []any) {
+	columns := []string{"ctime", "id"}
+	values := []any{c.Ctime.UTC(), c.ID}
+	if search {
+		columns = append([]string{"r.score"}, columns...)
+		values = append([]any{c.Score}, values...)
+	}
+	op := " < ?"
+	if c.Backward {
+		op = " > ?"
+	}
+	// (k1 < ? or (k1 = ? and (k2 < ? or (k2 = ? and k3 < ?))))
+	last := len(columns) - 1
+	cond = columns[last] + op
+	args = []any{values[last]}
+	for i := last - 1; i >= 0; i-- {
+		cond = "(" + columns[i] + op + " or (" + columns[i] + " = ? and " + cond + "))"
+		args = append([]any{values[i], values[i]}, args...)
+	}
+	return
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	ctime := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("CST", 8*3600))
+	for _, c := range []*Cursor{
+		{Ctime: ctime, ID: 10},
+		{Ctime: ctime, ID: 10, Backward: true},
+		{Score: 12.345678, Ctime: ctime, ID: 1},
+	} {
+		got, err := ParseCursor(c.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != *c && (!got.Ctime.Equal(c.Ctime) || got.String() != c.String()) {
+			t.Errorf("ParseCursor(%s) = %+v, expected: %+v", c, got, c)
+		}
+	}
+	for _, s := range []string{"0", "eof", "bm9uZQ", (&Cursor{}).String()[1:]} {
+		if _, err := ParseCursor(s); err == nil {
+			t.Errorf("ParseCursor(%q) returned nil error", s)
+		}
+	}
+}
+
+func TestStorePageArticles(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+
+	// 7 articles, two of them created at the same time
+	ctime := time.Now()
+	for i := 0; i < 7; i++ {
+		article := &Article{ArticleEntry: ArticleEntry{Title: "yap", UId: "1", Status: StatusPublished}, Content: "yap"}
+		id, err := store.InsertArticle(todo, article, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != 3 {
+			ctime = ctime.Add(time.Second)
+		}
+		store.(*sqlStore).db.Exec("update article set ctime=? where id=?", ctime.UTC(), id)
+	}
+
+	// page forward then backward
+	for _, search := range []string{"", "yap"} {
+		filter := &ArticleFilter{Search: search, Statuses: []Status{StatusPublished}}
+		var c *Cursor
+		var pages [][]string
+		for {
+			items, err := store.PageArticles(todo, filter, c, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) == 0 {
+				break
+			}
+			pages = append(pages, ids(items))
+			c = cursorOf(items[len(items)-1], false)
+		}
+		want := [][]string{{"7", "6", "5"}, {"4", "3", "2"}, {"1"}}
+		if !reflect.DeepEqual(pages, want) {
+			t.Errorf("PageArticles(search %q) forward returned %v, expected: %v", search, pages, want)
+		}
+
+		items, _ := store.PageArticles(todo, filter, nil, 3)
+		next, _ := store.PageArticles(todo, filter, cursorOf(items[2], false), 3)
+		prev, _ := store.PageArticles(todo, filter, cursorOf(next[0], true), 3)
+		if got := ids(prev); !reflect.DeepEqual(got, want[0]) {
+			t.Errorf("PageArticles(search %q) backward returned %v, expected: %v", search, got, want[0])
+		}
+	}
+
+	// a new article doesn't shift the pages
+	filter := &ArticleFilter{Statuses: []Status{StatusPublished}}
+	items, _ := store.PageArticles(todo, filter, nil, 3)
+	if _, err := store.InsertArticle(todo, &Article{ArticleEntry: ArticleEntry{Title: "new", Status: StatusPublished}}, 0); err != nil {
+		t.Fatal(err)
+	}
+	next, _ := store.PageArticles(todo, filter, cursorOf(items[2], false), 3)
+	if got, want := ids(next), []string{"4", "3", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PageArticles() after insert returned %v, expected: %v", got, want)
+	}
+}
+
+func ids(items []*ArticleEntry) (ret []string) {
+	for _, item := range items {
+		ret = append(ret, item.ID)
+	}
+	return
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import "strings"
+
+// DiffOp tells how a line changed.
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = " "
+	DiffDelete DiffOp = "-"
+	DiffInsert DiffOp = "+"
+)
+
+// DiffLine is a line of a line-level diff.
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// DiffLines returns the line-level diff turning a into b, in the order of a
+// unified diff.
+func DiffLines(a, b string) []DiffLine {
+	x, y := splitLines(a), splitLines(b)
+
+	// common prefix and suffix
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+
+	diff := make([]DiffLine, 0, len(x)+len(y)-pre-suf)
+	for _, line := range x[:pre] {
+		diff = append(diff, DiffLine{DiffEqual, line})
+	}
+	diff = diffLCS(diff, x[pre:len(x)-suf], y[pre:len(y)-suf])
+	for _, line := range x[len(x)-suf:] {
+		diff = append(diff, DiffLine{DiffEqual, line})
+	}
+	return diff
+}
+
+// maxDiffCells limits the size of the table of diffLCS, which takes
+// len(x)*len(y) ints, so that large revisions can't use up the memory.
+const maxDiffCells = 1 << 20
+
+// diffLCS appends the diff of x and y, based on their longest common
+// subsequence, to diff. If x and y are too large to compare, the whole text
+// is replaced instead.
+func diffLCS(diff []DiffLine, x, y []string) []DiffLine {
+	if (len(x)+1)*(len(y)+1) > maxDiffCells {
+		for _, line := range x {
+			diff = append(diff, DiffLine{DiffDelete, line})
+		}
+		for _, line := range y {
+			diff = append(diff, DiffLine{DiffInsert, line})
+		}
+		return diff
+	}
+	// lcs[i][j] is the length of the LCS of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			diff = append(diff, DiffLine{DiffEqual, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{DiffDelete, x[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{DiffInsert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		diff = append(diff, DiffLine{DiffDelete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		diff = append(diff, DiffLine{DiffInsert, y[j]})
+	}
+	return diff
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// formatDiff joins diff as the lines of a unified diff.
+func formatDiff(diff []DiffLine) string {
+	var b strings.Builder
+	for _, line := range diff {
+		b.WriteString(string(line.Op) + line.Text + "\n")
+	}
+	return b.String()
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"", "", ""},
+		{"a\nb\n", "a\nb\n", " a\n b\n"},
+		{"", "a\nb", "+a\n+b\n"},
+		{"a\nb", "", "-a\n-b\n"},
+		{"a\nb\nc", "a\nc", " a\n-b\n c\n"},
+		{"a\nc", "a\nb\nc", " a\n+b\n c\n"},
+		{"a\nb\nc", "a\nx\nc", " a\n-b\n+x\n c\n"},
+		{"# Title\nx\ny\nz\nend", "# Title\ny\nw\nz\nend", " # Title\n-x\n y\n+w\n z\n end\n"},
+	}
+	for _, tt := range tests {
+		if got := formatDiff(DiffLines(tt.a, tt.b)); got != tt.expected {
+			t.Errorf("DiffLines(%q, %q) = %q, expected: %q", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	// every other line changed, so the texts are too large to compare
+	var a, b strings.Builder
+	for i := 0; i < 2000; i++ {
+		line := strconv.Itoa(i)
+		a.WriteString("a" + line + "\n")
+		if i%2 == 0 {
+			b.WriteString("b" + line + "\n")
+		} else {
+			b.WriteString("a" + line + "\n")
+		}
+	}
+	diff := DiffLines(a.String(), b.String())
+	// the last line is common
+	if len(diff) != 3999 || diff[0].Op != DiffDelete || diff[1998].Op != DiffDelete || diff[1999].Op != DiffInsert {
+		t.Errorf("DiffLines() of large texts returned %d lines, expected the whole text replaced", len(diff))
+	}
+}
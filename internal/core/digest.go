@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	digestPeriod = 7 * 24 * time.Hour
+	digestSize   = 10  // articles in a digest
+	digestBatch  = 100 // recipients looked up at once
+)
+
+// digestMail is the data of the digest templates.
+type digestMail struct {
+	SiteURL   string
+	Recipient User
+	Articles  []*ArticleEntry
+}
+
+// SendDigests emails the articles with the most engagement over the week
+// before now to the users subscribed to the digest who haven't received one
+// for a week. It returns the number of digests sent, and stops at the first
+// failure to send.
+func (p *Community) SendDigests(ctx context.Context, now time.Time) (n int, err error) {
+	if p.mailer == nil {
+		return
+	}
+	since := now.Add(-digestPeriod)
+	articles, err := p.store.TopArticles(ctx, since, digestSize)
+	if err != nil || len(articles) == 0 {
+		return
+	}
+	p.addAuthors(articles)
+	for {
+		uids, err := p.store.ListDigestDue(ctx, since, digestBatch)
+		if err != nil || len(uids) == 0 {
+			return n, err
+		}
+		users, _ := p.users.get(uids)
+		for _, uid := range uids {
+			// users without email are skipped until the next week
+			if user := users[uid]; user != nil && user.Email != "" {
+				msg, err := mailTemplates["digest"].render(user, &digestMail{p.siteURL, *user, articles})
+				if err != nil {
+					return n, err
+				}
+				if err = p.mailer.Send(ctx, msg); err != nil {
+					return n, err
+				}
+				n++
+			}
+			if err = p.store.SetDigestSent(ctx, uid, now); err != nil {
+				return n, err
+			}
+		}
+	}
+}
+
+// runDigester sends the due digests every interval until ctx is done.
+func (p *Community) runDigester(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := p.SendDigests(ctx, now)
+			if err != nil {
+				p.xLog.Error("send digests error:", err)
+			}
+			if n > 0 {
+				p.xLog.Info("sent digests:", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// viewWindow is how long the views of an article by the same viewer count
+// as one.
+const viewWindow = 30 * time.Minute
+
+// LikeArticle likes or unlikes (like is false) article id by uid, and
+// returns its number of likes.
+func (p *Community) LikeArticle(ctx context.Context, uid, id string, like bool) (likes int, err error) {
+	article, err := p.canEngage(ctx, uid, id)
+	if err != nil {
+		return
+	}
+	if likes, err = p.store.SetLike(ctx, uid, id, like); err != nil || !like {
+		return
+	}
+	p.notify(ctx, &Notification{UId: article.UId, ActorID: uid, Kind: NotifyLike, ArticleID: id})
+	return
+}
+
+// BookmarkArticle bookmarks or unbookmarks (bookmark is false) article id by
+// uid, and returns its number of bookmarks.
+func (p *Community) BookmarkArticle(ctx context.Context, uid, id string, bookmark bool) (bookmarks int, err error) {
+	if _, err = p.canEngage(ctx, uid, id); err != nil {
+		return
+	}
+	return p.store.SetBookmark(ctx, uid, id, bookmark)
+}
+
+// canEngage checks that the signed in user uid can read article id and is
+// not banned, and returns the article.
+func (p *Community) canEngage(ctx context.Context, uid, id string) (*Article, error) {
+	if uid == "" {
+		return nil, ErrPermission
+	}
+	article, _, err := p.store.GetArticle(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !article.VisibleTo(uid) {
+		return nil, ErrNotExist
+	}
+	if err = p.authorize(ctx, uid, ActionEngage, article.UId); err != nil {
+		return nil, err
+	}
+	return article, nil
+}
+
+// Engagement reports whether uid liked and bookmarked article id.
+func (p *Community) Engagement(ctx context.Context, uid, id string) (liked, bookmarked bool, err error) {
+	if uid == "" {
+		return
+	}
+	return p.store.Engagement(ctx, uid, id)
+}
+
+// Bookmarks lists the articles bookmarked by uid from a position like
+// ListArticle. Drafts bookmarked before they were unpublished are left out.
+func (p *Community) Bookmarks(ctx context.Context, uid, from string, limit int) (items []*ArticleEntry, prev, next string, err error) {
+	filter := &ArticleFilter{
+		BookmarkedBy: uid,
+		Statuses:     []Status{StatusPublished, StatusUnlisted, StatusArchived},
+	}
+	return p.listArticles(ctx, filter, from, limit)
+}
+
+// ViewArticle counts a view of article id by viewer, a user id or a client
+// address. Views are counted once per viewer in half an hour, and are kept
+// in memory until they are flushed to the store every ViewFlushInterval.
+func (p *Community) ViewArticle(id, viewer string) {
+	p.views.add(id, viewer, time.Now())
+}
+
+// flushViews saves the views counted in memory.
+func (p *Community) flushViews(ctx context.Context) error {
+	views := p.views.take(time.Now())
+	if len(views) == 0 {
+		return nil
+	}
+	if err := p.store.AddViews(ctx, views); err != nil {
+		p.views.restore(views)
+		return err
+	}
+	return nil
+}
+
+// runViewFlusher flushes the views every interval until ctx is done.
+func (p *Community) runViewFlusher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.flushViews(ctx); err != nil {
+				p.xLog.Error("flush views error:", err)
+			}
+		}
+	}
+}
+
+type viewKey struct {
+	id, viewer string
+}
+
+// viewCounter buffers the views of articles, deduplicated by viewer.
+type viewCounter struct {
+	mu      sync.Mutex
+	seen    map[viewKey]time.Time // when a viewer was last counted
+	pending map[string]int64      // views by article id
+}
+
+func newViewCounter() *viewCounter {
+	return &viewCounter{seen: make(map[viewKey]time.Time), pending: make(map[string]int64)}
+}
+
+// add counts a view of article id by viewer at now, and reports whether it
+// counted.
+func (c *viewCounter) add(id, viewer string, now time.Time) bool {
+	key := viewKey{id, viewer}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.seen[key]; ok && now.Sub(last) < viewWindow {
+		return false
+	}
+	c.seen[key] = now
+	c.pending[id]++
+	return true
+}
+
+// take returns the pending views and forgets the viewers seen before the
+// window.
+func (c *viewCounter) take(now time.Time) map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, last := range c.seen {
+		if now.Sub(last) >= viewWindow {
+			delete(c.seen, key)
+		}
+	}
+	views := c.pending
+	c.pending = make(map[string]int64)
+	return views
+}
+
+// restore puts views failed to save back.
+func (c *viewCounter) restore(views map[string]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, n := range views {
+		c.pending[id] += n
+	}
+}
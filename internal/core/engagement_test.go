@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLikeArticle(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	id := putTestArticle(t, community, "1", "Test")
+	community.SetArticleStatus(todo, "1", id, StatusPublished)
+	draft := putTestArticle(t, community, "1", "Draft")
+
+	tests := []struct {
+		uid, id       string
+		like          bool
+		expectedLikes int
+		expectedErr   error
+	}{
+		{"2", id, true, 1, nil},
+		{"2", id, true, 1, nil}, // no-op
+		{"3", id, true, 2, nil},
+		{"2", id, false, 1, nil},
+		{"2", id, false, 1, nil}, // no-op
+		{"", id, true, 0, ErrPermission},
+		{"2", draft, true, 0, ErrNotExist},
+		{"2", "100", true, 0, ErrNotExist},
+	}
+	for _, tt := range tests {
+		likes, err := community.LikeArticle(todo, tt.uid, tt.id, tt.like)
+		if likes != tt.expectedLikes || err != tt.expectedErr {
+			t.Errorf("LikeArticle(%s, %s, %v) returned %d, %v, expected: %d, %v", tt.uid, tt.id, tt.like, likes, err, tt.expectedLikes, tt.expectedErr)
+		}
+	}
+
+	if bookmarks, err := community.BookmarkArticle(todo, "2", id, true); bookmarks != 1 || err != nil {
+		t.Errorf("BookmarkArticle() returned %d, %v, expected: 1", bookmarks, err)
+	}
+	for _, tt := range []struct {
+		uid               string
+		liked, bookmarked bool
+	}{
+		{"2", false, true},
+		{"3", true, false},
+		{"", false, false},
+	} {
+		liked, bookmarked, err := community.Engagement(todo, tt.uid, id)
+		if liked != tt.liked || bookmarked != tt.bookmarked || err != nil {
+			t.Errorf("Engagement(%s) returned %v, %v, %v, expected: %v, %v", tt.uid, liked, bookmarked, err, tt.liked, tt.bookmarked)
+		}
+	}
+	article, _ := community.Article(todo, id)
+	if article.Likes != 1 || article.Bookmarks != 1 {
+		t.Errorf("Article() returned %d likes and %d bookmarks, expected: 1 and 1", article.Likes, article.Bookmarks)
+	}
+}
+
+func TestLikeArticleConcurrently(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	id := putTestArticle(t, community, "1", "Test")
+	community.SetArticleStatus(todo, "1", id, StatusPublished)
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(uid string) {
+			defer wg.Done()
+			if _, err := community.LikeArticle(todo, uid, id, true); err != nil {
+				t.Errorf("LikeArticle(%s) returned %v", uid, err)
+			}
+			if _, err := community.BookmarkArticle(todo, uid, id, true); err != nil {
+				t.Errorf("BookmarkArticle(%s) returned %v", uid, err)
+			}
+		}(strconv.Itoa(i + 2))
+	}
+	wg.Wait()
+	for i := 0; i < n; i += 2 {
+		wg.Add(1)
+		go func(uid string) {
+			defer wg.Done()
+			// twice, the second unlike is a no-op
+			for j := 0; j < 2; j++ {
+				if _, err := community.LikeArticle(todo, uid, id, false); err != nil {
+					t.Errorf("LikeArticle(%s, false) returned %v", uid, err)
+				}
+			}
+		}(strconv.Itoa(i + 2))
+	}
+	wg.Wait()
+
+	article, _ := community.Article(todo, id)
+	if article.Likes != n/2 || article.Bookmarks != n {
+		t.Errorf("Article() returned %d likes and %d bookmarks, expected: %d and %d", article.Likes, article.Bookmarks, n/2, n)
+	}
+	var likes int
+	db := community.store.(*sqlStore).db
+	if err := db.QueryRowContext(todo, "select count(*) from article_like where article_id=?", id).Scan(&likes); err != nil || likes != article.Likes {
+		t.Errorf("article_like has %d likes, %v, expected: %d", likes, err, article.Likes)
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+
+	var bookmarked []string
+	for _, title := range []string{"a", "b", "c"} {
+		id := putTestArticle(t, community, "1", title)
+		community.SetArticleStatus(todo, "1", id, StatusPublished)
+		if _, err := community.BookmarkArticle(todo, "2", id, true); err != nil {
+			t.Fatal(err)
+		}
+		bookmarked = append(bookmarked, id)
+	}
+	putTestArticle(t, community, "1", "not bookmarked")
+	// unpublished after being bookmarked
+	community.SetArticleStatus(todo, "1", bookmarked[1], StatusDraft)
+
+	items, _, next, err := community.Bookmarks(todo, "2", MarkBegin, 10)
+	if err != nil || next != MarkEnd {
+		t.Fatalf("Bookmarks() returned %s, %v", next, err)
+	}
+	if got, want := ids(items), []string{bookmarked[2], bookmarked[0]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bookmarks() returned %v, expected: %v", got, want)
+	}
+}
+
+func TestViewCounter(t *testing.T) {
+	c := newViewCounter()
+	now := time.Now()
+	tests := []struct {
+		id, viewer string
+		at         time.Duration
+		expected   bool
+	}{
+		{"1", "a", 0, true},
+		{"1", "a", time.Minute, false},
+		{"1", "b", time.Minute, true},
+		{"2", "a", time.Minute, true},
+		{"1", "a", viewWindow, true},
+	}
+	for _, tt := range tests {
+		if got := c.add(tt.id, tt.viewer, now.Add(tt.at)); got != tt.expected {
+			t.Errorf("add(%s, %s, +%v) = %v, expected: %v", tt.id, tt.viewer, tt.at, got, tt.expected)
+		}
+	}
+	views := c.take(now.Add(viewWindow))
+	if want := map[string]int64{"1": 3, "2": 1}; !reflect.DeepEqual(views, want) {
+		t.Errorf("take() = %v, expected: %v", views, want)
+	}
+	c.restore(views)
+	c.add("2", "c", now)
+	if views, want := c.take(now), map[string]int64{"1": 3, "2": 2}; !reflect.DeepEqual(views, want) {
+		t.Errorf("take() after restore = %v, expected: %v", views, want)
+	}
+	// seen viewers are forgotten after the window
+	c.take(now.Add(2 * viewWindow))
+	if len(c.seen) != 0 {
+		t.Errorf("take() kept %d viewers", len(c.seen))
+	}
+}
+
+func TestViewArticle(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	id := putTestArticle(t, community, "1", "Test")
+
+	for _, viewer := range []string{"1", "1", "2", "127.0.0.1"} {
+		community.ViewArticle(id, viewer)
+	}
+	if err := community.flushViews(todo); err != nil {
+		t.Fatal(err)
+	}
+	if article, _ := community.Article(todo, id); article.Views != 3 {
+		t.Errorf("Article() returned %d views, expected: 3", article.Views)
+	}
+}
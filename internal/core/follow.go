@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"errors"
+)
+
+var errFollowSelf = errors.New("core: can't follow yourself")
+
+// Follow makes uid follow or unfollow (follow is false) the user followee.
+func (p *Community) Follow(ctx context.Context, uid, followee string, follow bool) error {
+	if uid == "" {
+		return ErrPermission
+	}
+	if uid == followee {
+		return errFollowSelf
+	}
+	if err := p.authorize(ctx, uid, ActionFollow, followee); err != nil {
+		return err
+	}
+	if follow {
+		if _, err := p.users.get([]string{followee}); err != nil {
+			return ErrNotExist
+		}
+	}
+	if err := p.store.SetFollow(ctx, uid, followee, follow); err != nil || !follow {
+		return err
+	}
+	p.notify(ctx, &Notification{UId: followee, ActorID: uid, Kind: NotifyFollow})
+	return nil
+}
+
+// IsFollowing reports whether uid follows followee.
+func (p *Community) IsFollowing(ctx context.Context, uid, followee string) (bool, error) {
+	if uid == "" {
+		return false, nil
+	}
+	return p.store.IsFollowing(ctx, uid, followee)
+}
+
+// CountFollows counts the users uid follows and the followers of uid.
+func (p *Community) CountFollows(ctx context.Context, uid string) (following, followers int, err error) {
+	return p.store.CountFollows(ctx, uid)
+}
+
+// Following lists the users uid follows, latest first.
+func (p *Community) Following(ctx context.Context, uid string, offset, limit int) ([]User, error) {
+	return p.follows(ctx, uid, false, offset, limit)
+}
+
+// Followers lists the followers of uid, latest first.
+func (p *Community) Followers(ctx context.Context, uid string, offset, limit int) ([]User, error) {
+	return p.follows(ctx, uid, true, offset, limit)
+}
+
+func (p *Community) follows(ctx context.Context, uid string, followers bool, offset, limit int) ([]User, error) {
+	uids, err := p.store.ListFollows(ctx, uid, followers, offset, limit)
+	if err != nil {
+		return []User{}, err
+	}
+	authors := p.authorsOf(uids)
+	users := make([]User, len(uids))
+	for i, id := range uids {
+		users[i] = authors[id]
+	}
+	return users, nil
+}
+
+// Feed lists the articles of the authors uid follows from a position like
+// ListArticle, newest first.
+func (p *Community) Feed(ctx context.Context, uid, from string, limit int) (items []*ArticleEntry, prev, next string, err error) {
+	if uid == "" {
+		return []*ArticleEntry{}, MarkEnd, MarkEnd, ErrPermission
+	}
+	filter := &ArticleFilter{FollowedBy: uid, Statuses: listedStatuses}
+	return p.listArticles(ctx, filter, from, limit)
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFollow(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+
+	tests := []struct {
+		uid, followee string
+		follow        bool
+		expectedErr   error
+	}{
+		{"1", "2", true, nil},
+		{"1", "2", true, nil}, // no-op
+		{"1", "3", true, nil},
+		{"3", "2", true, nil},
+		{"1", "3", false, nil},
+		{"1", "3", false, nil}, // no-op
+		{"", "2", true, ErrPermission},
+		{"1", "1", true, errFollowSelf},
+		{"1", "404", true, ErrNotExist},
+	}
+	for _, tt := range tests {
+		if err := community.Follow(todo, tt.uid, tt.followee, tt.follow); err != tt.expectedErr {
+			t.Errorf("Follow(%s, %s, %v) returned err: %v, expected: %v", tt.uid, tt.followee, tt.follow, err, tt.expectedErr)
+		}
+	}
+
+	if following, _ := community.IsFollowing(todo, "1", "2"); !following {
+		t.Errorf("IsFollowing(1, 2) = false, expected: true")
+	}
+	if following, _ := community.IsFollowing(todo, "1", "3"); following {
+		t.Errorf("IsFollowing(1, 3) = true, expected: false")
+	}
+	if following, followers, err := community.CountFollows(todo, "2"); following != 0 || followers != 2 || err != nil {
+		t.Errorf("CountFollows(2) returned %d, %d, %v, expected: 0, 2", following, followers, err)
+	}
+	users, err := community.Followers(todo, "2", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	sort.Strings(names)
+	if want := []string{"user1", "user3"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Followers(2) returned %v, expected: %v", names, want)
+	}
+	if users, _ := community.Following(todo, "1", 0, 10); len(users) != 1 || users[0].Id != "2" {
+		t.Errorf("Following(1) returned %v, expected: user 2", users)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+
+	var want []string
+	for _, uid := range []string{"2", "3", "4", "2"} {
+		id := putTestArticle(t, community, uid, "Test")
+		community.SetArticleStatus(todo, uid, id, StatusPublished)
+		if uid != "4" {
+			want = append([]string{id}, want...)
+		}
+	}
+	putTestArticle(t, community, "2", "Draft")
+	community.Follow(todo, "1", "2", true)
+	community.Follow(todo, "1", "3", true)
+
+	var got []string
+	from := MarkBegin
+	for from != MarkEnd {
+		items, _, next, err := community.Feed(todo, "1", from, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ids(items)...)
+		from = next
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Feed(1) returned %v, expected: %v", got, want)
+	}
+
+	if _, _, _, err := community.Feed(todo, "5", MarkBegin, 2); err != io.EOF {
+		t.Errorf("Feed() following nobody returned err: %v, expected: %v", err, io.EOF)
+	}
+	if _, _, _, err := community.Feed(todo, "", MarkBegin, 2); err != ErrPermission {
+		t.Errorf("Feed() signed out returned err: %v, expected: %v", err, ErrPermission)
+	}
+}
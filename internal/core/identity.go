@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+	"github.com/goplus/yap"
+	"golang.org/x/oauth2"
+)
+
+// IdentityProvider logs users in and keeps their profiles. It's casdoor
+// (CasdoorConfig) in production, and may be a LocalProvider to develop and
+// test the community offline.
+type IdentityProvider interface {
+	// LoginURL returns the login page, which calls back redirectURI with an
+	// oauth code and state once the user logs in.
+	LoginURL(redirectURI, state string) string
+	// GetOAuthToken exchanges the oauth code of a callback for tokens.
+	GetOAuthToken(code, state string) (*oauth2.Token, error)
+	// RefreshOAuthToken gets new tokens with a refresh token.
+	RefreshOAuthToken(refreshToken string) (*oauth2.Token, error)
+	// ParseJwtToken verifies an access token and returns its claims.
+	ParseJwtToken(token string) (*casdoorsdk.Claims, error)
+	// GetUserByUserId returns the profile of user uid, or nil if there is
+	// no such user.
+	GetUserByUserId(uid string) (*casdoorsdk.User, error)
+	// GetUsersByUserIds returns the profiles of those of users uids that
+	// exist, in one lookup.
+	GetUsersByUserIds(uids []string) ([]*casdoorsdk.User, error)
+	// UpdateUserById updates the profile of user uid.
+	UpdateUserById(uid string, user *casdoorsdk.User) (bool, error)
+}
+
+// LocalLogin serves the login page of a LocalProvider. It's 404 with other
+// identity providers, which have login pages of their own.
+func (p *Community) LocalLogin(ctx *yap.Context) {
+	local, ok := p.idp.(*LocalProvider)
+	if !ok {
+		ctx.ResponseWriter.Header().Set("Content-Type", "text/html")
+		ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		ctx.YAP(http.StatusNotFound, "4xx", map[string]interface{}{})
+		return
+	}
+	local.ServeHTTP(ctx.ResponseWriter, ctx.Request)
+}
+
+var _ IdentityProvider = (*CasdoorConfig)(nil)
+
+func (c *CasdoorConfig) LoginURL(redirectURI, state string) string {
+	return fmt.Sprintf(
+		"%s/login/oauth/authorize?client_id=%s&response_type=code&redirect_uri=%s&scope=read&state=%s",
+		c.endPoint,
+		c.clientId,
+		url.QueryEscape(redirectURI),
+		url.QueryEscape(state),
+	)
+}
+
+func (c *CasdoorConfig) GetOAuthToken(code, state string) (*oauth2.Token, error) {
+	return casdoorsdk.GetOAuthToken(code, state)
+}
+
+func (c *CasdoorConfig) RefreshOAuthToken(refreshToken string) (*oauth2.Token, error) {
+	return casdoorsdk.RefreshOAuthToken(refreshToken)
+}
+
+func (c *CasdoorConfig) ParseJwtToken(token string) (*casdoorsdk.Claims, error) {
+	return casdoorsdk.ParseJwtToken(token)
+}
+
+func (c *CasdoorConfig) GetUserByUserId(uid string) (*casdoorsdk.User, error) {
+	return casdoorsdk.GetUserByUserId(uid)
+}
+
+// GetUsersByUserIds lists the users of the organization, as casdoor can't
+// look up several users by id at once.
+func (c *CasdoorConfig) GetUsersByUserIds(uids []string) ([]*casdoorsdk.User, error) {
+	all, err := casdoorsdk.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		wanted[uid] = true
+	}
+	users := make([]*casdoorsdk.User, 0, len(uids))
+	for _, user := range all {
+		if wanted[user.Id] {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (c *CasdoorConfig) UpdateUserById(uid string, user *casdoorsdk.User) (bool, error) {
+	return casdoorsdk.UpdateUserById(uid, user)
+}
@@ -0,0 +1,270 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// localLoginPath is the login page of a LocalProvider, on the site of
+	// its callback.
+	localLoginPath = "/login/local"
+
+	localCodeTTL    = time.Minute
+	localAccessTTL  = time.Hour
+	localRefreshTTL = sessionTTL
+)
+
+// LocalProvider is an IdentityProvider for local development, which logs in
+// the users of a fixture without casdoor. Its login page lists the users to
+// log in as, and its codes and tokens are JWTs signed by a key of its own.
+// Updates of profiles are kept in memory only.
+type LocalProvider struct {
+	key []byte
+
+	mu    sync.RWMutex
+	users []*casdoorsdk.User // in the order of the fixture
+}
+
+var _ IdentityProvider = (*LocalProvider)(nil)
+
+// NewLocalProvider returns a LocalProvider of users, which signs its tokens
+// with key.
+func NewLocalProvider(users []*casdoorsdk.User, key []byte) *LocalProvider {
+	return &LocalProvider{key: key, users: users}
+}
+
+// LoadLocalProvider returns a LocalProvider of the users in a JSON file,
+// which is an array of casdoor users, such as:
+//
+//	[{"id": "1", "name": "alice", "avatar": "", "email": "alice@example.com", "isAdmin": true}]
+func LoadLocalProvider(file string, key []byte) (*LocalProvider, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var users []*casdoorsdk.User
+	if err = json.Unmarshal(b, &users); err != nil {
+		return nil, fmt.Errorf("core: invalid users of %s: %v", file, err)
+	}
+	for i, user := range users {
+		if user.Id == "" {
+			return nil, fmt.Errorf("core: user %d of %s has no id", i, file)
+		}
+	}
+	return NewLocalProvider(users, key), nil
+}
+
+func (p *LocalProvider) LoginURL(redirectURI, state string) string {
+	query := url.Values{"redirect_uri": {redirectURI}, "state": {state}}.Encode()
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return localLoginPath + "?" + query
+	}
+	login := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: localLoginPath, RawQuery: query}
+	return login.String()
+}
+
+var localLoginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Log in</title></head>
+<body>
+<h1>Log in as</h1>
+<ul>
+{{range .}}<li><a href="{{.URL}}">{{.Name}}</a> ({{.Id}})</li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// ServeHTTP serves the login page, which links to the callback with a code
+// of each user. The callback must be on the site of the page, so that the
+// codes can't be handed to other sites.
+func (p *LocalProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	callback, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || callback.Host != "" && callback.Host != r.Host || callback.Path == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	type entry struct {
+		Id, Name, URL string
+	}
+	var entries []entry
+	p.mu.RLock()
+	for _, user := range p.users {
+		code, err := p.sign(user.Id, "code", localCodeTTL)
+		if err != nil {
+			p.mu.RUnlock()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		name := user.DisplayName
+		if name == "" {
+			name = user.Name
+		}
+		u := *callback
+		u.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+		entries = append(entries, entry{user.Id, name, u.String()})
+	}
+	p.mu.RUnlock()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	localLoginPage.Execute(w, entries)
+}
+
+func (p *LocalProvider) GetOAuthToken(code, state string) (*oauth2.Token, error) {
+	var claims jwt.RegisteredClaims
+	if err := p.parse(code, "code", &claims, &claims); err != nil {
+		return nil, refusedToken(err.Error())
+	}
+	return p.tokens(claims.Subject)
+}
+
+func (p *LocalProvider) RefreshOAuthToken(refreshToken string) (*oauth2.Token, error) {
+	var claims jwt.RegisteredClaims
+	if err := p.parse(refreshToken, "refresh", &claims, &claims); err != nil {
+		return nil, refusedToken(err.Error())
+	}
+	return p.tokens(claims.Subject)
+}
+
+func (p *LocalProvider) ParseJwtToken(token string) (*casdoorsdk.Claims, error) {
+	claims := new(casdoorsdk.Claims)
+	if err := p.parse(token, "access", claims, &claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (p *LocalProvider) GetUserByUserId(uid string) (*casdoorsdk.User, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if i := p.find(uid); i >= 0 {
+		user := *p.users[i]
+		return &user, nil
+	}
+	return nil, nil
+}
+
+func (p *LocalProvider) GetUsersByUserIds(uids []string) ([]*casdoorsdk.User, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	users := make([]*casdoorsdk.User, 0, len(uids))
+	for _, uid := range uids {
+		if i := p.find(uid); i >= 0 {
+			user := *p.users[i]
+			users = append(users, &user)
+		}
+	}
+	return users, nil
+}
+
+func (p *LocalProvider) UpdateUserById(uid string, user *casdoorsdk.User) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := p.find(uid)
+	if i < 0 {
+		return false, ErrNotExist
+	}
+	updated := *user
+	updated.Id = uid
+	p.users[i] = &updated
+	return true, nil
+}
+
+// find returns the index of user uid, or -1 if there is none.
+func (p *LocalProvider) find(uid string) int {
+	for i, user := range p.users {
+		if user.Id == uid {
+			return i
+		}
+	}
+	return -1
+}
+
+// tokens returns new access and refresh tokens of user uid.
+func (p *LocalProvider) tokens(uid string) (*oauth2.Token, error) {
+	user, err := p.GetUserByUserId(uid)
+	if err != nil || user == nil {
+		return nil, refusedToken("no user " + uid)
+	}
+	expiry := time.Now().Add(localAccessTTL)
+	claims := &casdoorsdk.Claims{
+		User: *user,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uid,
+			Audience:  jwt.ClaimStrings{"access"},
+			ExpiresAt: jwt.NewNumericDate(expiry),
+		},
+	}
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(p.key)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := p.sign(uid, "refresh", localRefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: access, TokenType: "Bearer", RefreshToken: refresh, Expiry: expiry}, nil
+}
+
+// refusedToken returns the error of a code or a refresh token being refused, as
+// casdoor would, so that sessions of such tokens are revoked.
+func refusedToken(reason string) error {
+	return &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}, Body: []byte(reason)}
+}
+
+// sign returns a JWT for user uid, which is a code or a refresh token by
+// audience, expiring in ttl.
+func (p *LocalProvider) sign(uid, audience string, ttl time.Duration) (string, error) {
+	claims := &jwt.RegisteredClaims{
+		Subject:   uid,
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(p.key)
+}
+
+// parse verifies a JWT signed by p for audience into claims, whose
+// registered claims are reg.
+func (p *LocalProvider) parse(token, audience string, claims jwt.Claims, reg *jwt.RegisteredClaims) error {
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return p.key, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !reg.VerifyAudience(audience, true) {
+		return fmt.Errorf("core: token is not a %s token", audience)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+	"github.com/goplus/yap"
+)
+
+// newLocalCommunity returns a community logging in the users of the example
+// fixture with a LocalProvider.
+func newLocalCommunity(t *testing.T) (*Community, *LocalProvider) {
+	local, err := LoadLocalProvider("../../cmd/gopcomm/users.example.json", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	community := newTestCommunity(t)
+	community.idp = local
+	community.tokens = newOAuthTokens(local)
+	community.users = newUserCache(fetchUser(local), time.Minute)
+	return community, local
+}
+
+var loginLink = regexp.MustCompile(`<a href="([^"]+)">([^<]+)</a>`)
+
+func TestLocalLogin(t *testing.T) {
+	community, _ := newLocalCommunity(t)
+
+	// the login page of the provider is on the site
+	req := httptest.NewRequest("GET", "/login", nil)
+	w := httptest.NewRecorder()
+	loginURL, err := community.RedirectToCasdoor(&yap.Context{Request: req, ResponseWriter: w}, "/p/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := w.Result().Cookies()[0].Value
+	u, err := url.Parse(loginURL)
+	if err != nil || u.Host != "example.com" || u.Path != localLoginPath {
+		t.Fatalf("RedirectToCasdoor() returned %q, expected the local login page", loginURL)
+	}
+
+	// which lists the users of the fixture to log in as
+	w = httptest.NewRecorder()
+	community.LocalLogin(&yap.Context{Request: httptest.NewRequest("GET", loginURL, nil), ResponseWriter: w})
+	links := loginLink.FindAllStringSubmatch(w.Body.String(), -1)
+	if w.Code != http.StatusOK || len(links) != 4 || links[0][2] != "Admin" {
+		t.Fatalf("LocalLogin() responded %d %q, expected the users of the fixture", w.Code, w.Body)
+	}
+
+	req = httptest.NewRequest("GET", html.UnescapeString(links[0][1]), nil)
+	req.AddCookie(&http.Cookie{Name: stateCookie, Value: state})
+	w = httptest.NewRecorder()
+	returnTo, err := community.SetToken(&yap.Context{Request: req, ResponseWriter: w})
+	if err != nil || returnTo != "/p/1" {
+		t.Fatalf("SetToken() returned %q, %v, expected: /p/1", returnTo, err)
+	}
+	var session string
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			session = cookie.Value
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+	user, err := community.Authenticate(&yap.Context{Request: req, ResponseWriter: httptest.NewRecorder()})
+	if err != nil || user.Id != "1" || user.Name != "admin" {
+		t.Fatalf("Authenticate() returned %v, %v, expected user 1", user, err)
+	}
+	for uid, expected := range map[string]Role{"1": RoleAdmin, "2": RoleModerator, "3": RoleAuthor, "4": RoleReader} {
+		if role, err := community.Role(context.TODO(), uid); err != nil || role != expected {
+			t.Errorf("Role(%s) = %v, %v, expected: %v", uid, role, err, expected)
+		}
+	}
+
+	// codes are only handed to the site itself
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", localLoginPath+"?redirect_uri="+url.QueryEscape("http://evil.com/callback"), nil)
+	community.LocalLogin(&yap.Context{Request: req, ResponseWriter: w})
+	if w.Code != http.StatusBadRequest || loginLink.MatchString(w.Body.String()) {
+		t.Errorf("LocalLogin() to another site responded %d %q, expected: %d", w.Code, w.Body, http.StatusBadRequest)
+	}
+}
+
+func TestLocalTokens(t *testing.T) {
+	community, local := newLocalCommunity(t)
+
+	code, err := local.sign("2", "code", localCodeTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := local.GetOAuthToken(code, "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid, err := community.ParseJwtToken(tok.AccessToken); err != nil || uid != "2" {
+		t.Errorf("ParseJwtToken() = %q, %v, expected: 2", uid, err)
+	}
+	tok, err = local.RefreshOAuthToken(tok.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid, err := community.ParseJwtToken(tok.AccessToken); err != nil || uid != "2" {
+		t.Errorf("ParseJwtToken() of a refreshed token = %q, %v, expected: 2", uid, err)
+	}
+
+	// tokens are only good for what they are issued for, by the key of the
+	// provider, and for users of the fixture
+	if _, err := local.ParseJwtToken(code); err == nil {
+		t.Errorf("ParseJwtToken() of a code succeeded")
+	}
+	if _, err := local.GetOAuthToken(tok.RefreshToken, "state"); err == nil {
+		t.Errorf("GetOAuthToken() of a refresh token succeeded")
+	}
+	other := NewLocalProvider(local.users, []byte("other"))
+	if _, err := other.ParseJwtToken(tok.AccessToken); err == nil {
+		t.Errorf("ParseJwtToken() of a token signed by another key succeeded")
+	}
+	expired, _ := local.sign("2", "code", -time.Minute)
+	if _, err := local.GetOAuthToken(expired, "state"); err == nil {
+		t.Errorf("GetOAuthToken() of an expired code succeeded")
+	}
+	unknown, _ := local.sign("404", "refresh", localRefreshTTL)
+	if _, err := local.RefreshOAuthToken(unknown); err == nil {
+		t.Errorf("RefreshOAuthToken() of an unknown user succeeded")
+	}
+
+	// profiles are looked up and updated in the fixture
+	if _, err := community.UpdateUserById("3", &casdoorsdk.User{Name: "writer"}); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := community.GetUserById("3"); err != nil || user.Id != "3" || user.Name != "writer" {
+		t.Errorf("GetUserById() after an update = %v, %v, expected: writer", user, err)
+	}
+	if _, err := community.GetUserById("404"); err != ErrNotExist {
+		t.Errorf("GetUserById() of an unknown user returned %v, expected: %v", err, ErrNotExist)
+	}
+}
+
+func TestLoadLocalProvider(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(file, []byte(`[{"name": "anonymous"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLocalProvider(file, nil); err == nil {
+		t.Errorf("LoadLocalProvider() of a user without id succeeded")
+	}
+	if err := os.WriteFile(file, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLocalProvider(file, nil); err == nil {
+		t.Errorf("LoadLocalProvider() of an invalid fixture succeeded")
+	}
+}
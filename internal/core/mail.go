@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"embed"
+	htmltemplate "html/template"
+	"net/mail"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	cmail "github.com/goplus/community/internal/mail"
+)
+
+// NotificationPrefs are the notification settings of a user.
+type NotificationPrefs struct {
+	EmailReplies bool // email on comments on the user's articles and replies
+	EmailDigest  bool // weekly email of the top articles
+}
+
+// DefaultPrefs are the preferences of the users who never set them.
+var DefaultPrefs = NotificationPrefs{EmailReplies: true}
+
+// NotificationPrefs returns the notification preferences of uid.
+func (p *Community) NotificationPrefs(ctx context.Context, uid string) (*NotificationPrefs, error) {
+	if uid == "" {
+		return &DefaultPrefs, ErrPermission
+	}
+	return p.store.GetPrefs(ctx, uid)
+}
+
+// SetNotificationPrefs replaces the notification preferences of uid.
+func (p *Community) SetNotificationPrefs(ctx context.Context, uid string, prefs *NotificationPrefs) error {
+	if uid == "" {
+		return ErrPermission
+	}
+	return p.store.SetPrefs(ctx, uid, prefs)
+}
+
+//go:embed templates
+var mailFS embed.FS
+
+// mailTemplate renders an email from templates/name.txt, which defines its
+// subject too, and templates/name.html.
+type mailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var mailTemplates = map[string]*mailTemplate{
+	"reply":  mustParseMail("reply"),
+	"digest": mustParseMail("digest"),
+}
+
+func mustParseMail(name string) *mailTemplate {
+	return &mailTemplate{
+		text: texttemplate.Must(texttemplate.ParseFS(mailFS, "templates/"+name+".txt")),
+		html: htmltemplate.Must(htmltemplate.ParseFS(mailFS, "templates/"+name+".html")),
+	}
+}
+
+// render renders the email to user from data.
+func (t *mailTemplate) render(to *User, data any) (*cmail.Message, error) {
+	var subject, text, html strings.Builder
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := t.text.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	if err := t.html.Execute(&html, data); err != nil {
+		return nil, err
+	}
+	return &cmail.Message{
+		To:      (&mail.Address{Name: to.Name, Address: to.Email}).String(),
+		Subject: subject.String(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// mailer sends the emails of the community. Notifications are mailed in the
+// background, and Close waits for them.
+type mailer struct {
+	cmail.Mailer
+	wg sync.WaitGroup
+}
+
+// replyMail is the data of the reply templates.
+type replyMail struct {
+	SiteURL      string
+	Recipient    User
+	Actor        User
+	Kind         string
+	ArticleID    string
+	ArticleTitle string
+	Comment      *Comment
+	CommentHtml  htmltemplate.HTML // sanitized Comment.Html
+}
+
+// mailNotification emails notification n in the background if its
+// recipient wants it. Only comments and replies are mailed.
+func (p *Community) mailNotification(n *Notification) {
+	if p.mailer == nil || (n.Kind != NotifyComment && n.Kind != NotifyReply) {
+		return
+	}
+	p.mailer.wg.Add(1)
+	go func() {
+		defer p.mailer.wg.Done()
+		if err := p.sendNotificationMail(context.Background(), n); err != nil {
+			p.xLog.Error("mail notification error:", err)
+		}
+	}()
+}
+
+func (p *Community) sendNotificationMail(ctx context.Context, n *Notification) error {
+	prefs, err := p.store.GetPrefs(ctx, n.UId)
+	if err != nil || !prefs.EmailReplies {
+		return err
+	}
+	users, _ := p.users.get([]string{n.UId, n.ActorID})
+	recipient := users[n.UId]
+	if recipient == nil || recipient.Email == "" {
+		return nil
+	}
+	actor := users[n.ActorID]
+	if actor == nil {
+		placeholder := placeholderUser(n.ActorID)
+		actor = &placeholder
+	}
+	article, _, err := p.store.GetArticle(ctx, n.ArticleID)
+	if err != nil {
+		return err
+	}
+	comment, err := p.store.GetComment(ctx, n.CommentID)
+	if err != nil {
+		return err
+	}
+	msg, err := mailTemplates["reply"].render(recipient, &replyMail{
+		SiteURL:      p.siteURL,
+		Recipient:    *recipient,
+		Actor:        *actor,
+		Kind:         n.Kind,
+		ArticleID:    n.ArticleID,
+		ArticleTitle: article.Title,
+		Comment:      comment,
+		CommentHtml:  htmltemplate.HTML(comment.Html),
+	})
+	if err != nil {
+		return err
+	}
+	return p.mailer.Send(ctx, msg)
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	cmail "github.com/goplus/community/internal/mail"
+)
+
+// useTestMailer makes community drop its emails into a temporary directory,
+// and returns a function reading the recipients and subjects of the emails
+// sent.
+func useTestMailer(t *testing.T, community *Community) func() []string {
+	dir := t.TempDir()
+	community.mailer = &mailer{Mailer: &cmail.DirMailer{Dir: dir, From: "noreply@example.com"}}
+	community.siteURL = "https://community.example.com"
+	return func() []string {
+		community.mailer.wg.Wait()
+		files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+		var sent []string
+		for _, file := range files {
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := mail.ReadMessage(f)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			to, _ := mail.ParseAddress(msg.Header.Get("To"))
+			subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+			sent = append(sent, to.Address+": "+subject)
+			os.Remove(file)
+		}
+		sort.Strings(sent)
+		return sent
+	}
+}
+
+func TestMailNotification(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	sent := useTestMailer(t, community)
+
+	id := putTestArticle(t, community, "1", "Test")
+	community.SetArticleStatus(todo, "1", id, StatusPublished)
+	comment, err := community.PutComment(todo, "2", id, "", "Nice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	community.PutComment(todo, "3", id, comment.ID, "Thanks")
+	community.LikeArticle(todo, "3", id, true) // likes aren't mailed
+	want := []string{
+		"1@example.com: user2 commented on “Test”",
+		"1@example.com: user3 commented on “Test”",
+		"2@example.com: user3 replied to you on “Test”",
+	}
+	if got := sent(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("sent %q, expected: %q", got, want)
+	}
+
+	if err = community.SetNotificationPrefs(todo, "1", &NotificationPrefs{EmailReplies: false}); err != nil {
+		t.Fatal(err)
+	}
+	community.PutComment(todo, "2", id, "", "Again")
+	if got := sent(); len(got) != 0 {
+		t.Errorf("sent %q after opting out, expected nothing", got)
+	}
+	if prefs, _ := community.NotificationPrefs(todo, "2"); *prefs != DefaultPrefs {
+		t.Errorf("NotificationPrefs(2) = %+v, expected: %+v", *prefs, DefaultPrefs)
+	}
+}
+
+func TestSendDigests(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	sent := useTestMailer(t, community)
+
+	now := time.Now()
+	if n, err := community.SendDigests(todo, now); n != 0 || err != nil {
+		t.Errorf("SendDigests() without articles returned %d, %v", n, err)
+	}
+	for _, title := range []string{"Quiet", "Liked", "Discussed"} {
+		id := putTestArticle(t, community, "1", title)
+		community.SetArticleStatus(todo, "1", id, StatusPublished)
+		switch title {
+		case "Liked":
+			community.LikeArticle(todo, "2", id, true)
+		case "Discussed":
+			community.LikeArticle(todo, "2", id, true)
+			community.PutComment(todo, "3", id, "", "Nice")
+		}
+	}
+	top, err := community.store.TopArticles(todo, now.Add(-digestPeriod), digestSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, item := range top {
+		titles = append(titles, item.Title)
+	}
+	if got := strings.Join(titles, ","); got != "Discussed,Liked" {
+		t.Errorf("TopArticles() returned %s, expected: Discussed,Liked", got)
+	}
+
+	community.SetNotificationPrefs(todo, "2", &NotificationPrefs{EmailDigest: true})
+	community.SetNotificationPrefs(todo, "4", &NotificationPrefs{EmailDigest: true})
+	community.SetNotificationPrefs(todo, "5", &NotificationPrefs{})
+	sent() // the comment notification
+
+	now = time.Now()
+	if n, err := community.SendDigests(todo, now); n != 2 || err != nil {
+		t.Errorf("SendDigests() returned %d, %v, expected: 2", n, err)
+	}
+	want := "2@example.com: This week on Go+ Community\n4@example.com: This week on Go+ Community"
+	if got := sent(); strings.Join(got, "\n") != want {
+		t.Errorf("sent %q, expected: %q", got, want)
+	}
+	if n, _ := community.SendDigests(todo, now.Add(time.Hour)); n != 0 {
+		t.Errorf("SendDigests() an hour later returned %d, expected: 0", n)
+	}
+}
@@ -7,11 +7,12 @@ import (
 	"io"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/qiniu/go-cdk-driver/kodoblob"
+	_ "gocloud.dev/blob/fileblob"
+	_ "gocloud.dev/blob/memblob"
 )
 
 type File struct {
@@ -24,47 +25,58 @@ type File struct {
 	UpdateAt time.Time
 }
 
+// DelMedias deletes the records of medias ids. Their owners and moderators
+// may delete them.
 func (c *Community) DelMedias(ctx context.Context, userId string, ids []string) error {
-
-	uId, err := strconv.Atoi(userId)
-	if err != nil {
-		return err
+	owned := make(map[string][]string)
+	for _, id := range ids {
+		if _, err := strconv.Atoi(id); err != nil {
+			return err
+		}
+		owner, err := c.store.GetFileOwner(ctx, id)
+		if err == ErrNotExist {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err = c.authorize(ctx, userId, ActionDeleteMedia, owner); err != nil {
+			return err
+		}
+		owned[owner] = append(owned[owner], id)
 	}
-	idStr := strings.Join(ids, ",")
-	_, err = c.db.ExecContext(ctx, "delete from file where user_id = ? and id in( ? )", uId, idStr)
-
-	return err
+	for owner, ids := range owned {
+		if err := c.store.DeleteFiles(ctx, owner, ids); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+// DelMedia deletes media mediaId. Its owner and moderators may delete it.
 func (c *Community) DelMedia(ctx context.Context, userId, mediaId string) error {
-
-	uId, err := strconv.Atoi(userId)
+	// get the file key before the record is gone
+	fileKey, err := c.GetMediaUrl(ctx, mediaId)
 	if err != nil {
 		return err
 	}
-	mId, err := strconv.Atoi(mediaId)
+	owner, err := c.store.GetFileOwner(ctx, mediaId)
 	if err != nil {
 		return err
 	}
-	// del db media
-	res, err := c.db.ExecContext(ctx, "delete from file where user_id = ? and id = ?", uId, mId)
-	if err != nil {
+	if err = c.authorize(ctx, userId, ActionDeleteMedia, owner); err != nil {
 		return err
 	}
-	aff, err := res.RowsAffected()
+	// del db media
+	deleted, err := c.store.DeleteFile(ctx, owner, mediaId)
 	if err != nil {
 		return err
 	}
-	if aff == 0 {
+	if !deleted {
 		c.xLog.Warn("no need del data")
 		return nil
 	}
 
 	// del cloud oss media
-	fileKey, err := c.GetMediaUrl(ctx, mediaId)
-	if err != nil {
-		return err
-	}
 	if err := c.bucket.Delete(context.Background(), fileKey); err != nil {
 		return err
 	}
@@ -73,14 +85,38 @@ func (c *Community) DelMedia(ctx context.Context, userId, mediaId string) error
 
 // get file key
 func (c *Community) GetMediaUrl(ctx context.Context, mediaId string) (string, error) {
-	ID, err := strconv.Atoi(mediaId)
-	if err != nil {
+	if _, err := strconv.Atoi(mediaId); err != nil {
 		return "", err
 	}
-	row := c.db.QueryRow(`select file_key from file where id = ?`, ID)
-	var fileKey string
-	row.Scan(&fileKey)
+	return c.store.GetFileKey(ctx, mediaId)
+}
 
+// GetVisibleMediaUrl returns the file key of media id if uid may see it. The
+// html of an article is visible along with the article, and the html of its
+// old revisions to those who may edit it.
+func (c *Community) GetVisibleMediaUrl(ctx context.Context, uid, mediaId string) (string, error) {
+	fileKey, err := c.GetMediaUrl(ctx, mediaId)
+	if err != nil {
+		return "", err
+	}
+	id, old, err := c.store.GetMediaArticle(ctx, mediaId)
+	if err == ErrNotExist {
+		return fileKey, nil
+	} else if err != nil {
+		return "", err
+	}
+	article, _, err := c.store.GetArticle(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if !article.VisibleTo(uid) {
+		return "", ErrNotExist
+	}
+	if old {
+		if editable, _ := c.CanEditable(ctx, uid, id); !editable {
+			return "", ErrNotExist
+		}
+	}
 	return fileKey, nil
 }
 
@@ -98,17 +134,7 @@ func (c *Community) SaveMedia(ctx context.Context, userId string, data []byte) (
 		return 0, err
 	}
 	// save
-	stem, err := c.db.Prepare(`insert into file (file_key,format,size,user_id,create_at,update_at) VALUES (?,?,?,?,?,?)`)
-	if err != nil {
-		return 0, err
-	}
-	res, err := stem.Exec(fileKey, fileInfo.Format, fileInfo.Size, userId, time.Now(), time.Now())
-
-	if err != nil {
-		return 0, err
-	}
-
-	return res.LastInsertId()
+	return c.store.SaveFile(ctx, userId, fileInfo)
 }
 
 // for internal use,no need to add ctx
@@ -145,8 +171,15 @@ func (c *Community) uploadMedia(fileKey string, data []byte) error {
 	return nil
 }
 
+// UploadFile saves a file uploaded by the current user, see RequireAuth.
 func UploadFile(ctx *yap.Context, community *Community) {
 	xLog := xlog.New("")
+	uid := UserId(ctx)
+	// banned users may not fill the bucket
+	if err := community.authorize(ctx.Context(), uid, ActionUploadMedia, uid); err != nil {
+		Forbidden(ctx)
+		return
+	}
 	file, header, err := ctx.FormFile("file")
 	filename := header.Filename
 	ctx.ParseMultipartForm(10 << 20)
@@ -182,14 +215,6 @@ func UploadFile(ctx *yap.Context, community *Community) {
 		ctx.JSON(500, err.Error())
 		return
 	}
-	token, err := GetToken(ctx)
-	if err != nil {
-		ctx.JSON(500, err.Error())
-	}
-	uid, err := community.ParseJwtToken(token.Value)
-	if err != nil {
-		ctx.JSON(500, err.Error())
-	}
 	id, err := community.SaveMedia(context.Background(), uid, bytes)
 	if err != nil {
 		xLog.Error("save file", err.Error())
@@ -3,7 +3,7 @@ package core_test
 import (
 	"context"
 	"log"
-	"os"
+	"strconv"
 	"testing"
 
 	"github.com/goplus/community/internal/core"
@@ -13,9 +13,9 @@ var c *core.Community
 
 func TestMain(t *testing.M) {
 	config := &core.Config{
-		Driver: "mysql",
-		DSN:    "",
-		BlobUS: "",
+		Driver: "sqlite",
+		DSN:    ":memory:",
+		BlobUS: "mem://",
 	}
 	ret, err := core.New(context.Background(), config)
 	if err != nil {
@@ -26,50 +26,64 @@ func TestMain(t *testing.M) {
 	t.Run()
 }
 
+func saveTestMedia(t *testing.T) string {
+	id, err := c.SaveMedia(context.Background(), "1", []byte("<p>Hello, World!</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strconv.FormatInt(id, 10)
+}
+
 func TestGetMediaUrl(t *testing.T) {
-	url, err := c.GetMediaUrl(context.Background(), "10")
+	id := saveTestMedia(t)
+	url, err := c.GetMediaUrl(context.Background(), id)
 	if err != nil {
-		log.Fatalln(err.Error())
-		return
+		t.Fatal(err)
+	}
+	if url == "" {
+		t.Errorf("GetMediaUrl(%s) returned empty file key", id)
+	}
+	if _, err = c.GetMediaUrl(context.Background(), "10000"); err != core.ErrNotExist {
+		t.Errorf("GetMediaUrl(10000) returned err: %v, expected: %v", err, core.ErrNotExist)
 	}
-	log.Println(url)
 }
 
 func TestSaveMedia(t *testing.T) {
-	data, err := readFileByte("xxx")
+	id, err := c.SaveMedia(context.Background(), "1", []byte("data"))
 	if err != nil {
-		log.Fatalln(err.Error())
-		return
+		t.Fatal(err)
 	}
-	id, err := c.SaveMedia(context.Background(), "1", data)
-	if err != nil {
-		log.Fatalln(err.Error())
-		return
+	if id <= 0 {
+		t.Errorf("SaveMedia returned id: %d", id)
 	}
-	log.Println("id:", id)
-
 }
 
 func TestDelMeida(t *testing.T) {
-	err := c.DelMedia(context.Background(), "1", "10")
-	if err != nil {
-		log.Fatalln(err.Error())
-		return
+	id := saveTestMedia(t)
+	// not the owner
+	if err := c.DelMedia(context.Background(), "2", id); err != core.ErrPermission {
+		t.Errorf("DelMedia(2, %s) returned err: %v, expected: %v", id, err, core.ErrPermission)
+	}
+	if _, err := c.GetMediaUrl(context.Background(), id); err != nil {
+		t.Errorf("DelMedia(2, %s) deleted the media of another user", id)
+	}
+	if err := c.DelMedia(context.Background(), "1", id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetMediaUrl(context.Background(), id); err != core.ErrNotExist {
+		t.Errorf("GetMediaUrl(%s) returned err: %v, expected: %v", id, err, core.ErrNotExist)
 	}
-	log.Println(err.Error())
 }
 
 func TestDelMedias(t *testing.T) {
-	err := c.DelMedias(context.Background(), "1", []string{"1"})
+	ids := []string{saveTestMedia(t), saveTestMedia(t)}
+	err := c.DelMedias(context.Background(), "1", ids)
 	if err != nil {
-
-		log.Fatalln(err.Error())
-		return
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if _, err := c.GetMediaUrl(context.Background(), id); err != core.ErrNotExist {
+			t.Errorf("GetMediaUrl(%s) returned err: %v, expected: %v", id, err, core.ErrNotExist)
+		}
 	}
-
-}
-
-func readFileByte(filePath string) ([]byte, error) {
-	data, err := os.ReadFile(filePath)
-	return data, err
 }
@@ -0,0 +1,271 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// LatestVersion migrates the schema to the newest migration.
+const LatestVersion = -1
+
+//go:embed migrations
+var migrationFS embed.FS
+
+// Migration is a versioned schema change. Migration files are named
+// `<version>_<name>.up.sql` and `<version>_<name>.down.sql` and live in
+// migrations/<dialect>.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// migrationHooks run in Go after the up script of the migration of their
+// version, e.g. to convert data.
+var migrationHooks = map[int]func(ctx context.Context, tx *sql.Tx) error{
+	5: backfillTags,
+	6: backfillSearchIndex,
+}
+
+var rxMigration = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+func (d dialect) String() string {
+	if d == dialectSQLite {
+		return "sqlite"
+	}
+	return "mysql"
+}
+
+// loadMigrations returns the migrations of dialect d ordered by version.
+func loadMigrations(d dialect) (migrations []*Migration, err error) {
+	dir := path.Join("migrations", d.String())
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := rxMigration.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		data, err := fs.ReadFile(migrationFS, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+			migrations = append(migrations, mig)
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("core: migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return
+}
+
+// splitStatements splits a migration file into statements. Statements end
+// with a `;` at the end of a line.
+func splitStatements(script string) (stmts []string) {
+	var b strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(b.String()))
+			b.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(b.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return
+}
+
+const schemaVersionTable = `create table if not exists schema_version (
+	version integer not null primary key,
+	name varchar(255) not null,
+	applied_at datetime not null
+)`
+
+// SchemaVersion returns the version of the newest applied migration, or 0
+// when no migration has been applied.
+func (s *sqlStore) SchemaVersion(ctx context.Context) (version int, err error) {
+	if _, err = s.db.ExecContext(ctx, schemaVersionTable); err != nil {
+		return
+	}
+	err = s.db.QueryRowContext(ctx, "select coalesce(max(version), 0) from schema_version").Scan(&version)
+	return
+}
+
+// Migrate applies or rolls back migrations until the schema is at version.
+// Use LatestVersion to apply all pending migrations.
+func (s *sqlStore) Migrate(ctx context.Context, version int) error {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return err
+	}
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version == LatestVersion && len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version
+	}
+	if version < 0 {
+		version = 0
+	}
+	// roll forward
+	for _, mig := range migrations {
+		if mig.Version > current && mig.Version <= version {
+			if err = s.applyMigration(ctx, mig, true); err != nil {
+				return err
+			}
+		}
+	}
+	// roll back
+	for i := len(migrations) - 1; i >= 0; i-- {
+		mig := migrations[i]
+		if mig.Version <= current && mig.Version > version {
+			if err = s.applyMigration(ctx, mig, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyMigration runs the up or down script of mig in a transaction.
+//
+// On MySQL each DDL statement commits implicitly, so a migration failing
+// partway keeps its statements before the failure, without its
+// schema_version row. Statements of a rerun which fail because their change
+// is already there are skipped (see alreadyApplied), so that the migration
+// can be rerun once the cause of the failure is fixed.
+func (s *sqlStore) applyMigration(ctx context.Context, mig *Migration, up bool) (err error) {
+	script := mig.Down
+	if up {
+		script = mig.Up
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	for _, stmt := range splitStatements(script) {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			if s.dialect == dialectMySQL && alreadyApplied(err) {
+				err = nil
+				continue
+			}
+			return fmt.Errorf("core: migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	if hook := migrationHooks[mig.Version]; up && hook != nil {
+		if err = hook(ctx, tx); err != nil {
+			return fmt.Errorf("core: migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "insert into schema_version (version, name, applied_at) values (?, ?, ?)", mig.Version, mig.Name, time.Now())
+	} else {
+		_, err = tx.ExecContext(ctx, "delete from schema_version where version = ?", mig.Version)
+	}
+	if err != nil {
+		return
+	}
+	return tx.Commit()
+}
+
+// alreadyApplied reports whether err is a MySQL error of a DDL statement
+// whose change is already in the schema: a table, column or index which
+// exists, or a column or index to drop which doesn't.
+func alreadyApplied(err error) bool {
+	var e *mysql.MySQLError
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.Number {
+	case 1050, // ER_TABLE_EXISTS_ERROR
+		1060, // ER_DUP_FIELDNAME
+		1061, // ER_DUP_KEYNAME
+		1091: // ER_CANT_DROP_FIELD_OR_KEY
+		return true
+	}
+	return false
+}
+
+// backfillTags fills the tags of the articles saved before the tag tables
+// were added.
+func backfillTags(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "select id, tags from article where tags <> ''")
+	if err != nil {
+		return err
+	}
+	tags := make(map[string]string)
+	for rows.Next() {
+		var id, t string
+		if err = rows.Scan(&id, &t); err != nil {
+			rows.Close()
+			return err
+		}
+		tags[id] = t
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for id, t := range tags {
+		if err = setArticleTags(ctx, tx, id, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	mysql, err := loadMigrations(dialectMySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := loadMigrations(dialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mysql) == 0 || len(mysql) != len(sqlite) {
+		t.Fatalf("loadMigrations returned %d mysql and %d sqlite migrations", len(mysql), len(sqlite))
+	}
+	for i, mig := range mysql {
+		if mig.Version != i+1 {
+			t.Errorf("migration %s has version %d, expected: %d", mig.Name, mig.Version, i+1)
+		}
+		if mig.Version != sqlite[i].Version || mig.Name != sqlite[i].Name {
+			t.Errorf("mysql migration %d_%s differs from sqlite %d_%s", mig.Version, mig.Name, sqlite[i].Version, sqlite[i].Name)
+		}
+		for _, m := range []*Migration{mig, sqlite[i]} {
+			if m.Up == "" || m.Down == "" {
+				t.Errorf("migration %d_%s misses its up or down script", m.Version, m.Name)
+			}
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- comment
+create table a (
+	id integer
+);
+
+drop table b;
+insert into c values (';')`
+	want := []string{
+		"create table a (\n\tid integer\n);",
+		"drop table b;",
+		"insert into c values (';')",
+	}
+	if got := splitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements() = %q, expected: %q", got, want)
+	}
+}
+
+func TestAlreadyApplied(t *testing.T) {
+	for _, c := range []struct {
+		err      error
+		expected bool
+	}{
+		{&mysql.MySQLError{Number: 1060, Message: "Duplicate column name 'like_count'"}, true},
+		{fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1061}), true},
+		{&mysql.MySQLError{Number: 1091}, true},
+		{&mysql.MySQLError{Number: 1146, Message: "Table 'article' doesn't exist"}, false},
+		{ErrNotExist, false},
+	} {
+		if ret := alreadyApplied(c.err); ret != c.expected {
+			t.Errorf("alreadyApplied(%v) = %v, expected: %v", c.err, ret, c.expected)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	todo := context.TODO()
+	store, err := OpenStore("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	migrations, _ := loadMigrations(dialectSQLite)
+	latest := migrations[len(migrations)-1].Version
+
+	tests := []struct {
+		version         int
+		expectedVersion int
+	}{
+		{LatestVersion, latest},
+		{LatestVersion, latest}, // no-op
+		{0, 0},
+		{1, 1},
+		{LatestVersion, latest},
+	}
+	for _, tt := range tests {
+		if err = store.Migrate(todo, tt.version); err != nil {
+			t.Fatalf("Migrate(%d) returned error: %v", tt.version, err)
+		}
+		version, err := store.SchemaVersion(todo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != tt.expectedVersion {
+			t.Errorf("Migrate(%d) left schema at version %d, expected: %d", tt.version, version, tt.expectedVersion)
+		}
+	}
+
+	// the schema works after rolling back and forward again
+	if _, err = store.InsertArticle(todo, &Article{ArticleEntry: ArticleEntry{Title: "Test", UId: "1"}}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Migrate(todo, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.CountArticles(todo, &ArticleFilter{}); err == nil {
+		t.Errorf("CountArticles() succeeded after rolling back all migrations")
+	}
+}
+
+func TestMigrateBackfill(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+
+	// articles saved before the tag tables were added
+	if err := store.Migrate(todo, 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.InsertArticle(todo, &Article{ArticleEntry: ArticleEntry{Title: "Test", Tags: "Go+, yap"}}, 0); err == nil {
+		t.Fatal("InsertArticle() succeeded without the tag tables")
+	}
+	db := store.(*sqlStore).db
+	_, err := db.ExecContext(todo, "insert into article (title, tags, status, ctime, mtime) values ('Test', 'Go+, yap', 1, ?, ?)", time.Now(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Migrate(todo, LatestVersion); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := store.ListTags(todo, nil)
+	if err != nil || len(tags) != 2 {
+		t.Errorf("ListTags() returned %v, %v, expected: Go+ and yap", tags, err)
+	}
+	total, err := store.CountArticles(todo, &ArticleFilter{Search: "yap"})
+	if err != nil || total != 1 {
+		t.Errorf("CountArticles(search yap) returned %d, %v, expected: 1", total, err)
+	}
+}
@@ -0,0 +1,2 @@
+drop table if exists file;
+drop table if exists article;
@@ -0,0 +1,29 @@
+create table if not exists article (
+	id bigint not null auto_increment,
+	title varchar(255) not null default '',
+	user_id varchar(64) not null default '',
+	cover varchar(512) not null default '',
+	tags varchar(255) not null default '',
+	abstract varchar(1024) not null default '',
+	content longtext not null,
+	trans_content longtext,
+	html_id bigint not null default 0,
+	trans_html_id bigint not null default 0,
+	ctime datetime not null,
+	mtime datetime not null,
+	primary key (id),
+	key idx_article_user_id (user_id),
+	key idx_article_ctime (ctime)
+) engine=InnoDB default charset=utf8mb4;
+
+create table if not exists file (
+	id bigint not null auto_increment,
+	file_key varchar(255) not null default '',
+	format varchar(255) not null default '',
+	size bigint not null default 0,
+	user_id varchar(64) not null default '',
+	create_at datetime not null,
+	update_at datetime not null,
+	primary key (id),
+	key idx_file_user_id (user_id)
+) engine=InnoDB default charset=utf8mb4;
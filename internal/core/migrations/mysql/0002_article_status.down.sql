@@ -0,0 +1,2 @@
+drop index idx_article_status on article;
+alter table article drop column status;
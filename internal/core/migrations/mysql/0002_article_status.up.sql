@@ -0,0 +1,2 @@
+alter table article add column status tinyint not null default 1;
+create index idx_article_status on article (status);
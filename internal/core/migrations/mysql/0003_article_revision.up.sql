@@ -0,0 +1,18 @@
+create table if not exists article_revision (
+	id bigint not null auto_increment,
+	article_id bigint not null,
+	user_id varchar(64) not null default '',
+	title varchar(255) not null default '',
+	tags varchar(255) not null default '',
+	content longtext not null,
+	html_id bigint not null default 0,
+	ctime datetime not null,
+	primary key (id),
+	key idx_article_revision_article_id (article_id)
+) engine=InnoDB default charset=utf8mb4;
+
+-- the current content of existing articles is their first revision, unless
+-- a rerun finds it already there
+insert into article_revision (article_id, user_id, title, tags, content, html_id, ctime)
+select id, user_id, title, tags, content, html_id, mtime from article
+where not exists (select 1 from article_revision where article_id = article.id);
@@ -0,0 +1,2 @@
+drop index idx_article_status_publish_at on article;
+alter table article drop column publish_at;
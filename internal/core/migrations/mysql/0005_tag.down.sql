@@ -0,0 +1,2 @@
+drop table if exists article_tag;
+drop table if exists tag;
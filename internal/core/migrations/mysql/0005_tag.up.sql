@@ -0,0 +1,13 @@
+create table if not exists tag (
+	id bigint not null auto_increment,
+	name varchar(64) not null,
+	primary key (id),
+	unique key uk_tag_name (name)
+) engine=InnoDB default charset=utf8mb4;
+
+create table if not exists article_tag (
+	article_id bigint not null,
+	tag_id bigint not null,
+	primary key (article_id, tag_id),
+	key idx_article_tag_tag_id (tag_id)
+) engine=InnoDB default charset=utf8mb4;
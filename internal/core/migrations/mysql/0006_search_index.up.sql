@@ -0,0 +1,8 @@
+-- terms are lowercased by the tokenizer and compared byte by byte
+create table if not exists search_index (
+	term varchar(64) not null,
+	article_id bigint not null,
+	weight int not null default 0,
+	primary key (term, article_id),
+	key idx_search_index_article_id (article_id)
+) engine=InnoDB default charset=utf8mb4 collate=utf8mb4_bin;
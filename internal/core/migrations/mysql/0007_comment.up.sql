@@ -0,0 +1,15 @@
+create table if not exists comment (
+	id bigint not null auto_increment,
+	article_id bigint not null,
+	parent_id bigint not null default 0,
+	root_id bigint not null default 0,
+	user_id varchar(64) not null default '',
+	content text not null,
+	html text not null,
+	deleted tinyint not null default 0,
+	ctime datetime not null,
+	mtime datetime not null,
+	primary key (id),
+	key idx_comment_article_id (article_id, root_id),
+	key idx_comment_root_id (root_id)
+) engine=InnoDB default charset=utf8mb4;
@@ -0,0 +1,19 @@
+alter table article add column like_count int not null default 0;
+alter table article add column bookmark_count int not null default 0;
+alter table article add column view_count bigint not null default 0;
+
+create table if not exists article_like (
+	article_id bigint not null,
+	user_id varchar(64) not null,
+	ctime datetime not null,
+	primary key (article_id, user_id),
+	key idx_article_like_user_id (user_id)
+) engine=InnoDB default charset=utf8mb4;
+
+create table if not exists article_bookmark (
+	article_id bigint not null,
+	user_id varchar(64) not null,
+	ctime datetime not null,
+	primary key (article_id, user_id),
+	key idx_article_bookmark_user_id (user_id)
+) engine=InnoDB default charset=utf8mb4;
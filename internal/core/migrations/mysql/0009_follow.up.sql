@@ -0,0 +1,7 @@
+create table if not exists follow (
+	user_id varchar(64) not null,
+	followee_id varchar(64) not null,
+	ctime datetime not null,
+	primary key (user_id, followee_id),
+	key idx_follow_followee_id (followee_id)
+) engine=InnoDB default charset=utf8mb4;
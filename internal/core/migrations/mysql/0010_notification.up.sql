@@ -0,0 +1,12 @@
+create table if not exists notification (
+	id bigint not null auto_increment,
+	user_id varchar(64) not null,
+	actor_id varchar(64) not null,
+	kind varchar(16) not null,
+	article_id bigint not null default 0,
+	comment_id bigint not null default 0,
+	is_read tinyint not null default 0,
+	ctime datetime not null,
+	primary key (id),
+	key idx_notification_user_id (user_id, is_read)
+) engine=InnoDB default charset=utf8mb4;
@@ -0,0 +1,8 @@
+create table if not exists notification_pref (
+	user_id varchar(64) not null,
+	email_replies tinyint not null default 1,
+	email_digest tinyint not null default 0,
+	digest_time datetime null,
+	mtime datetime not null,
+	primary key (user_id)
+) engine=InnoDB default charset=utf8mb4;
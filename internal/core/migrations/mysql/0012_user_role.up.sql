@@ -0,0 +1,6 @@
+create table if not exists user_role (
+	user_id varchar(64) not null,
+	role tinyint not null,
+	mtime datetime not null,
+	primary key (user_id)
+) engine=InnoDB default charset=utf8mb4;
@@ -0,0 +1,5 @@
+drop table if exists user_ban;
+drop table if exists audit_log;
+drop table if exists report;
+alter table comment drop column hidden;
+alter table article drop column hidden;
@@ -0,0 +1,35 @@
+alter table article add column hidden tinyint not null default 0;
+alter table comment add column hidden tinyint not null default 0;
+
+create table if not exists report (
+	id bigint not null auto_increment,
+	reporter_id varchar(64) not null,
+	kind varchar(16) not null,
+	target_id bigint not null,
+	reason text not null,
+	status varchar(16) not null default 'open',
+	ctime datetime not null,
+	mtime datetime not null,
+	primary key (id),
+	key idx_report_status (status, ctime),
+	key idx_report_target (kind, target_id)
+) engine=InnoDB default charset=utf8mb4;
+
+create table if not exists audit_log (
+	id bigint not null auto_increment,
+	actor_id varchar(64) not null,
+	action varchar(16) not null,
+	kind varchar(16) not null,
+	target_id varchar(64) not null,
+	detail text not null,
+	ctime datetime not null,
+	primary key (id)
+) engine=InnoDB default charset=utf8mb4;
+
+create table if not exists user_ban (
+	user_id varchar(64) not null,
+	actor_id varchar(64) not null,
+	reason text not null,
+	ctime datetime not null,
+	primary key (user_id)
+) engine=InnoDB default charset=utf8mb4;
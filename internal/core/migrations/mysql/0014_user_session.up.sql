@@ -0,0 +1,12 @@
+create table if not exists user_session (
+	id varchar(64) not null,
+	user_id varchar(64) not null,
+	access_token text not null,
+	refresh_token text not null,
+	expiry datetime not null,
+	ctime datetime not null,
+	mtime datetime not null,
+	primary key (id),
+	key idx_user_session_user (user_id),
+	key idx_user_session_mtime (mtime)
+) engine=InnoDB default charset=utf8mb4;
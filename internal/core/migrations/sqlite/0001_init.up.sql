@@ -0,0 +1,27 @@
+create table if not exists article (
+	id integer primary key autoincrement,
+	title varchar(255) not null default '',
+	user_id varchar(64) not null default '',
+	cover varchar(512) not null default '',
+	tags varchar(255) not null default '',
+	abstract varchar(1024) not null default '',
+	content text not null default '',
+	trans_content text not null default '',
+	html_id integer not null default 0,
+	trans_html_id integer not null default 0,
+	ctime datetime not null,
+	mtime datetime not null
+);
+create index if not exists idx_article_user_id on article (user_id);
+create index if not exists idx_article_ctime on article (ctime);
+
+create table if not exists file (
+	id integer primary key autoincrement,
+	file_key varchar(255) not null default '',
+	format varchar(255) not null default '',
+	size integer not null default 0,
+	user_id varchar(64) not null default '',
+	create_at datetime not null,
+	update_at datetime not null
+);
+create index if not exists idx_file_user_id on file (user_id);
@@ -0,0 +1,17 @@
+create table if not exists article_revision (
+	id integer primary key autoincrement,
+	article_id integer not null,
+	user_id varchar(64) not null default '',
+	title varchar(255) not null default '',
+	tags varchar(255) not null default '',
+	content text not null default '',
+	html_id integer not null default 0,
+	ctime datetime not null
+);
+create index if not exists idx_article_revision_article_id on article_revision (article_id);
+
+-- the current content of existing articles is their first revision, unless
+-- a rerun finds it already there
+insert into article_revision (article_id, user_id, title, tags, content, html_id, ctime)
+select id, user_id, title, tags, content, html_id, mtime from article
+where not exists (select 1 from article_revision where article_id = article.id);
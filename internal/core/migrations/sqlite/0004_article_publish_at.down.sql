@@ -0,0 +1,2 @@
+drop index idx_article_status_publish_at;
+alter table article drop column publish_at;
@@ -0,0 +1,2 @@
+alter table article add column publish_at datetime null;
+create index idx_article_status_publish_at on article (status, publish_at);
@@ -0,0 +1,11 @@
+create table if not exists tag (
+	id integer primary key autoincrement,
+	name varchar(64) not null collate nocase unique
+);
+
+create table if not exists article_tag (
+	article_id integer not null,
+	tag_id integer not null,
+	primary key (article_id, tag_id)
+);
+create index if not exists idx_article_tag_tag_id on article_tag (tag_id);
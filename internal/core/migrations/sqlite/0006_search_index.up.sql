@@ -0,0 +1,7 @@
+create table if not exists search_index (
+	term varchar(64) not null,
+	article_id integer not null,
+	weight integer not null default 0,
+	primary key (term, article_id)
+);
+create index if not exists idx_search_index_article_id on search_index (article_id);
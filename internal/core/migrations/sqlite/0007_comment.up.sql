@@ -0,0 +1,14 @@
+create table if not exists comment (
+	id integer primary key autoincrement,
+	article_id integer not null,
+	parent_id integer not null default 0,
+	root_id integer not null default 0,
+	user_id varchar(64) not null default '',
+	content text not null default '',
+	html text not null default '',
+	deleted integer not null default 0,
+	ctime datetime not null,
+	mtime datetime not null
+);
+create index if not exists idx_comment_article_id on comment (article_id, root_id);
+create index if not exists idx_comment_root_id on comment (root_id);
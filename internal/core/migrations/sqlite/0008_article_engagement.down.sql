@@ -0,0 +1,5 @@
+drop table if exists article_bookmark;
+drop table if exists article_like;
+alter table article drop column view_count;
+alter table article drop column bookmark_count;
+alter table article drop column like_count;
@@ -0,0 +1,19 @@
+alter table article add column like_count integer not null default 0;
+alter table article add column bookmark_count integer not null default 0;
+alter table article add column view_count integer not null default 0;
+
+create table if not exists article_like (
+	article_id integer not null,
+	user_id varchar(64) not null,
+	ctime datetime not null,
+	primary key (article_id, user_id)
+);
+create index if not exists idx_article_like_user_id on article_like (user_id);
+
+create table if not exists article_bookmark (
+	article_id integer not null,
+	user_id varchar(64) not null,
+	ctime datetime not null,
+	primary key (article_id, user_id)
+);
+create index if not exists idx_article_bookmark_user_id on article_bookmark (user_id);
@@ -0,0 +1,7 @@
+create table if not exists follow (
+	user_id varchar(64) not null,
+	followee_id varchar(64) not null,
+	ctime datetime not null,
+	primary key (user_id, followee_id)
+);
+create index if not exists idx_follow_followee_id on follow (followee_id);
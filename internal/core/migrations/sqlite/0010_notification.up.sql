@@ -0,0 +1,11 @@
+create table if not exists notification (
+	id integer primary key autoincrement,
+	user_id varchar(64) not null,
+	actor_id varchar(64) not null,
+	kind varchar(16) not null,
+	article_id integer not null default 0,
+	comment_id integer not null default 0,
+	is_read integer not null default 0,
+	ctime datetime not null
+);
+create index if not exists idx_notification_user_id on notification (user_id, is_read);
@@ -0,0 +1,7 @@
+create table if not exists notification_pref (
+	user_id varchar(64) not null primary key,
+	email_replies integer not null default 1,
+	email_digest integer not null default 0,
+	digest_time datetime null,
+	mtime datetime not null
+);
@@ -0,0 +1,5 @@
+create table if not exists user_role (
+	user_id varchar(64) not null primary key,
+	role integer not null,
+	mtime datetime not null
+);
@@ -0,0 +1,7 @@
+drop table if exists user_ban;
+drop table if exists audit_log;
+drop index if exists idx_report_target;
+drop index if exists idx_report_status;
+drop table if exists report;
+alter table comment drop column hidden;
+alter table article drop column hidden;
@@ -0,0 +1,32 @@
+alter table article add column hidden integer not null default 0;
+alter table comment add column hidden integer not null default 0;
+
+create table if not exists report (
+	id integer primary key autoincrement,
+	reporter_id varchar(64) not null,
+	kind varchar(16) not null,
+	target_id integer not null,
+	reason text not null default '',
+	status varchar(16) not null default 'open',
+	ctime datetime not null,
+	mtime datetime not null
+);
+create index if not exists idx_report_status on report (status, ctime);
+create index if not exists idx_report_target on report (kind, target_id);
+
+create table if not exists audit_log (
+	id integer primary key autoincrement,
+	actor_id varchar(64) not null,
+	action varchar(16) not null,
+	kind varchar(16) not null,
+	target_id varchar(64) not null,
+	detail text not null default '',
+	ctime datetime not null
+);
+
+create table if not exists user_ban (
+	user_id varchar(64) not null primary key,
+	actor_id varchar(64) not null,
+	reason text not null default '',
+	ctime datetime not null
+);
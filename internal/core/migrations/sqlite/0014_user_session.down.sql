@@ -0,0 +1,3 @@
+drop index if exists idx_user_session_mtime;
+drop index if exists idx_user_session_user;
+drop table if exists user_session;
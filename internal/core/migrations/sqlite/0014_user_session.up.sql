@@ -0,0 +1,11 @@
+create table if not exists user_session (
+	id varchar(64) not null primary key,
+	user_id varchar(64) not null,
+	access_token text not null,
+	refresh_token text not null,
+	expiry datetime not null,
+	ctime datetime not null,
+	mtime datetime not null
+);
+create index if not exists idx_user_session_user on user_session (user_id);
+create index if not exists idx_user_session_mtime on user_session (mtime);
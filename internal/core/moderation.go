@@ -0,0 +1,370 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Kinds of the targets of reports and of the audit log.
+const (
+	KindArticle = "article"
+	KindComment = "comment"
+	KindUser    = "user"
+)
+
+// Statuses of reports.
+const (
+	ReportOpen      = "open"      // waiting for a moderator
+	ReportResolved  = "resolved"  // the content was hidden or deleted
+	ReportDismissed = "dismissed" // the content was found fine
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditHide    = "hide"
+	AuditRestore = "restore"
+	AuditDelete  = "delete"
+	AuditDismiss = "dismiss"
+	AuditBan     = "ban"
+	AuditUnban   = "unban"
+	AuditRole    = "role"
+)
+
+// MaxReasonLen is the maximum length of the reason of a report in runes.
+const MaxReasonLen = 1000
+
+var (
+	errEmptyReason     = errors.New("core: empty reason")
+	errReasonTooLong   = errors.New("core: reason too long")
+	errInvalidKind     = errors.New("core: invalid kind")
+	errInvalidStatus   = errors.New("core: invalid report status")
+	errInvalidModerate = errors.New("core: invalid moderation")
+)
+
+// Report is a report of an abusive article or comment by a user.
+type Report struct {
+	ID         string
+	ReporterID string
+	Reporter   User
+	Kind       string // KindArticle or KindComment
+	TargetID   string
+	Reason     string
+	Status     string
+	Ctime      time.Time
+
+	// the reported content as it is now
+	ArticleID string // the article of the comment for KindComment
+	AuthorID  string
+	Author    User
+	Text      string // the title of the article or the content of the comment, "" once deleted
+	Hidden    bool
+}
+
+// AuditEntry records a moderation.
+type AuditEntry struct {
+	ID       string
+	ActorID  string
+	Actor    User
+	Action   string
+	Kind     string
+	TargetID string
+	Detail   string // the note of the moderator, or the new role for AuditRole
+	Ctime    time.Time
+}
+
+// Ban bars a user from all that is subject to the policy.
+type Ban struct {
+	UId     string
+	ActorID string
+	Reason  string
+	Ctime   time.Time
+}
+
+// contentOf returns the author of article or comment id of kind, and the
+// article of it.
+func (p *Community) contentOf(ctx context.Context, kind, id string) (author string, article *Article, err error) {
+	switch kind {
+	case KindArticle:
+		article, _, err = p.store.GetArticle(ctx, id)
+		return article.UId, article, err
+	case KindComment:
+		c, err := p.store.GetComment(ctx, id)
+		if err != nil {
+			return "", &Article{}, err
+		}
+		if c.Deleted {
+			return "", &Article{}, ErrNotExist
+		}
+		article, _, err = p.store.GetArticle(ctx, c.ArticleID)
+		return c.UId, article, err
+	}
+	return "", &Article{}, errInvalidKind
+}
+
+// ReportContent reports article or comment id of kind by uid with a reason.
+// Reporting it again before it is reviewed is a no-op.
+func (p *Community) ReportContent(ctx context.Context, uid, kind, id, reason string) error {
+	author, article, err := p.contentOf(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	if !article.VisibleTo(uid) {
+		return ErrNotExist
+	}
+	if err = p.authorize(ctx, uid, ActionReport, author); err != nil {
+		return err
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return errEmptyReason
+	}
+	if utf8.RuneCountInString(reason) > MaxReasonLen {
+		return errReasonTooLong
+	}
+	_, err = p.store.InsertReport(ctx, &Report{ReporterID: uid, Kind: kind, TargetID: id, Reason: reason})
+	return err
+}
+
+// Reports lists the reports in status ("" for ReportOpen) from a position,
+// which is MarkBegin or the next cursor of the previous page, newest first.
+// Only moderators (uid) may list them.
+func (p *Community) Reports(ctx context.Context, uid, status, from string, limit int) (items []*Report, next string, err error) {
+	if status == "" {
+		status = ReportOpen
+	}
+	if status != ReportOpen && status != ReportResolved && status != ReportDismissed {
+		return []*Report{}, from, errInvalidStatus
+	}
+	if err = p.authorize(ctx, uid, ActionModerate, ""); err != nil {
+		return []*Report{}, from, err
+	}
+	if from == MarkEnd || limit <= 0 {
+		return []*Report{}, MarkEnd, nil
+	}
+	var c *Cursor
+	if from != MarkBegin {
+		if c, err = ParseCursor(from); err != nil || c.Backward {
+			return []*Report{}, from, errInvalidCursor
+		}
+	}
+	items, err = p.store.ListReports(ctx, status, c, limit+1)
+	if err != nil {
+		return []*Report{}, from, err
+	}
+	next = MarkEnd
+	if len(items) > limit {
+		items = items[:limit]
+		last := items[limit-1]
+		lastId, _ := strconv.ParseInt(last.ID, 10, 64)
+		next = (&Cursor{Ctime: last.Ctime, ID: lastId}).String()
+	}
+	if len(items) == 0 {
+		return items, MarkEnd, io.EOF
+	}
+	uids := make([]string, 0, 2*len(items))
+	for _, r := range items {
+		uids = append(uids, r.ReporterID, r.AuthorID)
+	}
+	users := p.authorsOf(uids)
+	for _, r := range items {
+		r.Reporter = users[r.ReporterID]
+		r.Author = users[r.AuthorID]
+	}
+	return items, next, nil
+}
+
+// CountReports counts the reports in status. Only moderators (uid) may
+// count them.
+func (p *Community) CountReports(ctx context.Context, uid, status string) (int, error) {
+	if err := p.authorize(ctx, uid, ActionModerate, ""); err != nil {
+		return 0, err
+	}
+	return p.store.CountReports(ctx, status)
+}
+
+// Moderate hides, restores, deletes or dismisses the reports on article or
+// comment id of kind by moderator uid with a note, and records it in the
+// audit log. The open reports on the content are resolved by hiding or
+// deleting it.
+func (p *Community) Moderate(ctx context.Context, uid, kind, id, action, note string) error {
+	if kind != KindArticle && kind != KindComment {
+		return errInvalidKind
+	}
+	if action == AuditDelete {
+		if kind == KindArticle {
+			return p.deleteArticle(ctx, uid, id, note)
+		}
+		return p.deleteComment(ctx, uid, id, note)
+	}
+	author, _, err := p.contentOf(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	if err = p.authorize(ctx, uid, ActionModerate, author); err != nil {
+		return err
+	}
+	switch action {
+	case AuditHide, AuditRestore:
+		if err = p.store.SetHidden(ctx, kind, id, action == AuditHide); err != nil {
+			return err
+		}
+		if action == AuditHide {
+			p.resolveReports(ctx, kind, id, ReportResolved)
+		}
+	case AuditDismiss:
+		if err = p.store.ResolveReports(ctx, kind, id, ReportDismissed); err != nil {
+			return err
+		}
+	default:
+		return errInvalidModerate
+	}
+	p.audit(ctx, uid, action, kind, id, note)
+	return nil
+}
+
+// resolveReports closes the open reports on the content id of kind after it
+// is moderated. Failures are logged only, as the content is moderated.
+func (p *Community) resolveReports(ctx context.Context, kind, id, status string) {
+	if err := p.store.ResolveReports(ctx, kind, id, status); err != nil {
+		p.xLog.Error("resolve reports error:", err)
+	}
+}
+
+// Ban bans user target by moderator uid with a reason. Moderators may not
+// ban their peers or superiors.
+func (p *Community) Ban(ctx context.Context, uid, target, reason string) error {
+	if err := p.authorize(ctx, uid, ActionBanUser, target); err != nil {
+		return err
+	}
+	if err := p.outranks(ctx, uid, target); err != nil {
+		return err
+	}
+	reason = strings.TrimSpace(reason)
+	if err := p.store.SetBan(ctx, target, &Ban{UId: target, ActorID: uid, Reason: reason}); err != nil {
+		return err
+	}
+	p.audit(ctx, uid, AuditBan, KindUser, target, reason)
+	return nil
+}
+
+// Unban lifts the ban of user target by moderator uid with a note. Like
+// Ban, moderators may not unban their peers or superiors, nor lift the bans
+// set by their superiors.
+func (p *Community) Unban(ctx context.Context, uid, target, note string) error {
+	if err := p.authorize(ctx, uid, ActionBanUser, target); err != nil {
+		return err
+	}
+	if err := p.outranks(ctx, uid, target); err != nil {
+		return err
+	}
+	ban, err := p.store.GetBan(ctx, target)
+	if err == nil {
+		role, _ := p.Role(ctx, uid)
+		actorRole, err := p.Role(ctx, ban.ActorID)
+		if err != nil {
+			return err
+		}
+		if actorRole > role {
+			return ErrPermission
+		}
+	} else if err != ErrNotExist {
+		return err
+	}
+	if err = p.store.SetBan(ctx, target, nil); err != nil {
+		return err
+	}
+	p.audit(ctx, uid, AuditUnban, KindUser, target, strings.TrimSpace(note))
+	return nil
+}
+
+// outranks checks that the role of uid is above that of user target.
+func (p *Community) outranks(ctx context.Context, uid, target string) error {
+	role, err := p.Role(ctx, uid)
+	if err != nil {
+		return err
+	}
+	targetRole, err := p.Role(ctx, target)
+	if err != nil {
+		return err
+	}
+	if targetRole >= role {
+		return ErrPermission
+	}
+	return nil
+}
+
+// GetBan returns the ban of user uid, ErrNotExist if not banned.
+func (p *Community) GetBan(ctx context.Context, uid string) (*Ban, error) {
+	return p.store.GetBan(ctx, uid)
+}
+
+// AuditLog lists the audit log from a position, which is MarkBegin or the
+// next cursor of the previous page, newest first. Only moderators (uid) may
+// list it.
+func (p *Community) AuditLog(ctx context.Context, uid, from string, limit int) (items []*AuditEntry, next string, err error) {
+	if err = p.authorize(ctx, uid, ActionModerate, ""); err != nil {
+		return []*AuditEntry{}, from, err
+	}
+	if from == MarkEnd || limit <= 0 {
+		return []*AuditEntry{}, MarkEnd, nil
+	}
+	var c *Cursor
+	if from != MarkBegin {
+		if c, err = ParseCursor(from); err != nil || c.Backward {
+			return []*AuditEntry{}, from, errInvalidCursor
+		}
+	}
+	items, err = p.store.ListAudit(ctx, c, limit+1)
+	if err != nil {
+		return []*AuditEntry{}, from, err
+	}
+	next = MarkEnd
+	if len(items) > limit {
+		items = items[:limit]
+		last := items[limit-1]
+		lastId, _ := strconv.ParseInt(last.ID, 10, 64)
+		next = (&Cursor{Ctime: last.Ctime, ID: lastId}).String()
+	}
+	if len(items) == 0 {
+		return items, MarkEnd, io.EOF
+	}
+	uids := make([]string, len(items))
+	for i, e := range items {
+		uids[i] = e.ActorID
+	}
+	actors := p.authorsOf(uids)
+	for _, e := range items {
+		e.Actor = actors[e.ActorID]
+	}
+	return items, next, nil
+}
+
+// audit records a moderation by uid in the audit log. Failures are logged
+// only, as the moderation is done.
+func (p *Community) audit(ctx context.Context, uid, action, kind, id, detail string) {
+	e := &AuditEntry{ActorID: uid, Action: action, Kind: kind, TargetID: id, Detail: detail}
+	if err := p.store.InsertAudit(ctx, e); err != nil {
+		p.xLog.Error("audit error:", err)
+	}
+}
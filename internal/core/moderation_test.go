@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goplus/yap"
+)
+
+func TestReportContent(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	id := putTestArticle(t, community, "1", "Test")
+	community.SetArticleStatus(todo, "1", id, StatusPublished)
+	draft := putTestArticle(t, community, "1", "Draft")
+	c, err := community.PutComment(todo, "1", id, "", "Spam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, _ := community.PutComment(todo, "1", id, "", "Deleted")
+	community.DeleteComment(todo, "1", deleted.ID)
+
+	tests := []struct {
+		uid, kind, id, reason string
+		expectedErr           error
+	}{
+		{"", KindArticle, id, "spam", ErrPermission},
+		{"2", KindArticle, id, "  ", errEmptyReason},
+		{"2", KindArticle, id, strings.Repeat("a", MaxReasonLen+1), errReasonTooLong},
+		{"2", KindArticle, "100", "spam", ErrNotExist},
+		{"2", KindArticle, draft, "spam", ErrNotExist},
+		{"2", KindComment, deleted.ID, "spam", ErrNotExist},
+		{"2", KindUser, "1", "spam", errInvalidKind},
+		{"2", KindArticle, id, "spam", nil},
+		{"2", KindArticle, id, "again", nil}, // no-op while open
+		{"3", KindComment, c.ID, "spam", nil},
+	}
+	for _, tt := range tests {
+		if err := community.ReportContent(todo, tt.uid, tt.kind, tt.id, tt.reason); err != tt.expectedErr {
+			t.Errorf("ReportContent(%s, %s, %s) returned err: %v, expected: %v", tt.uid, tt.kind, tt.id, err, tt.expectedErr)
+		}
+	}
+
+	community.store.SetRole(todo, "4", RoleModerator)
+	if _, _, err = community.Reports(todo, "2", "", MarkBegin, 10); err != ErrPermission {
+		t.Errorf("Reports() by a reader returned err: %v, expected: %v", err, ErrPermission)
+	}
+	items, next, err := community.Reports(todo, "4", "", MarkBegin, 10)
+	if err != nil || next != MarkEnd || len(items) != 2 {
+		t.Fatalf("Reports() returned %d items, %q, %v", len(items), next, err)
+	}
+	r := items[1]
+	if r.Kind != KindArticle || r.TargetID != id || r.Reason != "spam" || r.Reporter.Name != "user2" ||
+		r.ArticleID != id || r.AuthorID != "1" || r.Author.Name != "user1" || r.Text != "Test" {
+		t.Errorf("Reports() returned %+v", r)
+	}
+	if r = items[0]; r.Kind != KindComment || r.ArticleID != id || r.Text != "Spam" {
+		t.Errorf("Reports() returned %+v", r)
+	}
+	if n, _ := community.CountReports(todo, "4", ReportOpen); n != 2 {
+		t.Errorf("CountReports(open) returned %d, expected: 2", n)
+	}
+	if _, _, err = community.Reports(todo, "4", "closed", MarkBegin, 10); err != errInvalidStatus {
+		t.Errorf("Reports(closed) returned err: %v, expected: %v", err, errInvalidStatus)
+	}
+}
+
+func TestModerate(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	community.store.SetRole(todo, "3", RoleModerator)
+	id := putTestArticle(t, community, "1", "Test")
+	community.SetArticleStatus(todo, "1", id, StatusPublished)
+	c, err := community.PutComment(todo, "2", id, "", "Spam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	community.ReportContent(todo, "2", KindArticle, id, "spam")
+	community.ReportContent(todo, "1", KindComment, c.ID, "spam")
+
+	if err = community.Moderate(todo, "2", KindArticle, id, AuditHide, ""); err != ErrPermission {
+		t.Errorf("Moderate() by a reader returned err: %v, expected: %v", err, ErrPermission)
+	}
+	if err = community.Moderate(todo, "3", KindArticle, id, "ignore", ""); err != errInvalidModerate {
+		t.Errorf("Moderate(ignore) returned err: %v, expected: %v", err, errInvalidModerate)
+	}
+	if err = community.Moderate(todo, "3", KindUser, "1", AuditDelete, ""); err != errInvalidKind {
+		t.Errorf("Moderate(%s, delete) returned err: %v, expected: %v", KindUser, err, errInvalidKind)
+	}
+
+	// hidden articles are visible to their authors only
+	if err = community.Moderate(todo, "3", KindArticle, id, AuditHide, "off-topic"); err != nil {
+		t.Fatal(err)
+	}
+	article, _ := community.Article(todo, id)
+	if !article.Hidden || article.VisibleTo("2") || !article.VisibleTo("1") {
+		t.Errorf("Article() after hiding returned %+v", article.ArticleEntry)
+	}
+	if items, _, _, _ := community.ListArticle(todo, MarkBegin, 10, "", ""); len(items) != 0 {
+		t.Errorf("ListArticle() listed %d hidden articles", len(items))
+	}
+	if items, _, _, _ := community.GetArticlesByUid(todo, "1", "1", MarkBegin, 10); len(items) != 1 || !items[0].Hidden {
+		t.Errorf("GetArticlesByUid() by the author returned %+v", items)
+	}
+	if n, _ := community.CountReports(todo, "3", ReportResolved); n != 1 {
+		t.Errorf("CountReports(resolved) returned %d after hiding, expected: 1", n)
+	}
+	if err = community.Moderate(todo, "3", KindArticle, id, AuditRestore, ""); err != nil {
+		t.Fatal(err)
+	}
+	if items, _, _, _ := community.ListArticle(todo, MarkBegin, 10, "", ""); len(items) != 1 {
+		t.Errorf("ListArticle() listed %d articles after restoring, expected: 1", len(items))
+	}
+
+	// hidden comments are listed without content while they have replies
+	reply, _ := community.PutComment(todo, "1", id, c.ID, "Reply")
+	if err = community.Moderate(todo, "3", KindComment, c.ID, AuditHide, ""); err != nil {
+		t.Fatal(err)
+	}
+	items, _, _ := community.ListComments(todo, id, MarkBegin, 10, "")
+	if len(items) != 1 || !items[0].Hidden || items[0].Content != "" || len(items[0].Replies) != 1 {
+		t.Errorf("ListComments() after hiding returned %+v", items)
+	}
+	if n, _ := community.CountComments(todo, id); n != 1 {
+		t.Errorf("CountComments() after hiding returned %d, expected: 1", n)
+	}
+	community.ReportContent(todo, "2", KindComment, reply.ID, "rude")
+	if err = community.Moderate(todo, "3", KindComment, reply.ID, AuditDismiss, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = community.Moderate(todo, "3", KindComment, reply.ID, AuditDelete, "rude"); err != nil {
+		t.Fatal(err)
+	}
+	if items, _, err = community.ListComments(todo, id, MarkBegin, 10, ""); len(items) != 0 || err != nil {
+		t.Errorf("ListComments() after deleting returned %+v, %v", items, err)
+	}
+
+	var actions []string
+	for from := MarkBegin; from != MarkEnd; {
+		entries, next, err := community.AuditLog(todo, "3", from, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.ActorID != "3" || e.Actor.Name != "user3" {
+				t.Errorf("AuditLog() returned %+v", e)
+			}
+			actions = append(actions, e.Action+" "+e.Kind+" "+e.Detail)
+		}
+		from = next
+	}
+	expected := []string{"delete comment rude", "dismiss comment ", "hide comment ", "restore article ", "hide article off-topic"}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("AuditLog() returned %q, expected: %q", actions, expected)
+	}
+	if _, _, err = community.AuditLog(todo, "1", MarkBegin, 10); err != ErrPermission {
+		t.Errorf("AuditLog() by an author returned err: %v, expected: %v", err, ErrPermission)
+	}
+}
+
+func TestBan(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	community.store.SetRole(todo, "3", RoleModerator)
+	community.store.SetRole(todo, "4", RoleModerator)
+	community.store.SetRole(todo, "5", RoleAdmin)
+	id := putTestArticle(t, community, "1", "Test")
+	community.SetArticleStatus(todo, "1", id, StatusPublished)
+
+	if err := community.Ban(todo, "2", "1", "spam"); err != ErrPermission {
+		t.Errorf("Ban() by an author returned err: %v, expected: %v", err, ErrPermission)
+	}
+	if err := community.Ban(todo, "3", "4", "spam"); err != ErrPermission {
+		t.Errorf("Ban() of a moderator by a moderator returned err: %v, expected: %v", err, ErrPermission)
+	}
+	if err := community.Ban(todo, "3", "1", "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if ban, err := community.GetBan(todo, "1"); err != nil || ban.ActorID != "3" || ban.Reason != "spam" {
+		t.Errorf("GetBan(1) returned %+v, %v", ban, err)
+	}
+	// banned users may do nothing subject to the policy
+	if _, err := community.PutComment(todo, "1", id, "", "hi"); err != ErrPermission {
+		t.Errorf("PutComment() by a banned user returned err: %v, expected: %v", err, ErrPermission)
+	}
+	if err := community.DeleteArticle(todo, "1", id); err != ErrPermission {
+		t.Errorf("DeleteArticle() by a banned user returned err: %v, expected: %v", err, ErrPermission)
+	}
+	if _, err := community.LikeArticle(todo, "1", id, true); err != ErrPermission {
+		t.Errorf("LikeArticle() by a banned user returned err: %v, expected: %v", err, ErrPermission)
+	}
+	if err := community.Follow(todo, "1", "2", true); err != ErrPermission {
+		t.Errorf("Follow() by a banned user returned err: %v, expected: %v", err, ErrPermission)
+	}
+	w := httptest.NewRecorder()
+	ctx := &yap.Context{Request: httptest.NewRequest("POST", "/upload", nil), ResponseWriter: w}
+	withUser(ctx, &User{Id: "1"})
+	if UploadFile(ctx, community); w.Code != http.StatusForbidden {
+		t.Errorf("UploadFile() by a banned user responded %d, expected: %d", w.Code, http.StatusForbidden)
+	}
+	if err := community.Unban(todo, "3", "1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := community.GetBan(todo, "1"); err != ErrNotExist {
+		t.Errorf("GetBan(1) after Unban() returned err: %v, expected: %v", err, ErrNotExist)
+	}
+	if _, err := community.PutComment(todo, "1", id, "", "hi"); err != nil {
+		t.Errorf("PutComment() after Unban() returned err: %v", err)
+	}
+	if err := community.Ban(todo, "5", "4", "abuse"); err != nil {
+		t.Errorf("Ban() of a moderator by an admin returned err: %v", err)
+	}
+	if err := community.Unban(todo, "3", "4", ""); err != ErrPermission {
+		t.Errorf("Unban() of a moderator by a moderator returned err: %v, expected: %v", err, ErrPermission)
+	}
+	// the bans set by admins are lifted by admins
+	if err := community.Ban(todo, "5", "2", "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if err := community.Unban(todo, "3", "2", ""); err != ErrPermission {
+		t.Errorf("Unban() of a ban by an admin by a moderator returned err: %v, expected: %v", err, ErrPermission)
+	}
+	if err := community.Unban(todo, "5", "2", ""); err != nil {
+		t.Errorf("Unban() by an admin returned err: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kinds of notifications.
+const (
+	NotifyComment = "comment" // a comment on an article of the recipient
+	NotifyReply   = "reply"   // a reply to a comment of the recipient
+	NotifyLike    = "like"    // a like of an article of the recipient
+	NotifyFollow  = "follow"  // a new follower of the recipient
+)
+
+// Notification tells a user about the activity of another one.
+type Notification struct {
+	ID           string
+	UId          string // the recipient
+	ActorID      string
+	Actor        User
+	Kind         string
+	ArticleID    string // "" for NotifyFollow
+	ArticleTitle string
+	CommentID    string // "" unless NotifyComment or NotifyReply
+	Read         bool
+	Ctime        time.Time
+}
+
+// notify saves notification n, pushes the new unread count of its recipient
+// to the subscribers and mails it. Users aren't notified of their own
+// activity. Failures are logged only, as notifications are best effort.
+func (p *Community) notify(ctx context.Context, n *Notification) {
+	if n.UId == "" || n.UId == n.ActorID {
+		return
+	}
+	added, err := p.store.InsertNotification(ctx, n)
+	if err != nil {
+		p.xLog.Error("notify error:", err)
+		return
+	}
+	if added {
+		p.publishUnread(ctx, n.UId)
+		p.mailNotification(n)
+	}
+}
+
+// publishUnread pushes the unread count of uid to the subscribers.
+func (p *Community) publishUnread(ctx context.Context, uid string) {
+	if !p.notifier.subscribed(uid) {
+		return
+	}
+	unread, err := p.store.CountUnread(ctx, uid)
+	if err != nil {
+		p.xLog.Error("count unread error:", err)
+		return
+	}
+	p.notifier.publish(uid, unread)
+}
+
+// Notifications lists the notifications of uid from a position, which is
+// MarkBegin or the next cursor of the previous page, newest first.
+func (p *Community) Notifications(ctx context.Context, uid, from string, limit int) (items []*Notification, next string, err error) {
+	if uid == "" {
+		return []*Notification{}, from, ErrPermission
+	}
+	if from == MarkEnd || limit <= 0 {
+		return []*Notification{}, MarkEnd, nil
+	}
+	var c *Cursor
+	if from != MarkBegin {
+		if c, err = ParseCursor(from); err != nil || c.Backward {
+			return []*Notification{}, from, errInvalidCursor
+		}
+	}
+	items, err = p.store.ListNotifications(ctx, uid, c, limit+1)
+	if err != nil {
+		return []*Notification{}, from, err
+	}
+	next = MarkEnd
+	if len(items) > limit {
+		items = items[:limit]
+		last := items[limit-1]
+		lastId, _ := strconv.ParseInt(last.ID, 10, 64)
+		next = (&Cursor{Ctime: last.Ctime, ID: lastId}).String()
+	}
+	if len(items) == 0 {
+		return items, MarkEnd, io.EOF
+	}
+	uids := make([]string, len(items))
+	for i, n := range items {
+		uids[i] = n.ActorID
+	}
+	actors := p.authorsOf(uids)
+	for _, n := range items {
+		n.Actor = actors[n.ActorID]
+	}
+	return items, next, nil
+}
+
+// UnreadCount counts the unread notifications of uid.
+func (p *Community) UnreadCount(ctx context.Context, uid string) (int, error) {
+	if uid == "" {
+		return 0, ErrPermission
+	}
+	return p.store.CountUnread(ctx, uid)
+}
+
+// MarkRead marks notifications ids of uid read, or all of them if ids is
+// empty.
+func (p *Community) MarkRead(ctx context.Context, uid string, ids []string) error {
+	if uid == "" {
+		return ErrPermission
+	}
+	if err := p.store.MarkRead(ctx, uid, ids); err != nil {
+		return err
+	}
+	p.publishUnread(ctx, uid)
+	return nil
+}
+
+// SubscribeUnread returns a channel receiving the unread count of uid each
+// time it changes, and a function to cancel the subscription.
+func (p *Community) SubscribeUnread(uid string) (unread <-chan int, cancel func()) {
+	return p.notifier.subscribe(uid)
+}
+
+// notifier fans the unread counts of users out to their subscribers.
+type notifier struct {
+	mu   sync.Mutex
+	subs map[string]map[chan int]struct{}
+}
+
+func newNotifier() *notifier {
+	return &notifier{subs: make(map[string]map[chan int]struct{})}
+}
+
+func (n *notifier) subscribe(uid string) (<-chan int, func()) {
+	ch := make(chan int, 1)
+	n.mu.Lock()
+	subs := n.subs[uid]
+	if subs == nil {
+		subs = make(map[chan int]struct{})
+		n.subs[uid] = subs
+	}
+	subs[ch] = struct{}{}
+	n.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			n.mu.Lock()
+			delete(subs, ch)
+			if len(subs) == 0 {
+				delete(n.subs, uid)
+			}
+			n.mu.Unlock()
+		})
+	}
+}
+
+func (n *notifier) subscribed(uid string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.subs[uid]) > 0
+}
+
+// publish sends unread to the subscribers of uid. Slow subscribers only get
+// the latest count.
+func (n *notifier) publish(uid string, unread int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subs[uid] {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- unread
+	}
+}
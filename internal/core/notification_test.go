@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNotifications(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+
+	id := putTestArticle(t, community, "1", "Test")
+	community.SetArticleStatus(todo, "1", id, StatusPublished)
+	unread, cancel := community.SubscribeUnread("1")
+	defer cancel()
+
+	comment, err := community.PutComment(todo, "2", id, "", "Nice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-unread:
+		if n != 1 {
+			t.Errorf("SubscribeUnread(1) received %d, expected: 1", n)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("SubscribeUnread(1) received nothing")
+	}
+	community.PutComment(todo, "3", id, comment.ID, "Thanks")
+	community.PutComment(todo, "1", id, comment.ID, "Mine") // 1 isn't notified of itself
+	community.LikeArticle(todo, "3", id, true)
+	community.LikeArticle(todo, "3", id, false)
+	community.LikeArticle(todo, "3", id, true) // not notified twice while unread
+	community.Follow(todo, "2", "1", true)
+
+	var kinds []string
+	from := MarkBegin
+	for from != MarkEnd {
+		items, next, err := community.Notifications(todo, "1", from, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range items {
+			kinds = append(kinds, n.Kind+":"+n.Actor.Name)
+		}
+		from = next
+	}
+	want := []string{"follow:user2", "like:user3", "comment:user3", "comment:user2"}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("Notifications(1) returned %v, expected: %v", kinds, want)
+	}
+	if n, _ := community.UnreadCount(todo, "2"); n != 2 {
+		t.Errorf("UnreadCount(2) = %d, expected: 2 replies", n)
+	}
+
+	if n, _ := community.UnreadCount(todo, "1"); n != 4 {
+		t.Errorf("UnreadCount(1) = %d, expected: 4", n)
+	}
+	items, _, _ := community.Notifications(todo, "1", MarkBegin, 1)
+	if err = community.MarkRead(todo, "1", []string{items[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if n := <-unread; n != 3 {
+		t.Errorf("SubscribeUnread(1) received %d after MarkRead, expected: 3", n)
+	}
+	community.MarkRead(todo, "1", nil)
+	if n, _ := community.UnreadCount(todo, "1"); n != 0 {
+		t.Errorf("UnreadCount(1) = %d after MarkRead all, expected: 0", n)
+	}
+	if _, _, err := community.Notifications(todo, "", MarkBegin, 10); err != ErrPermission {
+		t.Errorf("Notifications() signed out returned err: %v, expected: %v", err, ErrPermission)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	n := newNotifier()
+	ch, cancel := n.subscribe("1")
+	n.publish("1", 1)
+	n.publish("1", 2) // replaces the unread count not received
+	n.publish("2", 3)
+	if got := <-ch; got != 2 {
+		t.Errorf("received %d, expected: 2", got)
+	}
+	cancel()
+	cancel()
+	if n.subscribed("1") {
+		t.Errorf("subscribed(1) after cancel = true, expected: false")
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// redirectPaths are the pages users may be sent back to after they log in.
+// A path matches an entry if it is the entry or under it, except "/" which
+// only matches the home page.
+var redirectPaths = []string{
+	"/",
+	"/p",
+	"/user",
+	"/tag",
+	"/tags",
+	"/search",
+	"/add",
+	"/edit",
+	"/revisions",
+	"/feed",
+	"/notifications",
+	"/prefs",
+	"/admin",
+}
+
+// SafeRedirect returns target as a path of the site if it is one of
+// redirectPaths on the origin of request r, or "/" otherwise. target may be
+// an absolute URL, such as the Referer of a request.
+func (p *Community) SafeRedirect(r *http.Request, target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.User != nil || u.Opaque != "" {
+		return "/"
+	}
+	if u.Scheme != "" || u.Host != "" {
+		base, err := url.Parse(p.BaseURL(r))
+		if err != nil || u.Scheme != base.Scheme || u.Host != base.Host {
+			return "/"
+		}
+	}
+	// reject "//host" and "/\host", which browsers take for other origins
+	if !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(u.Path, "//") || strings.Contains(u.Path, "\\") {
+		return "/"
+	}
+	name := path.Clean(u.Path)
+	for _, allowed := range redirectPaths {
+		if name == allowed || allowed != "/" && strings.HasPrefix(name, allowed+"/") {
+			ret := &url.URL{Path: name, RawQuery: u.RawQuery}
+			return ret.String()
+		}
+	}
+	return "/"
+}
+
+// encodeState returns the oauth state of a login carrying nonce and the
+// page to return to.
+func encodeState(nonce, returnTo string) string {
+	return nonce + "." + base64.RawURLEncoding.EncodeToString([]byte(returnTo))
+}
+
+// stateReturnTo returns the page to return to carried by oauth state, or "/"
+// if there is none.
+func stateReturnTo(state string) string {
+	_, enc, ok := strings.Cut(state, ".")
+	if !ok {
+		return "/"
+	}
+	b, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil || len(b) == 0 {
+		return "/"
+	}
+	return string(b)
+}
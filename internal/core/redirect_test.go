@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/goplus/yap"
+)
+
+func TestSafeRedirect(t *testing.T) {
+	community := newTestCommunity(t)
+	r := httptest.NewRequest("GET", "/login", nil)
+
+	for _, c := range []struct {
+		target, expected string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"/p/1", "/p/1"},
+		{"/search?q=go%2B", "/search?q=go%2B"},
+		{"/user/1/../../prefs", "/prefs"},
+		{"http://example.com/tag/go", "/tag/go"},
+		{"http://example.com", "/"},
+		{"/tags#top", "/tags"},
+		{"/login", "/"},
+		{"/callback?code=code", "/"},
+		{"/feed.xml", "/"},
+		{"/p/../logout", "/"},
+		{"p/1", "/"},
+		{"//evil.com/p/1", "/"},
+		{"/\\evil.com/p/1", "/"},
+		{"https://example.com/p/1", "/"},
+		{"http://evil.com/p/1", "/"},
+		{"http://user@example.com/p/1", "/"},
+		{"javascript:alert(1)", "/"},
+	} {
+		if ret := community.SafeRedirect(r, c.target); ret != c.expected {
+			t.Errorf("SafeRedirect(%q) = %q, expected: %q", c.target, ret, c.expected)
+		}
+	}
+}
+
+func TestLoginReturn(t *testing.T) {
+	community := newTestCommunity(t)
+	key := initTestCasdoor(t)
+	casdoor := &testTokens{t: t, sign: func(uid string) string { return signTestToken(t, key, uid) }, expiresIn: time.Hour}
+	casdoor.install(community)
+
+	for _, c := range []struct {
+		returnTo, expected string
+	}{
+		{"/p/1?lang=en", "/p/1?lang=en"},
+		{"http://evil.com/p/1", "/"},
+		{"", "/"},
+	} {
+		req := httptest.NewRequest("GET", "/login", nil)
+		w := httptest.NewRecorder()
+		loginURL, err := community.RedirectToCasdoor(&yap.Context{Request: req, ResponseWriter: w}, c.returnTo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(loginURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := u.Query().Get("state")
+
+		req = httptest.NewRequest("GET", "/callback?code=code&state="+url.QueryEscape(state), nil)
+		req.AddCookie(&http.Cookie{Name: stateCookie, Value: state})
+		returnTo, err := community.SetToken(&yap.Context{Request: req, ResponseWriter: httptest.NewRecorder()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if returnTo != c.expected {
+			t.Errorf("SetToken() after login from %q returned %q, expected: %q", c.returnTo, returnTo, c.expected)
+		}
+	}
+
+	// a state naming another site, as if the cookie were planted, is checked
+	// again on the callback
+	state := encodeState("nonce", "http://evil.com/p/1")
+	req := httptest.NewRequest("GET", "/callback?code=code&state="+url.QueryEscape(state), nil)
+	req.AddCookie(&http.Cookie{Name: stateCookie, Value: state})
+	if returnTo, err := community.SetToken(&yap.Context{Request: req, ResponseWriter: httptest.NewRecorder()}); err != nil || returnTo != "/" {
+		t.Errorf("SetToken() of a state to another site returned %q, %v, expected: /", returnTo, err)
+	}
+}
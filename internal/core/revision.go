@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Revision is a saved version of an article.
+type Revision struct {
+	ID        string
+	ArticleID string
+	UId       string // who saved the revision
+	Title     string
+	Tags      string
+	Content   string // in markdown
+	HtmlId    string // media id of the html
+	Ctime     time.Time
+}
+
+// ArticleRevisions lists the revisions of the article, newest first.
+func (p *Community) ArticleRevisions(ctx context.Context, uid, id string) (items []*Revision, err error) {
+	if editable, _ := p.CanEditable(ctx, uid, id); !editable {
+		return []*Revision{}, ErrPermission
+	}
+	return p.store.ListRevisions(ctx, id)
+}
+
+// revision returns the revision revId of article id.
+func (p *Community) revision(ctx context.Context, id, revId string) (*Revision, error) {
+	rev, err := p.store.GetRevision(ctx, revId)
+	if err != nil {
+		return rev, err
+	}
+	if rev.ArticleID != id {
+		return &Revision{}, ErrNotExist
+	}
+	return rev, nil
+}
+
+// RevisionDiff compares the markdown of the revisions from and to of the
+// article. An empty to compares with the current content.
+func (p *Community) RevisionDiff(ctx context.Context, uid, id, from, to string) (diff []DiffLine, err error) {
+	if editable, _ := p.CanEditable(ctx, uid, id); !editable {
+		return nil, ErrPermission
+	}
+	old, err := p.revision(ctx, id, from)
+	if err != nil {
+		return
+	}
+	var content string
+	if to == "" {
+		article, _, err := p.store.GetArticle(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		content = article.Content
+	} else {
+		rev, err := p.revision(ctx, id, to)
+		if err != nil {
+			return nil, err
+		}
+		content = rev.Content
+	}
+	return DiffLines(old.Content, content), nil
+}
+
+// RestoreRevision makes the revision revId the current content of the
+// article. The restored content is saved as a new revision.
+func (p *Community) RestoreRevision(ctx context.Context, uid, id, revId string) (err error) {
+	if editable, _ := p.CanEditable(ctx, uid, id); !editable {
+		return ErrPermission
+	}
+	rev, err := p.revision(ctx, id, revId)
+	if err != nil {
+		return
+	}
+	article, _, err := p.store.GetArticle(ctx, id)
+	if err != nil {
+		return
+	}
+	article.Title = rev.Title
+	article.Tags = rev.Tags
+	article.Content = rev.Content
+	htmlId, _ := strconv.ParseInt(rev.HtmlId, 10, 64)
+	return p.store.UpdateArticle(ctx, uid, article, htmlId)
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+)
+
+// Role is what a user may do in the community. The roles are ordered, each
+// one may do all that the lesser ones may.
+type Role int
+
+const (
+	RoleReader    Role = iota + 1 // reads, comments and likes
+	RoleAuthor                    // writes articles too, the default role
+	RoleModerator                 // edits and removes the content of others too
+	RoleAdmin                     // manages the roles of users too
+)
+
+// DefaultRole is the role of users without a local or casdoor role.
+const DefaultRole = RoleAuthor
+
+var roleNames = [...]string{
+	RoleReader:    "reader",
+	RoleAuthor:    "author",
+	RoleModerator: "moderator",
+	RoleAdmin:     "admin",
+}
+
+func (r Role) String() string {
+	if r >= RoleReader && r <= RoleAdmin {
+		return roleNames[r]
+	}
+	return "Role(" + strconv.Itoa(int(r)) + ")"
+}
+
+// ParseRole parses the name of a role.
+func ParseRole(name string) (Role, error) {
+	for r := RoleReader; r <= RoleAdmin; r++ {
+		if roleNames[r] == name {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("core: invalid role %q", name)
+}
+
+// casdoorRole returns the greatest role among the roles and groups of a
+// casdoor user named after roles, RoleAdmin for casdoor admins, or 0 if
+// there is none.
+func casdoorRole(u *casdoorsdk.User) (role Role) {
+	if u.IsAdmin {
+		return RoleAdmin
+	}
+	names := append([]string(nil), u.Groups...)
+	for _, r := range u.Roles {
+		names = append(names, r.Name)
+	}
+	for _, name := range names {
+		// groups may be named as `organization/group`
+		name = strings.ToLower(name[strings.LastIndexByte(name, '/')+1:])
+		if r, err := ParseRole(name); err == nil && r > role {
+			role = r
+		}
+	}
+	return
+}
+
+// Action is an operation which is subject to the policy.
+type Action int
+
+const (
+	ActionCreateArticle Action = iota
+	ActionEditArticle
+	ActionDeleteArticle
+	ActionEditComment
+	ActionDeleteComment
+	ActionDeleteMedia
+	ActionManageRoles
+	ActionCreateComment
+	ActionReport
+	ActionModerate // review reports, hide and restore content
+	ActionBanUser
+	ActionUploadMedia
+	ActionEngage // like and bookmark articles
+	ActionFollow
+)
+
+// noRole is above all roles, so nobody is allowed.
+const noRole = RoleAdmin + 1
+
+// policy gives the least roles allowed to do an action on their own
+// resources and on the resources of others.
+var policy = [...]struct{ own, others Role }{
+	ActionCreateArticle: {RoleAuthor, noRole},
+	ActionEditArticle:   {RoleAuthor, RoleModerator},
+	ActionDeleteArticle: {RoleReader, RoleModerator},
+	ActionEditComment:   {RoleReader, noRole},
+	ActionDeleteComment: {RoleReader, RoleModerator},
+	ActionDeleteMedia:   {RoleReader, RoleModerator},
+	ActionManageRoles:   {RoleAdmin, RoleAdmin},
+	ActionCreateComment: {RoleReader, noRole},
+	ActionReport:        {RoleReader, RoleReader},
+	ActionModerate:      {RoleModerator, RoleModerator},
+	ActionBanUser:       {noRole, RoleModerator},
+	ActionUploadMedia:   {RoleReader, noRole},
+	ActionEngage:        {RoleReader, RoleReader},
+	ActionFollow:        {noRole, RoleReader},
+}
+
+// Allowed reports whether role may do action on a resource, which is its
+// own if own.
+func Allowed(role Role, action Action, own bool) bool {
+	if action < 0 || int(action) >= len(policy) {
+		return false
+	}
+	if own {
+		return role >= policy[action].own
+	}
+	return role >= policy[action].others
+}
+
+// Role returns the role of user uid: the local role if set, or else the
+// role from casdoor, or else DefaultRole. The role from casdoor is
+// cached with the profile of the user. If the user can't be looked up, it
+// is RoleReader, so that outages of casdoor don't grant more than reading.
+func (p *Community) Role(ctx context.Context, uid string) (Role, error) {
+	role, err := p.store.GetRole(ctx, uid)
+	if err != ErrNotExist {
+		return role, err
+	}
+	users, err := p.users.get([]string{uid})
+	user, ok := users[uid]
+	if !ok {
+		p.xLog.Warn("get role error:", err)
+		return RoleReader, nil
+	}
+	if user.Role != 0 {
+		return user.Role, nil
+	}
+	return DefaultRole, nil
+}
+
+// SetRole sets the local role of user target, which overrides the role
+// from casdoor, or clears it if role is 0. Only admins (uid) may set roles.
+func (p *Community) SetRole(ctx context.Context, uid, target string, role Role) error {
+	if role != 0 && (role < RoleReader || role > RoleAdmin) {
+		return fmt.Errorf("core: invalid role %d", role)
+	}
+	if err := p.authorize(ctx, uid, ActionManageRoles, target); err != nil {
+		return err
+	}
+	if err := p.store.SetRole(ctx, target, role); err != nil {
+		return err
+	}
+	detail := ""
+	if role != 0 {
+		detail = role.String()
+	}
+	p.audit(ctx, uid, AuditRole, KindUser, target, detail)
+	return nil
+}
+
+// Can reports whether user uid may do action on a resource of user owner.
+func (p *Community) Can(ctx context.Context, uid string, action Action, owner string) bool {
+	return p.authorize(ctx, uid, action, owner) == nil
+}
+
+// authorize returns ErrPermission unless user uid may do action on a
+// resource of user owner. Visitors (uid == "") and banned users may do
+// nothing.
+func (p *Community) authorize(ctx context.Context, uid string, action Action, owner string) error {
+	if uid == "" {
+		return ErrPermission
+	}
+	if _, err := p.store.GetBan(ctx, uid); err != ErrNotExist {
+		if err == nil {
+			return ErrPermission
+		}
+		return err
+	}
+	role, err := p.Role(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if !Allowed(role, action, uid == owner) {
+		return ErrPermission
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+)
+
+var testRoles = []Role{RoleReader, RoleAuthor, RoleModerator, RoleAdmin}
+
+// permissionMatrix tells whether each role (RoleReader first) may do an
+// action on its own resources and on those of others.
+var permissionMatrix = map[Action][4]struct{ own, others bool }{
+	ActionCreateArticle: {{false, false}, {true, false}, {true, false}, {true, false}},
+	ActionEditArticle:   {{false, false}, {true, false}, {true, true}, {true, true}},
+	ActionDeleteArticle: {{true, false}, {true, false}, {true, true}, {true, true}},
+	ActionEditComment:   {{true, false}, {true, false}, {true, false}, {true, false}},
+	ActionDeleteComment: {{true, false}, {true, false}, {true, true}, {true, true}},
+	ActionDeleteMedia:   {{true, false}, {true, false}, {true, true}, {true, true}},
+	ActionManageRoles:   {{false, false}, {false, false}, {false, false}, {true, true}},
+	ActionCreateComment: {{true, false}, {true, false}, {true, false}, {true, false}},
+	ActionReport:        {{true, true}, {true, true}, {true, true}, {true, true}},
+	ActionModerate:      {{false, false}, {false, false}, {true, true}, {true, true}},
+	ActionBanUser:       {{false, false}, {false, false}, {false, true}, {false, true}},
+	ActionUploadMedia:   {{true, false}, {true, false}, {true, false}, {true, false}},
+	ActionEngage:        {{true, true}, {true, true}, {true, true}, {true, true}},
+	ActionFollow:        {{false, true}, {false, true}, {false, true}, {false, true}},
+}
+
+func TestAllowed(t *testing.T) {
+	if len(permissionMatrix) != len(policy) {
+		t.Fatalf("permissionMatrix has %d actions, expected: %d", len(permissionMatrix), len(policy))
+	}
+	for action, cells := range permissionMatrix {
+		for i, role := range testRoles {
+			if got := Allowed(role, action, true); got != cells[i].own {
+				t.Errorf("Allowed(%v, %d, own) = %t, expected: %t", role, action, got, cells[i].own)
+			}
+			if got := Allowed(role, action, false); got != cells[i].others {
+				t.Errorf("Allowed(%v, %d, others) = %t, expected: %t", role, action, got, cells[i].others)
+			}
+		}
+	}
+	if Allowed(RoleAdmin, Action(len(policy)), true) || Allowed(0, ActionDeleteComment, true) {
+		t.Errorf("Allowed() allowed an unknown action or role")
+	}
+}
+
+// TestPolicy checks every cell of permissionMatrix through the operations
+// subject to it.
+func TestPolicy(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	// author has DefaultRole, and others are written by other
+	for _, role := range testRoles {
+		if role != DefaultRole {
+			if err := community.store.SetRole(todo, role.String(), role); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	putArticle := func(owner string) string {
+		article := &Article{ArticleEntry: ArticleEntry{UId: owner, Title: "Test", Status: StatusPublished}, Content: "test"}
+		id, err := community.store.InsertArticle(todo, article, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	putComment := func(owner string) string {
+		c, err := community.PutComment(todo, owner, putArticle("other"), "", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.ID
+	}
+	do := func(action Action, uid, owner string) error {
+		switch action {
+		case ActionCreateArticle:
+			_, err := community.PutArticle(todo, uid, "", &Article{Content: "test"})
+			return err
+		case ActionEditArticle:
+			article := &Article{ArticleEntry: ArticleEntry{ID: putArticle(owner), Title: "Edited"}, Content: "edited"}
+			_, err := community.PutArticle(todo, uid, "", article)
+			return err
+		case ActionDeleteArticle:
+			return community.DeleteArticle(todo, uid, putArticle(owner))
+		case ActionEditComment:
+			_, err := community.EditComment(todo, uid, putComment(owner), "edited")
+			return err
+		case ActionDeleteComment:
+			return community.DeleteComment(todo, uid, putComment(owner))
+		case ActionDeleteMedia:
+			id, err := community.SaveMedia(todo, owner, []byte("test"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return community.DelMedia(todo, uid, strconv.FormatInt(id, 10))
+		case ActionManageRoles:
+			role, err := community.Role(todo, owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return community.SetRole(todo, uid, owner, role)
+		case ActionCreateComment:
+			_, err := community.PutComment(todo, uid, putArticle("other"), "", "test")
+			return err
+		case ActionReport:
+			return community.ReportContent(todo, uid, KindArticle, putArticle(owner), "spam")
+		case ActionModerate:
+			return community.Moderate(todo, uid, KindArticle, putArticle(owner), AuditHide, "")
+		case ActionBanUser:
+			err := community.Ban(todo, uid, owner, "spam")
+			if err == nil {
+				err = community.Unban(todo, uid, owner, "")
+			}
+			return err
+		case ActionUploadMedia:
+			// UploadFile authorizes before saving
+			return community.authorize(todo, uid, ActionUploadMedia, owner)
+		case ActionEngage:
+			_, err := community.LikeArticle(todo, uid, putArticle(owner), true)
+			return err
+		case ActionFollow:
+			return community.Follow(todo, uid, owner, true)
+		}
+		panic("unknown action")
+	}
+	for action, cells := range permissionMatrix {
+		for i, role := range testRoles {
+			uid := role.String()
+			if err := do(action, uid, uid); (err == nil) != cells[i].own {
+				t.Errorf("%v doing %d on its own returned err: %v, expected allowed: %t", role, action, err, cells[i].own)
+			}
+			if action == ActionCreateArticle || action == ActionCreateComment || action == ActionUploadMedia {
+				continue // content is created by its authors
+			}
+			if err := do(action, uid, "other"); (err == nil) != cells[i].others {
+				t.Errorf("%v doing %d on others' returned err: %v, expected allowed: %t", role, action, err, cells[i].others)
+			}
+		}
+	}
+	// visitors may do nothing
+	if err := community.DeleteComment(todo, "", putComment("other")); err != ErrPermission {
+		t.Errorf("DeleteComment() by a visitor returned err: %v, expected: %v", err, ErrPermission)
+	}
+}
+
+func TestRole(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	community.users = newUserCache(func(uids []string) (map[string]*User, error) {
+		for _, uid := range uids {
+			if uid == "500" {
+				return nil, errors.New("casdoor is down")
+			}
+		}
+		users, err := fetchTestUsers(uids)
+		if user, ok := users["3"]; ok {
+			user.Role = RoleModerator // from casdoor
+		}
+		return users, err
+	}, time.Minute)
+
+	tests := []struct {
+		uid      string
+		expected Role
+	}{
+		{"1", DefaultRole},
+		{"3", RoleModerator},
+		// users failed to look up may only read
+		{"404", RoleReader},
+		{"500", RoleReader},
+	}
+	for _, tt := range tests {
+		if role, err := community.Role(todo, tt.uid); role != tt.expected || err != nil {
+			t.Errorf("Role(%s) returned %v, %v, expected: %v", tt.uid, role, err, tt.expected)
+		}
+	}
+
+	// admins set the local roles, which override those from casdoor
+	community.store.SetRole(todo, "1", RoleAdmin)
+	if err := community.SetRole(todo, "3", "1", RoleReader); err != ErrPermission {
+		t.Errorf("SetRole() by a moderator returned err: %v, expected: %v", err, ErrPermission)
+	}
+	if err := community.SetRole(todo, "1", "3", RoleReader); err != nil {
+		t.Fatal(err)
+	}
+	if role, _ := community.Role(todo, "3"); role != RoleReader {
+		t.Errorf("Role(3) returned %v after SetRole(), expected: %v", role, RoleReader)
+	}
+	if err := community.SetRole(todo, "1", "3", 0); err != nil {
+		t.Fatal(err)
+	}
+	if role, _ := community.Role(todo, "3"); role != RoleModerator {
+		t.Errorf("Role(3) returned %v after clearing it, expected: %v", role, RoleModerator)
+	}
+	if err := community.SetRole(todo, "1", "3", RoleAdmin+1); err == nil {
+		t.Errorf("SetRole() of an invalid role returned nil error")
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, role := range testRoles {
+		if got, err := ParseRole(role.String()); got != role || err != nil {
+			t.Errorf("ParseRole(%q) returned %v, %v, expected: %v", role.String(), got, err, role)
+		}
+	}
+	if _, err := ParseRole("root"); err == nil {
+		t.Errorf("ParseRole(root) returned nil error")
+	}
+	if s := Role(0).String(); s != "Role(0)" {
+		t.Errorf("Role(0).String() = %q", s)
+	}
+}
+
+func TestCasdoorRole(t *testing.T) {
+	tests := []struct {
+		user     *casdoorsdk.User
+		expected Role
+	}{
+		{&casdoorsdk.User{}, 0},
+		{&casdoorsdk.User{IsAdmin: true}, RoleAdmin},
+		{&casdoorsdk.User{Groups: []string{"goplus/Moderator", "staff"}}, RoleModerator},
+		{&casdoorsdk.User{Roles: []*casdoorsdk.Role{{Name: "reader"}}}, RoleReader},
+		{&casdoorsdk.User{Groups: []string{"goplus/reader"}, Roles: []*casdoorsdk.Role{{Name: "admin"}}}, RoleAdmin},
+	}
+	for _, tt := range tests {
+		if got := casdoorRole(tt.user); got != tt.expected {
+			t.Errorf("casdoorRole(%+v) = %v, expected: %v", tt.user, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var errNoPublishTime = errors.New("core: scheduled article without publish time")
+
+// ScheduleArticle schedules the article to be published at publishAt. Until
+// then it is visible to its author only.
+func (p *Community) ScheduleArticle(ctx context.Context, uid, id string, publishAt time.Time) (err error) {
+	if editable, _ := p.CanEditable(ctx, uid, id); !editable {
+		return ErrPermission
+	}
+	if publishAt.IsZero() {
+		return errNoPublishTime
+	}
+	return p.store.ScheduleArticle(ctx, id, publishAt)
+}
+
+// PublishDue publishes the scheduled articles which are due at now.
+func (p *Community) PublishDue(ctx context.Context, now time.Time) (n int, err error) {
+	return p.store.PublishDue(ctx, now)
+}
+
+// runScheduler publishes the due articles every interval until ctx is done.
+func (p *Community) runScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := p.PublishDue(ctx, now)
+			if err != nil {
+				p.xLog.Error("publish scheduled articles error:", err)
+			} else if n > 0 {
+				p.xLog.Info("published scheduled articles:", n)
+			}
+		}
+	}
+}
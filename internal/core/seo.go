@@ -0,0 +1,166 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goplus/community/internal/sitemap"
+)
+
+// sitemapSize is the number of articles in a page of the sitemap.
+const sitemapSize = 5000
+
+// SitemapIndex returns the index of the pages of the sitemap of listed
+// articles, which are served at /sitemap.xml?page=N.
+func (p *Community) SitemapIndex(ctx context.Context) (*sitemap.Index, error) {
+	total, err := p.store.CountArticles(ctx, &ArticleFilter{Statuses: listedStatuses})
+	if err != nil {
+		return nil, err
+	}
+	idx := &sitemap.Index{}
+	for page := 1; page == 1 || (page-1)*sitemapSize < total; page++ {
+		idx.Sitemaps = append(idx.Sitemaps, sitemap.URL{Loc: p.siteURL + "/sitemap.xml?page=" + strconv.Itoa(page)})
+	}
+	return idx, nil
+}
+
+// Sitemap returns the page of the sitemap of listed articles, newest first,
+// with their modification times. The first page lists the home page too.
+func (p *Community) Sitemap(ctx context.Context, page int) (*sitemap.Sitemap, error) {
+	if page < 1 {
+		return nil, ErrNotExist
+	}
+	filter := &ArticleFilter{Statuses: listedStatuses}
+	items, err := p.store.ListArticles(ctx, filter, (page-1)*sitemapSize, sitemapSize)
+	if err != nil {
+		return nil, err
+	}
+	s := &sitemap.Sitemap{}
+	if page == 1 {
+		s.URLs = append(s.URLs, sitemap.URL{Loc: p.siteURL + "/"})
+	} else if len(items) == 0 {
+		return nil, ErrNotExist
+	}
+	for _, item := range items {
+		s.URLs = append(s.URLs, sitemap.URL{Loc: p.siteURL + "/p/" + item.ID, LastMod: item.Mtime})
+	}
+	return s, nil
+}
+
+// BaseURL returns the absolute URL of the site: SiteURL if configured, or
+// the origin of request r, behind a proxy too. Pages, feeds and sitemaps
+// all resolve their links against it.
+func (p *Community) BaseURL(r *http.Request) string {
+	if p.siteURL != "" {
+		return p.siteURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// HasSiteURL reports whether SiteURL is configured. Otherwise BaseURL takes
+// the origin from requests, which may be forged, so the responses resolved
+// against it, such as feeds and sitemaps, must not be kept by shared caches.
+func (p *Community) HasSiteURL() bool {
+	return p.siteURL != ""
+}
+
+// ArticleMeta is the metadata of the page of an article for search engines
+// and sharing: a canonical URL, OpenGraph and Twitter Card tags, and
+// schema.org data.
+type ArticleMeta struct {
+	CanonicalURL string
+	Title        string
+	Description  string
+	Image        string // absolute URL of the cover, "" if none
+	Author       string
+	AuthorURL    string
+	Tags         []string
+	Published    string // RFC 3339
+	Modified     string // RFC 3339
+
+	// JSONLD is the BlogPosting of the article, to be marshaled in a
+	// <script type="application/ld+json">.
+	JSONLD map[string]any
+}
+
+// ArticleMeta returns the metadata of the page of article, with absolute
+// URLs under base.
+func (p *Community) ArticleMeta(article *Article, base string) *ArticleMeta {
+	baseURL, err := url.Parse(base + "/")
+	if err != nil {
+		baseURL = &url.URL{}
+	}
+	abs := func(ref string) string {
+		if ref == "" {
+			return ""
+		}
+		if u, err := baseURL.Parse(ref); err == nil {
+			return u.String()
+		}
+		return ref
+	}
+	published := article.Ctime
+	if !article.PublishAt.IsZero() {
+		published = article.PublishAt
+	}
+	meta := &ArticleMeta{
+		CanonicalURL: abs("p/" + url.PathEscape(article.ID)),
+		Title:        article.Title,
+		Description:  article.Abstract,
+		Image:        abs(article.Cover),
+		Author:       article.User.Name,
+		AuthorURL:    abs("user/" + url.PathEscape(article.UId)),
+		Tags:         ParseTags(article.Tags),
+		Published:    published.UTC().Format(time.RFC3339),
+		Modified:     article.Mtime.UTC().Format(time.RFC3339),
+	}
+	if meta.Description == "" {
+		meta.Description = article.Title
+	}
+	meta.JSONLD = map[string]any{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         meta.Title,
+		"description":      meta.Description,
+		"url":              meta.CanonicalURL,
+		"mainEntityOfPage": map[string]any{"@type": "WebPage", "@id": meta.CanonicalURL},
+		"datePublished":    meta.Published,
+		"dateModified":     meta.Modified,
+		"author":           map[string]any{"@type": "Person", "name": meta.Author, "url": meta.AuthorURL},
+		"publisher":        map[string]any{"@type": "Organization", "name": "Go+ Community", "url": baseURL.String()},
+	}
+	if meta.Image != "" {
+		meta.JSONLD["image"] = []string{meta.Image}
+	}
+	if len(meta.Tags) > 0 {
+		meta.JSONLD["keywords"] = strings.Join(meta.Tags, ", ")
+	}
+	return meta
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSitemap(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+
+	var want []string
+	for _, title := range []string{"First", "Second"} {
+		id := putTestArticle(t, community, "1", title)
+		community.SetArticleStatus(todo, "1", id, StatusPublished)
+		want = append([]string{"/p/" + id}, want...)
+	}
+	putTestArticle(t, community, "1", "Draft")
+
+	idx, err := community.SitemapIndex(todo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Sitemaps) != 1 || idx.Sitemaps[0].Loc != "/sitemap.xml?page=1" {
+		t.Errorf("SitemapIndex() returned %v", idx.Sitemaps)
+	}
+	s, err := community.Sitemap(todo, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append([]string{"/"}, want...)
+	if len(s.URLs) != len(want) {
+		t.Fatalf("Sitemap(1) returned %v, expected: %v", s.URLs, want)
+	}
+	for i, u := range s.URLs {
+		if u.Loc != want[i] || (i > 0 && u.LastMod.IsZero()) {
+			t.Errorf("Sitemap(1) returned %v at %d, expected: %s with lastmod", u, i, want[i])
+		}
+	}
+	for _, page := range []int{0, 2} {
+		if _, err = community.Sitemap(todo, page); err != ErrNotExist {
+			t.Errorf("Sitemap(%d) returned err: %v, expected: %v", page, err, ErrNotExist)
+		}
+	}
+}
+
+func TestArticleMeta(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+
+	id := putTestArticle(t, community, "1", "Hello")
+	article, err := community.Article(todo, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	article.Abstract = ""
+	r := httptest.NewRequest("GET", "/p/"+id, nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	base := community.BaseURL(r)
+	if base != "https://example.com" {
+		t.Fatalf("BaseURL() = %s, expected: https://example.com", base)
+	}
+	meta := community.ArticleMeta(article, base)
+	if meta.CanonicalURL != "https://example.com/p/"+id || meta.Image != "https://example.com/cover1" ||
+		meta.Description != "Hello" || meta.Author != "user1" || meta.AuthorURL != "https://example.com/user/1" {
+		t.Errorf("ArticleMeta() returned %+v", meta)
+	}
+	if meta.JSONLD["@type"] != "BlogPosting" || meta.JSONLD["keywords"] != "tag1" || meta.JSONLD["datePublished"] != meta.Published {
+		t.Errorf("ArticleMeta() returned JSON-LD %v", meta.JSONLD)
+	}
+
+	community.siteURL = "https://community.example.com"
+	if base = community.BaseURL(r); base != community.siteURL {
+		t.Errorf("BaseURL() = %s, expected: %s", base, community.siteURL)
+	}
+}
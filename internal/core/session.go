@@ -0,0 +1,294 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goplus/yap"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// sessionCookie is the cookie keeping the id of the session of a
+	// browser. The tokens of the session stay on the server.
+	sessionCookie = "session"
+
+	// sessionTTL is how long a session lasts without being refreshed.
+	sessionTTL = 30 * 24 * time.Hour
+
+	// refreshMargin is how long before its expiry an access token is
+	// refreshed.
+	refreshMargin = 5 * time.Minute
+
+	// stateCookie is the cookie keeping the oauth state of a login, see
+	// RedirectToCasdoor, for stateTTL.
+	stateCookie = "oauth_state"
+	stateTTL    = 10 * time.Minute
+)
+
+// Session is a login of a user.
+type Session struct {
+	ID           string
+	UId          string
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time // of AccessToken
+	Ctime        time.Time
+	Mtime        time.Time // of the last refresh
+}
+
+// oauthTokens gets the oauth tokens of users from the identity provider.
+// Refreshes are serialized, so that a refresh token is used once even if
+// concurrent requests find the access token about to expire.
+type oauthTokens struct {
+	exchange func(code, state string) (*oauth2.Token, error)
+	refresh  func(refreshToken string) (*oauth2.Token, error)
+
+	mu sync.Mutex
+}
+
+func newOAuthTokens(idp IdentityProvider) *oauthTokens {
+	return &oauthTokens{exchange: idp.GetOAuthToken, refresh: idp.RefreshOAuthToken}
+}
+
+// tokenClaims returns the user the access token of tok is for, and when it
+// expires.
+func (p *Community) tokenClaims(tok *oauth2.Token) (uid string, expiry time.Time, err error) {
+	claims, err := p.idp.ParseJwtToken(tok.AccessToken)
+	if err != nil {
+		return
+	}
+	expiry = tok.Expiry
+	if expiry.IsZero() && claims.ExpiresAt != nil {
+		expiry = claims.ExpiresAt.Time
+	}
+	return claims.Id, expiry, nil
+}
+
+// SetToken logs the user in with the oauth code of the callback request,
+// starting a new session, and returns the page to send the user back to. It
+// returns ErrPermission unless the oauth state is the one RedirectToCasdoor
+// handed to the browser.
+func (p *Community) SetToken(ctx *yap.Context) (returnTo string, err error) {
+	query := ctx.URL.Query()
+	state := query.Get("state")
+	cookie, err := ctx.Request.Cookie(stateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return "", ErrPermission
+	}
+	p.setCookie(ctx, stateCookie, "", -1, false)
+	tok, err := p.tokens.exchange(query.Get("code"), state)
+	if err != nil {
+		return "", err
+	}
+	uid, expiry, err := p.tokenClaims(tok)
+	if err != nil {
+		return "", err
+	}
+	id, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	s := &Session{
+		ID:           id,
+		UId:          uid,
+		AccessToken:  tok.AccessToken,
+		RefreshToken: tok.RefreshToken,
+		Expiry:       expiry,
+	}
+	if err = p.store.InsertSession(ctx.Context(), s); err != nil {
+		return "", err
+	}
+	p.setSessionCookies(ctx, s.ID, int(sessionTTL/time.Second))
+	return p.SafeRedirect(ctx.Request, stateReturnTo(state)), nil
+}
+
+// GetToken returns the access token of the session of a request, which is
+// refreshed if about to expire. It returns ErrNotExist if there's no
+// session, or if it's expired or revoked.
+func (p *Community) GetToken(ctx *yap.Context) (token string, err error) {
+	cookie, err := ctx.Request.Cookie(sessionCookie)
+	if err != nil {
+		return "", ErrNotExist
+	}
+	s, err := p.session(ctx.Context(), cookie.Value)
+	if err != nil {
+		return "", err
+	}
+	if time.Until(s.Expiry) < refreshMargin {
+		if s, err = p.refreshSession(ctx.Context(), s.ID); err != nil {
+			return "", err
+		}
+		// the session lasts sessionTTL from its last refresh
+		p.setSessionCookies(ctx, s.ID, int(sessionTTL/time.Second))
+	}
+	return s.AccessToken, nil
+}
+
+// RemoveToken logs the user out, revoking the session of the request and
+// deleting its cookies. Unlike RequireAuth, it accepts expired or revoked
+// sessions, so that their cookies are deleted too, and checks CSRF only if
+// there is a session. It returns ErrPermission if the check fails.
+func (p *Community) RemoveToken(ctx *yap.Context) error {
+	cookie, err := ctx.Request.Cookie(sessionCookie)
+	if err != nil {
+		p.setSessionCookies(ctx, "", -1)
+		return nil
+	}
+	if !p.checkCSRF(ctx) {
+		return ErrPermission
+	}
+	p.setSessionCookies(ctx, "", -1)
+	return p.store.DeleteSession(ctx.Context(), cookie.Value)
+}
+
+// SignOutEverywhere revokes all the sessions of the current user, the one
+// of the request included, see RequireAuth.
+func (p *Community) SignOutEverywhere(ctx *yap.Context) error {
+	uid := UserId(ctx)
+	if uid == "" {
+		return ErrPermission
+	}
+	p.setSessionCookies(ctx, "", -1)
+	n, err := p.store.DeleteUserSessions(ctx.Context(), uid)
+	if err != nil {
+		return err
+	}
+	p.xLog.Info("signed out everywhere:", uid, n)
+	return nil
+}
+
+// session returns session id unless it's idle for sessionTTL.
+func (p *Community) session(ctx context.Context, id string) (*Session, error) {
+	s, err := p.store.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(s.Mtime) > sessionTTL {
+		p.store.DeleteSession(ctx, id)
+		return nil, ErrNotExist
+	}
+	return s, nil
+}
+
+// refreshSession refreshes the access token of session id. The session is
+// revoked if casdoor refuses to refresh it, but kept until its access token
+// expires if casdoor can't be reached.
+func (p *Community) refreshSession(ctx context.Context, id string) (*Session, error) {
+	p.tokens.mu.Lock()
+	defer p.tokens.mu.Unlock()
+
+	// another request may have refreshed the session meanwhile
+	s, err := p.session(ctx, id)
+	if err != nil || time.Until(s.Expiry) >= refreshMargin {
+		return s, err
+	}
+	tok, err := p.tokens.refresh(s.RefreshToken)
+	if err != nil {
+		p.xLog.Error("refresh token error:", err)
+		if _, ok := err.(*oauth2.RetrieveError); !ok && time.Now().Before(s.Expiry) {
+			return s, nil
+		}
+		p.store.DeleteSession(ctx, id)
+		return nil, ErrNotExist
+	}
+	uid, expiry, err := p.tokenClaims(tok)
+	if err != nil || uid != s.UId {
+		p.xLog.Error("refreshed token error:", err)
+		p.store.DeleteSession(ctx, id)
+		return nil, ErrNotExist
+	}
+	s.AccessToken = tok.AccessToken
+	if tok.RefreshToken != "" {
+		s.RefreshToken = tok.RefreshToken
+	}
+	s.Expiry = expiry
+	if err = p.store.UpdateSession(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// PurgeSessions deletes the sessions idle for sessionTTL as of now, and
+// returns their number.
+func (p *Community) PurgeSessions(ctx context.Context, now time.Time) (int64, error) {
+	return p.store.DeleteIdleSessions(ctx, now.Add(-sessionTTL))
+}
+
+// runSessionPurger purges the idle sessions every interval until ctx is
+// done.
+func (p *Community) runSessionPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := p.PurgeSessions(ctx, now)
+			if err != nil {
+				p.xLog.Error("purge sessions error:", err)
+			} else if n > 0 {
+				p.xLog.Info("purged idle sessions:", n)
+			}
+		}
+	}
+}
+
+// setSessionCookies sets the session cookie of session id, with its CSRF
+// token cookie, or deletes them if maxAge < 0.
+func (p *Community) setSessionCookies(ctx *yap.Context, id string, maxAge int) {
+	p.setCookie(ctx, sessionCookie, id, maxAge, false)
+	csrf := ""
+	if maxAge >= 0 {
+		csrf = p.csrfToken(id)
+	}
+	p.setCookie(ctx, csrfCookie, csrf, maxAge, true)
+}
+
+// setCookie sets a cookie of the site, deleting it if maxAge < 0. Cookies
+// are out of reach of scripts unless script, sent over https only if the
+// site is served by https, and not sent along the requests made by other
+// sites except for links.
+func (p *Community) setCookie(ctx *yap.Context, name, value string, maxAge int, script bool) {
+	http.SetCookie(ctx.ResponseWriter, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: !script,
+		Secure:   strings.HasPrefix(p.BaseURL(ctx.Request), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// randomToken returns a random token for session ids and oauth states.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
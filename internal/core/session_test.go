@@ -0,0 +1,297 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goplus/yap"
+	"golang.org/x/oauth2"
+)
+
+// testTokens stands for casdoor, issuing tokens of user "1" which expire
+// in expiresIn. Each refresh rotates the refresh token.
+type testTokens struct {
+	t         *testing.T
+	sign      func(uid string) string
+	expiresIn time.Duration
+	refreshes int
+	err       error // of refreshes
+}
+
+func (c *testTokens) token() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  c.sign("1"),
+		RefreshToken: "refresh" + strconv.Itoa(c.refreshes),
+		Expiry:       time.Now().Add(c.expiresIn),
+	}
+}
+
+func (c *testTokens) install(community *Community) {
+	community.tokens = &oauthTokens{
+		exchange: func(code, state string) (*oauth2.Token, error) {
+			if code != "code" {
+				return nil, &oauth2.RetrieveError{}
+			}
+			return c.token(), nil
+		},
+		refresh: func(refreshToken string) (*oauth2.Token, error) {
+			if c.err != nil {
+				return nil, c.err
+			}
+			if want := "refresh" + strconv.Itoa(c.refreshes); refreshToken != want {
+				c.t.Errorf("refreshed with %q, expected: %q", refreshToken, want)
+			}
+			c.refreshes++
+			return c.token(), nil
+		},
+	}
+}
+
+// serveSession calls handle with a callback request carrying the session
+// cookie, if not empty, and the oauth state cookie "state". It returns the
+// cookies set by handle.
+func serveSession(session string, handle func(ctx *yap.Context)) map[string]*http.Cookie {
+	req := httptest.NewRequest("GET", "/callback?code=code&state=state", nil)
+	req.AddCookie(&http.Cookie{Name: stateCookie, Value: "state"})
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+	}
+	w := httptest.NewRecorder()
+	handle(&yap.Context{Request: req, ResponseWriter: w})
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	return cookies
+}
+
+// login starts a session and returns its id.
+func login(t *testing.T, community *Community) string {
+	var err error
+	cookies := serveSession("", func(ctx *yap.Context) { _, err = community.SetToken(ctx) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := cookies[sessionCookie]
+	if cookie == nil || cookie.Value == "" || cookie.MaxAge <= 0 {
+		t.Fatalf("SetToken() set session cookie %v", cookie)
+	}
+	return cookie.Value
+}
+
+// getToken returns the access token of session, and the session cookie set
+// if the session was refreshed.
+func getToken(community *Community, session string) (token string, cookie *http.Cookie, err error) {
+	cookie = serveSession(session, func(ctx *yap.Context) { token, err = community.GetToken(ctx) })[sessionCookie]
+	return
+}
+
+func TestSession(t *testing.T) {
+	community := newTestCommunity(t)
+	key := initTestCasdoor(t)
+	casdoor := &testTokens{t: t, sign: func(uid string) string { return signTestToken(t, key, uid) }, expiresIn: time.Hour}
+	casdoor.install(community)
+
+	session := login(t, community)
+	token, cookie, err := getToken(community, session)
+	if err != nil || token == "" {
+		t.Fatalf("GetToken() returned %q, %v", token, err)
+	}
+	if cookie != nil || casdoor.refreshes != 0 {
+		t.Errorf("GetToken() refreshed a fresh token")
+	}
+	if user, err := community.GetUser(token); err != nil || user.Id != "1" {
+		t.Errorf("GetToken() returned a token of user %v, %v", user, err)
+	}
+
+	// tokens about to expire are refreshed, with the rotated refresh token
+	casdoor.expiresIn = time.Minute
+	for i := 1; i <= 2; i++ {
+		s, _ := community.store.GetSession(context.TODO(), session)
+		s.Expiry = time.Now().Add(time.Minute)
+		community.store.UpdateSession(context.TODO(), s)
+		if _, cookie, err = getToken(community, session); err != nil {
+			t.Fatal(err)
+		}
+		if casdoor.refreshes != i {
+			t.Errorf("GetToken() refreshed %d times, expected: %d", casdoor.refreshes, i)
+		}
+		if cookie == nil || cookie.Value != session || cookie.MaxAge <= 0 {
+			t.Errorf("GetToken() set session cookie %v after a refresh", cookie)
+		}
+	}
+
+	// the session is kept while casdoor can't be reached
+	casdoor.err = errors.New("connection refused")
+	if _, _, err = getToken(community, session); err != nil {
+		t.Errorf("GetToken() returned %v while casdoor can't be reached", err)
+	}
+	// and revoked once casdoor refuses to refresh it
+	casdoor.err = &oauth2.RetrieveError{}
+	if _, _, err = getToken(community, session); err != ErrNotExist {
+		t.Errorf("GetToken() of a refused refresh returned %v, expected: %v", err, ErrNotExist)
+	}
+	if _, _, err = getToken(community, "unknown"); err != ErrNotExist {
+		t.Errorf("GetToken() of an unknown session returned %v, expected: %v", err, ErrNotExist)
+	}
+}
+
+func TestSignOut(t *testing.T) {
+	community := newTestCommunity(t)
+	key := initTestCasdoor(t)
+	casdoor := &testTokens{t: t, sign: func(uid string) string { return signTestToken(t, key, uid) }, expiresIn: time.Hour}
+	casdoor.install(community)
+
+	var err error
+	session := login(t, community)
+	cookie := serveSession(session, func(ctx *yap.Context) { err = community.RemoveToken(ctx) })[sessionCookie]
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookie == nil || cookie.MaxAge >= 0 {
+		t.Errorf("RemoveToken() set session cookie %v, expected it deleted", cookie)
+	}
+	if _, _, err := getToken(community, session); err != ErrNotExist {
+		t.Errorf("GetToken() after RemoveToken() returned %v, expected: %v", err, ErrNotExist)
+	}
+	// logouts are posted with the CSRF token of the session, if any, and
+	// delete the cookies of revoked sessions as well
+	logout := func(session, csrf string) (map[string]*http.Cookie, error) {
+		req := httptest.NewRequest("POST", "/logout", strings.NewReader(url.Values{"csrf": {csrf}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+		}
+		w := httptest.NewRecorder()
+		err := community.RemoveToken(&yap.Context{Request: req, ResponseWriter: w})
+		cookies := make(map[string]*http.Cookie)
+		for _, cookie := range w.Result().Cookies() {
+			cookies[cookie.Name] = cookie
+		}
+		return cookies, err
+	}
+	session = login(t, community)
+	if _, err := logout(session, "forged"); err != ErrPermission {
+		t.Errorf("RemoveToken() without the CSRF token returned %v, expected: %v", err, ErrPermission)
+	}
+	if _, _, err := getToken(community, session); err != nil {
+		t.Errorf("RemoveToken() without the CSRF token revoked the session: %v", err)
+	}
+	for _, s := range []string{session, session, ""} {
+		cookies, err := logout(s, community.csrfToken(s))
+		if err != nil || cookies[sessionCookie] == nil || cookies[sessionCookie].MaxAge >= 0 || cookies[csrfCookie] == nil {
+			t.Errorf("RemoveToken(%q) returned %v, set cookies %v, expected them deleted", s, err, cookies)
+		}
+	}
+	if _, _, err := getToken(community, session); err != ErrNotExist {
+		t.Errorf("GetToken() after RemoveToken() returned %v, expected: %v", err, ErrNotExist)
+	}
+
+	sessions := []string{login(t, community), login(t, community)}
+	other := &Session{ID: "other", UId: "2", AccessToken: signTestToken(t, key, "2"), Expiry: time.Now().Add(time.Hour)}
+	community.store.InsertSession(context.TODO(), other)
+	serveSession(sessions[0], community.RequireAuth(func(ctx *yap.Context) { err = community.SignOutEverywhere(ctx) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, session := range sessions {
+		if _, _, err := getToken(community, session); err != ErrNotExist {
+			t.Errorf("GetToken() after SignOutEverywhere() returned %v, expected: %v", err, ErrNotExist)
+		}
+	}
+	if _, _, err := getToken(community, other.ID); err != nil {
+		t.Errorf("SignOutEverywhere() revoked the session of another user: %v", err)
+	}
+
+	// sessions idle for sessionTTL are purged
+	if n, err := community.PurgeSessions(context.TODO(), time.Now()); err != nil || n != 0 {
+		t.Errorf("PurgeSessions() returned %d, %v, expected: 0", n, err)
+	}
+	if n, err := community.PurgeSessions(context.TODO(), time.Now().Add(sessionTTL+time.Minute)); err != nil || n != 1 {
+		t.Errorf("PurgeSessions() returned %d, %v, expected: 1", n, err)
+	}
+}
+
+func TestLoginCookies(t *testing.T) {
+	community := newTestCommunity(t)
+	key := initTestCasdoor(t)
+	casdoor := &testTokens{t: t, sign: func(uid string) string { return signTestToken(t, key, uid) }, expiresIn: time.Hour}
+	casdoor.install(community)
+
+	// the login page hands a random oauth state to the browser
+	states := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/login", nil)
+		w := httptest.NewRecorder()
+		loginURL, err := community.RedirectToCasdoor(&yap.Context{Request: req, ResponseWriter: w}, "http://example.com/p/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != stateCookie || !cookies[0].HttpOnly {
+			t.Fatalf("RedirectToCasdoor() set cookies %v, expected an oauth state", cookies)
+		}
+		state := cookies[0].Value
+		if u, _ := url.Parse(loginURL); u == nil || u.Query().Get("state") != state || u.Query().Get("redirect_uri") != "http://example.com/callback" {
+			t.Errorf("RedirectToCasdoor() returned %q, expected state %q and the callback of the site", loginURL, state)
+		}
+		if returnTo := stateReturnTo(state); returnTo != "/p/1" {
+			t.Errorf("RedirectToCasdoor() set state returning to %q, expected: /p/1", returnTo)
+		}
+		states[state] = true
+	}
+	if len(states) != 2 {
+		t.Errorf("RedirectToCasdoor() returned the same state twice")
+	}
+
+	// the callback is rejected unless it returns the state of the browser
+	req := httptest.NewRequest("GET", "/callback?code=code&state=forged", nil)
+	req.AddCookie(&http.Cookie{Name: stateCookie, Value: "state"})
+	if _, err := community.SetToken(&yap.Context{Request: req, ResponseWriter: httptest.NewRecorder()}); err != ErrPermission {
+		t.Errorf("SetToken() of a forged state returned %v, expected: %v", err, ErrPermission)
+	}
+
+	var err error
+	cookies := serveSession("", func(ctx *yap.Context) { _, err = community.SetToken(ctx) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, csrf, state := cookies[sessionCookie], cookies[csrfCookie], cookies[stateCookie]
+	if session == nil || !session.HttpOnly || session.SameSite != http.SameSiteLaxMode {
+		t.Errorf("SetToken() set session cookie %v, expected HttpOnly and SameSite=Lax", session)
+	}
+	if csrf == nil || csrf.HttpOnly || csrf.Value != community.csrfToken(session.Value) {
+		t.Errorf("SetToken() set CSRF cookie %v, expected the token of the session to scripts", csrf)
+	}
+	if state == nil || state.MaxAge >= 0 {
+		t.Errorf("SetToken() set oauth state cookie %v, expected it deleted", state)
+	}
+	// cookies are sent over https only where the site is served by https
+	community.siteURL = "https://example.com"
+	if cookies = serveSession("", func(ctx *yap.Context) { _, err = community.SetToken(ctx) }); !cookies[sessionCookie].Secure {
+		t.Errorf("SetToken() set session cookie %v on an https site, expected Secure", cookies[sessionCookie])
+	}
+}
@@ -0,0 +1,309 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
+)
+
+// ArticleFilter selects articles in listings. Zero fields select all.
+type ArticleFilter struct {
+	UId      string   // written by UId
+	Search   string   // matches the full-text query Search, best first
+	Tag      string   // tagged with Tag
+	Statuses []Status // in one of Statuses
+	Viewer   string   // or scheduled and written by Viewer
+
+	BookmarkedBy string // bookmarked by the user BookmarkedBy
+	FollowedBy   string // written by the authors followed by FollowedBy
+
+	Hidden bool // include the articles hidden by moderators
+}
+
+// ArticleStore persists articles.
+type ArticleStore interface {
+	// GetArticle returns the article with id and the media id of its html.
+	// The author (User) is not filled in.
+	GetArticle(ctx context.Context, id string) (article *Article, htmlId string, err error)
+	// GetTransHtmlId returns the media id of the translated html of article id.
+	GetTransHtmlId(ctx context.Context, id string) (htmlId string, err error)
+	// IsAuthor reports whether uid wrote article id.
+	IsAuthor(ctx context.Context, uid, id string) (bool, error)
+	// CountArticles counts articles selected by filter.
+	CountArticles(ctx context.Context, filter *ArticleFilter) (total int, err error)
+	// ListArticles lists articles selected by filter, newest first. A limit
+	// <= 0 lists all of them. Search results are ranked and have snippets.
+	ListArticles(ctx context.Context, filter *ArticleFilter, offset, limit int) (items []*ArticleEntry, err error)
+	// PageArticles lists limit articles selected by filter right after c in
+	// the listing order, or right before c if c.Backward. A nil c starts at
+	// the first article.
+	PageArticles(ctx context.Context, filter *ArticleFilter, c *Cursor, limit int) (items []*ArticleEntry, err error)
+	// InsertArticle adds a new article and returns its id. InsertArticle,
+	// UpdateArticle and UpdateContent record the saved content as a revision
+	// saved by the author or editor.
+	// InsertArticle, UpdateArticle and UpdateTransArticle save its tags, and
+	// all of them update the search index.
+	InsertArticle(ctx context.Context, article *Article, htmlId int64) (id string, err error)
+	// UpdateArticle edits article.ID by user editor.
+	UpdateArticle(ctx context.Context, editor string, article *Article, htmlId int64) error
+	// UpdateTransArticle saves article as the translation of article.ID.
+	UpdateTransArticle(ctx context.Context, article *Article, htmlId int64) error
+	// SetArticleStatus changes the status of article id. Drafts and
+	// scheduled articles are dated (Ctime) when they are published.
+	SetArticleStatus(ctx context.Context, id string, status Status) error
+	// ScheduleArticle schedules article id to be published at publishAt.
+	ScheduleArticle(ctx context.Context, id string, publishAt time.Time) error
+	// PublishDue publishes the scheduled articles due at now, dated at their
+	// PublishAt, and returns how many were published.
+	PublishDue(ctx context.Context, now time.Time) (n int, err error)
+	// UpdateContent replaces the markdown and html of article id by user
+	// editor.
+	UpdateContent(ctx context.Context, editor, id, content string, htmlId int64) error
+	// DeleteArticle deletes article id written by uid together with its
+	// revisions, tags, search index and the records of their html medias.
+	DeleteArticle(ctx context.Context, uid, id string) error
+}
+
+// MediaStore persists the records of files uploaded to the blob bucket.
+type MediaStore interface {
+	// SaveFile adds a file record owned by uid and returns its id.
+	SaveFile(ctx context.Context, uid string, file *File) (id int64, err error)
+	// GetFileKey returns the blob key of media id.
+	GetFileKey(ctx context.Context, id string) (fileKey string, err error)
+	// GetFileOwner returns the user who uploaded media id.
+	GetFileOwner(ctx context.Context, id string) (uid string, err error)
+	// GetMediaArticle returns the article whose html, translated or not, is
+	// media id, and whether it is the html of an old revision only. It
+	// returns ErrNotExist for media of no article, such as images.
+	GetMediaArticle(ctx context.Context, id string) (articleId string, old bool, err error)
+	// DeleteFile deletes media id owned by uid. It reports whether a record
+	// was deleted.
+	DeleteFile(ctx context.Context, uid, id string) (deleted bool, err error)
+	// DeleteFiles deletes medias ids owned by uid.
+	DeleteFiles(ctx context.Context, uid string, ids []string) error
+}
+
+// TagStore persists the tags of articles, which are parsed from the tags of
+// articles saved by ArticleStore.
+type TagStore interface {
+	// ListTags lists the tags of the articles in one of statuses with their
+	// number of articles, the most used first.
+	ListTags(ctx context.Context, statuses []Status) (tags []*Tag, err error)
+}
+
+// RevisionStore persists the revisions of articles.
+type RevisionStore interface {
+	// ListRevisions lists the revisions of article id, newest first.
+	ListRevisions(ctx context.Context, id string) (items []*Revision, err error)
+	// GetRevision returns revision id.
+	GetRevision(ctx context.Context, id string) (*Revision, error)
+}
+
+// EngagementStore persists the likes, bookmarks and views of articles. The
+// counts are kept on the articles.
+type EngagementStore interface {
+	// SetLike likes or unlikes article id by uid, and returns its number of
+	// likes. Liking twice is a no-op.
+	SetLike(ctx context.Context, uid, id string, like bool) (likes int, err error)
+	// SetBookmark bookmarks or unbookmarks article id by uid, and returns
+	// its number of bookmarks. Bookmarking twice is a no-op.
+	SetBookmark(ctx context.Context, uid, id string, bookmark bool) (bookmarks int, err error)
+	// Engagement reports whether uid liked and bookmarked article id.
+	Engagement(ctx context.Context, uid, id string) (liked, bookmarked bool, err error)
+	// AddViews adds views to the view counts of articles by id.
+	AddViews(ctx context.Context, views map[string]int64) error
+	// TopArticles lists the published articles with the most likes,
+	// bookmarks and comments since a time, the most engaging first.
+	TopArticles(ctx context.Context, since time.Time, limit int) (items []*ArticleEntry, err error)
+}
+
+// FollowStore persists who follows whom.
+type FollowStore interface {
+	// SetFollow makes uid follow or unfollow followee. Following twice is a
+	// no-op.
+	SetFollow(ctx context.Context, uid, followee string, follow bool) error
+	// IsFollowing reports whether uid follows followee.
+	IsFollowing(ctx context.Context, uid, followee string) (bool, error)
+	// CountFollows counts the users uid follows and the followers of uid.
+	CountFollows(ctx context.Context, uid string) (following, followers int, err error)
+	// ListFollows lists the users uid follows, or the followers of uid if
+	// followers, latest first.
+	ListFollows(ctx context.Context, uid string, followers bool, offset, limit int) (uids []string, err error)
+}
+
+// NotificationStore persists the notifications of users.
+type NotificationStore interface {
+	// InsertNotification adds notification n unless the recipient has an
+	// unread one of the same kind by the same actor on the same target. It
+	// reports whether n was added.
+	InsertNotification(ctx context.Context, n *Notification) (added bool, err error)
+	// ListNotifications lists the notifications of uid after cursor c (nil
+	// for the newest), newest first. The actors (Actor) are not filled in.
+	ListNotifications(ctx context.Context, uid string, c *Cursor, limit int) (items []*Notification, err error)
+	// CountUnread counts the unread notifications of uid.
+	CountUnread(ctx context.Context, uid string) (unread int, err error)
+	// MarkRead marks notifications ids of uid read, or all of them if ids is
+	// empty.
+	MarkRead(ctx context.Context, uid string, ids []string) error
+}
+
+// PrefStore persists the notification preferences of users.
+type PrefStore interface {
+	// GetPrefs returns the preferences of uid, DefaultPrefs if never set.
+	GetPrefs(ctx context.Context, uid string) (*NotificationPrefs, error)
+	// SetPrefs replaces the preferences of uid.
+	SetPrefs(ctx context.Context, uid string, prefs *NotificationPrefs) error
+	// ListDigestDue lists the users subscribed to the digest who haven't
+	// received one since before.
+	ListDigestDue(ctx context.Context, before time.Time, limit int) (uids []string, err error)
+	// SetDigestSent records that uid received a digest at t.
+	SetDigestSent(ctx context.Context, uid string, t time.Time) error
+}
+
+// RoleStore persists the local roles of users, which override their roles
+// from casdoor.
+type RoleStore interface {
+	// GetRole returns the local role of uid, ErrNotExist if not set.
+	GetRole(ctx context.Context, uid string) (Role, error)
+	// SetRole sets the local role of uid, or clears it if role is 0.
+	SetRole(ctx context.Context, uid string, role Role) error
+}
+
+// ModerationStore persists the reports of abuse, the content hidden by
+// moderators, the bans of users and the audit log of moderations.
+type ModerationStore interface {
+	// InsertReport adds report r unless its reporter has an open report on
+	// the same target. It reports whether r was added.
+	InsertReport(ctx context.Context, r *Report) (added bool, err error)
+	// ListReports lists the reports in status after cursor c (nil for the
+	// newest), newest first, with the reported content. The users
+	// (Reporter, Author) are not filled in.
+	ListReports(ctx context.Context, status string, c *Cursor, limit int) (items []*Report, err error)
+	// CountReports counts the reports in status.
+	CountReports(ctx context.Context, status string) (total int, err error)
+	// ResolveReports closes the open reports on target id of kind with
+	// status.
+	ResolveReports(ctx context.Context, kind, id, status string) error
+	// SetHidden hides or restores the article or comment id of kind.
+	SetHidden(ctx context.Context, kind, id string, hidden bool) error
+	// GetBan returns the ban of uid, ErrNotExist if not banned.
+	GetBan(ctx context.Context, uid string) (*Ban, error)
+	// SetBan bans uid, or lifts the ban if ban is nil.
+	SetBan(ctx context.Context, uid string, ban *Ban) error
+	// InsertAudit adds e to the audit log.
+	InsertAudit(ctx context.Context, e *AuditEntry) error
+	// ListAudit lists the audit log after cursor c (nil for the newest),
+	// newest first. The actors (Actor) are not filled in.
+	ListAudit(ctx context.Context, c *Cursor, limit int) (items []*AuditEntry, err error)
+}
+
+// SessionStore persists the login sessions of users, with their tokens.
+type SessionStore interface {
+	// InsertSession adds session s.
+	InsertSession(ctx context.Context, s *Session) error
+	// GetSession returns session id, ErrNotExist if missing.
+	GetSession(ctx context.Context, id string) (*Session, error)
+	// UpdateSession replaces the tokens of session s.
+	UpdateSession(ctx context.Context, s *Session) error
+	// DeleteSession deletes session id. Deleting a missing session is a
+	// no-op.
+	DeleteSession(ctx context.Context, id string) error
+	// DeleteUserSessions deletes the sessions of uid, and returns their
+	// number.
+	DeleteUserSessions(ctx context.Context, uid string) (n int64, err error)
+	// DeleteIdleSessions deletes the sessions last refreshed before t, and
+	// returns their number.
+	DeleteIdleSessions(ctx context.Context, t time.Time) (n int64, err error)
+}
+
+// CommentStore persists the comments on articles.
+type CommentStore interface {
+	// InsertComment adds comment c and returns its id. A reply joins the
+	// thread of its parent.
+	InsertComment(ctx context.Context, c *Comment) (id string, err error)
+	// GetComment returns comment id. The author (User) is not filled in.
+	GetComment(ctx context.Context, id string) (*Comment, error)
+	// UpdateComment replaces the content of comment id.
+	UpdateComment(ctx context.Context, id, content, html string) error
+	// DeleteComment marks comment id deleted and clears its content.
+	DeleteComment(ctx context.Context, id string) error
+	// CountComments counts the comments on article id which aren't deleted
+	// or hidden.
+	CountComments(ctx context.Context, id string) (total int, err error)
+	// ListComments lists the top-level comments on article id, newest first,
+	// after the cursor c if not nil.
+	ListComments(ctx context.Context, id string, c *Cursor, limit int) (items []*Comment, err error)
+	// ListReplies lists the replies in the threads of the top-level comments
+	// roots, oldest first.
+	ListReplies(ctx context.Context, roots []string) (items []*Comment, err error)
+}
+
+// Migrator applies the versioned schema migrations of a Store.
+type Migrator interface {
+	// SchemaVersion returns the version of the newest applied migration.
+	SchemaVersion(ctx context.Context) (version int, err error)
+	// Migrate rolls the schema forward or back to version. LatestVersion
+	// applies all pending migrations.
+	Migrate(ctx context.Context, version int) error
+}
+
+// Store is the storage backend of Community.
+type Store interface {
+	ArticleStore
+	RevisionStore
+	TagStore
+	CommentStore
+	EngagementStore
+	FollowStore
+	NotificationStore
+	PrefStore
+	RoleStore
+	ModerationStore
+	SessionStore
+	MediaStore
+	Migrator
+	Close() error
+}
+
+// OpenStore opens a Store on the database named by driver and dsn. Supported
+// drivers are `mysql` and `sqlite` (pure Go, dsn may be `:memory:`).
+func OpenStore(driver, dsn string) (Store, error) {
+	switch driver {
+	case "mysql":
+		db, err := sql.Open(driver, dsn)
+		if err != nil {
+			return nil, err
+		}
+		return newSQLStore(db, dialectMySQL), nil
+	case "sqlite":
+		db, err := sql.Open(driver, dsn)
+		if err != nil {
+			return nil, err
+		}
+		// sqlite allows one writer only, and every connection to `:memory:`
+		// opens a new database.
+		db.SetMaxOpenConns(1)
+		return newSQLStore(db, dialectSQLite), nil
+	}
+	return nil, fmt.Errorf("core: unsupported database driver %q", driver)
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+const commentColumns = "id, article_id, parent_id, user_id, content, html, deleted, hidden, ctime, mtime"
+
+func scanComment(row interface{ Scan(dest ...any) error }) (*Comment, error) {
+	c := &Comment{}
+	var parentId int64
+	err := row.Scan(&c.ID, &c.ArticleID, &parentId, &c.UId, &c.Content, &c.Html, &c.Deleted, &c.Hidden, &c.Ctime, &c.Mtime)
+	if parentId != 0 {
+		c.ParentID = strconv.FormatInt(parentId, 10)
+	}
+	return c, err
+}
+
+func scanComments(rows *sql.Rows) (items []*Comment, err error) {
+	defer rows.Close()
+	items = []*Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return []*Comment{}, err
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+func (s *sqlStore) InsertComment(ctx context.Context, c *Comment) (id string, err error) {
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		var parentId, rootId int64
+		if c.ParentID != "" {
+			// replies belong to the thread of their parent
+			sqlStr := "select id, root_id from comment where id=?"
+			if err := tx.QueryRowContext(ctx, sqlStr, c.ParentID).Scan(&parentId, &rootId); err != nil {
+				if err == sql.ErrNoRows {
+					return ErrNotExist
+				}
+				return err
+			}
+			if rootId == 0 {
+				rootId = parentId
+			}
+		}
+		now := time.Now().UTC()
+		sqlStr := "insert into comment (article_id, parent_id, root_id, user_id, content, html, ctime, mtime) values (?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err := tx.ExecContext(ctx, sqlStr, c.ArticleID, parentId, rootId, c.UId, c.Content, c.Html, now, now)
+		if err != nil {
+			return err
+		}
+		idInt, err := res.LastInsertId()
+		id = strconv.FormatInt(idInt, 10)
+		return err
+	})
+	return
+}
+
+func (s *sqlStore) GetComment(ctx context.Context, id string) (*Comment, error) {
+	sqlStr := "select " + commentColumns + " from comment where id=?"
+	c, err := scanComment(s.db.QueryRowContext(ctx, sqlStr, id))
+	if err == sql.ErrNoRows {
+		return &Comment{}, ErrNotExist
+	}
+	return c, err
+}
+
+func (s *sqlStore) UpdateComment(ctx context.Context, id, content, html string) error {
+	sqlStr := "update comment set content=?, html=?, mtime=? where id=? and deleted=0"
+	res, err := s.db.ExecContext(ctx, sqlStr, content, html, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (s *sqlStore) DeleteComment(ctx context.Context, id string) error {
+	sqlStr := "update comment set content='', html='', deleted=1, mtime=? where id=? and deleted=0"
+	res, err := s.db.ExecContext(ctx, sqlStr, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (s *sqlStore) CountComments(ctx context.Context, id string) (total int, err error) {
+	sqlStr := "select count(*) from comment where article_id=? and deleted=0 and hidden=0"
+	err = s.db.QueryRowContext(ctx, sqlStr, id).Scan(&total)
+	return
+}
+
+func (s *sqlStore) ListComments(ctx context.Context, id string, c *Cursor, limit int) (items []*Comment, err error) {
+	sqlStr := "select " + commentColumns + " from comment where article_id=? and parent_id=0"
+	args := []any{id}
+	if c != nil {
+		cond, condArgs := c.where(false)
+		sqlStr += " and " + cond
+		args = append(args, condArgs...)
+	}
+	sqlStr += " order by ctime desc, id desc limit ?"
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return []*Comment{}, err
+	}
+	return scanComments(rows)
+}
+
+func (s *sqlStore) ListReplies(ctx context.Context, roots []string) (items []*Comment, err error) {
+	if len(roots) == 0 {
+		return []*Comment{}, nil
+	}
+	args := make([]any, len(roots))
+	for i, root := range roots {
+		args[i] = root
+	}
+	sqlStr := "select " + commentColumns + " from comment where root_id in (" + placeholders(len(roots)) + ") order by ctime, id"
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return []*Comment{}, err
+	}
+	return scanComments(rows)
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *sqlStore) SetLike(ctx context.Context, uid, id string, like bool) (likes int, err error) {
+	return s.setMark(ctx, "article_like", "like_count", uid, id, like)
+}
+
+func (s *sqlStore) SetBookmark(ctx context.Context, uid, id string, bookmark bool) (bookmarks int, err error) {
+	return s.setMark(ctx, "article_bookmark", "bookmark_count", uid, id, bookmark)
+}
+
+// setMark adds or removes the mark of uid on article id in table, and keeps
+// the count column of the article up to date. The count is changed in place
+// by the rows the mark changed, so that concurrent marks aren't lost.
+func (s *sqlStore) setMark(ctx context.Context, table, column, uid, id string, on bool) (count int, err error) {
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		var delta int64
+		if on {
+			var marked int
+			sqlStr := "select count(*) from " + table + " where article_id=? and user_id=?"
+			if err := tx.QueryRowContext(ctx, sqlStr, id, uid).Scan(&marked); err != nil {
+				return err
+			}
+			if marked == 0 {
+				if _, err := tx.ExecContext(ctx, "insert into "+table+" (article_id, user_id, ctime) values (?, ?, ?)", id, uid, time.Now().UTC()); err != nil {
+					return err
+				}
+				delta = 1
+			}
+		} else {
+			res, err := tx.ExecContext(ctx, "delete from "+table+" where article_id=? and user_id=?", id, uid)
+			if err != nil {
+				return err
+			}
+			if delta, err = res.RowsAffected(); err != nil {
+				return err
+			}
+			delta = -delta
+		}
+		if delta != 0 {
+			if _, err := tx.ExecContext(ctx, "update article set "+column+"="+column+"+? where id=?", delta, id); err != nil {
+				return err
+			}
+		}
+		err := tx.QueryRowContext(ctx, "select "+column+" from article where id=?", id).Scan(&count)
+		if err == sql.ErrNoRows {
+			return ErrNotExist
+		}
+		return err
+	})
+	return
+}
+
+func (s *sqlStore) Engagement(ctx context.Context, uid, id string) (liked, bookmarked bool, err error) {
+	sqlStr := "select (select count(*) from article_like where article_id=? and user_id=?), (select count(*) from article_bookmark where article_id=? and user_id=?)"
+	err = s.db.QueryRowContext(ctx, sqlStr, id, uid, id, uid).Scan(&liked, &bookmarked)
+	return
+}
+
+func (s *sqlStore) AddViews(ctx context.Context, views map[string]int64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for id, n := range views {
+			if _, err := tx.ExecContext(ctx, "update article set view_count=view_count+? where id=?", n, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) TopArticles(ctx context.Context, since time.Time, limit int) (items []*ArticleEntry, err error) {
+	since = since.UTC()
+	sqlStr := "select " + articleEntryColumns + " from article join (select article_id, count(*) as n from (" +
+		"select article_id from article_like where ctime >= ? union all " +
+		"select article_id from article_bookmark where ctime >= ? union all " +
+		"select article_id from comment where ctime >= ? and deleted = 0) m group by article_id) e on e.article_id = article.id" +
+		" where status = ? and hidden = 0 order by e.n desc, view_count desc, id desc limit ?"
+	rows, err := s.db.QueryContext(ctx, sqlStr, since, since, since, StatusPublished, limit)
+	if err != nil {
+		return []*ArticleEntry{}, err
+	}
+	return scanArticleEntries(rows, "")
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *sqlStore) SetFollow(ctx context.Context, uid, followee string, follow bool) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var n int
+		sqlStr := "select count(*) from follow where user_id=? and followee_id=?"
+		if err := tx.QueryRowContext(ctx, sqlStr, uid, followee).Scan(&n); err != nil {
+			return err
+		}
+		if (n > 0) == follow {
+			return nil
+		}
+		var err error
+		if follow {
+			_, err = tx.ExecContext(ctx, "insert into follow (user_id, followee_id, ctime) values (?, ?, ?)", uid, followee, time.Now().UTC())
+		} else {
+			_, err = tx.ExecContext(ctx, "delete from follow where user_id=? and followee_id=?", uid, followee)
+		}
+		return err
+	})
+}
+
+func (s *sqlStore) IsFollowing(ctx context.Context, uid, followee string) (following bool, err error) {
+	sqlStr := "select count(*) from follow where user_id=? and followee_id=?"
+	err = s.db.QueryRowContext(ctx, sqlStr, uid, followee).Scan(&following)
+	return
+}
+
+func (s *sqlStore) CountFollows(ctx context.Context, uid string) (following, followers int, err error) {
+	sqlStr := "select (select count(*) from follow where user_id=?), (select count(*) from follow where followee_id=?)"
+	err = s.db.QueryRowContext(ctx, sqlStr, uid, uid).Scan(&following, &followers)
+	return
+}
+
+func (s *sqlStore) ListFollows(ctx context.Context, uid string, followers bool, offset, limit int) (uids []string, err error) {
+	sqlStr := "select followee_id from follow where user_id=? order by ctime desc, followee_id limit ? offset ?"
+	if followers {
+		sqlStr = "select user_id from follow where followee_id=? order by ctime desc, user_id limit ? offset ?"
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, uid, limit, offset)
+	if err != nil {
+		return []string{}, err
+	}
+	defer rows.Close()
+	uids = []string{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return []string{}, err
+		}
+		uids = append(uids, id)
+	}
+	return uids, rows.Err()
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// reportTarget selects a column of the reported article or comment, or def
+// if it is deleted.
+func reportTarget(articleColumn, commentColumn, def string) string {
+	return "coalesce((select " + articleColumn + " from article where report.kind = 'article' and article.id = report.target_id), " +
+		"(select " + commentColumn + " from comment where report.kind = 'comment' and comment.id = report.target_id and comment.deleted = 0), " + def + ")"
+}
+
+var reportColumns = "id, reporter_id, kind, target_id, reason, status, ctime, " +
+	reportTarget("id", "article_id", "0") + ", " +
+	reportTarget("user_id", "user_id", "''") + ", " +
+	reportTarget("title", "content", "''") + ", " +
+	reportTarget("hidden", "hidden", "0")
+
+func (s *sqlStore) InsertReport(ctx context.Context, r *Report) (added bool, err error) {
+	targetId, err := strconv.ParseInt(r.TargetID, 10, 64)
+	if err != nil {
+		return false, ErrNotExist
+	}
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		var count int
+		sqlStr := "select count(*) from report where reporter_id=? and kind=? and target_id=? and status=?"
+		if err := tx.QueryRowContext(ctx, sqlStr, r.ReporterID, r.Kind, targetId, ReportOpen).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		now := time.Now().UTC()
+		sqlStr = "insert into report (reporter_id, kind, target_id, reason, status, ctime, mtime) values (?, ?, ?, ?, ?, ?, ?)"
+		_, err := tx.ExecContext(ctx, sqlStr, r.ReporterID, r.Kind, targetId, r.Reason, ReportOpen, now, now)
+		added = err == nil
+		return err
+	})
+	return
+}
+
+func (s *sqlStore) ListReports(ctx context.Context, status string, c *Cursor, limit int) (items []*Report, err error) {
+	sqlStr := "select " + reportColumns + " from report where status=?"
+	args := []any{status}
+	if c != nil {
+		cond, condArgs := c.where(false)
+		sqlStr += " and " + cond
+		args = append(args, condArgs...)
+	}
+	sqlStr += " order by ctime desc, id desc limit ?"
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return []*Report{}, err
+	}
+	defer rows.Close()
+	items = []*Report{}
+	for rows.Next() {
+		r := &Report{}
+		var targetId, articleId int64
+		err = rows.Scan(&r.ID, &r.ReporterID, &r.Kind, &targetId, &r.Reason, &r.Status, &r.Ctime, &articleId, &r.AuthorID, &r.Text, &r.Hidden)
+		if err != nil {
+			return []*Report{}, err
+		}
+		r.TargetID = strconv.FormatInt(targetId, 10)
+		if articleId != 0 {
+			r.ArticleID = strconv.FormatInt(articleId, 10)
+		}
+		items = append(items, r)
+	}
+	return items, rows.Err()
+}
+
+func (s *sqlStore) CountReports(ctx context.Context, status string) (total int, err error) {
+	err = s.db.QueryRowContext(ctx, "select count(*) from report where status=?", status).Scan(&total)
+	return
+}
+
+func (s *sqlStore) ResolveReports(ctx context.Context, kind, id, status string) error {
+	sqlStr := "update report set status=?, mtime=? where kind=? and target_id=? and status=?"
+	_, err := s.db.ExecContext(ctx, sqlStr, status, time.Now().UTC(), kind, id, ReportOpen)
+	return err
+}
+
+func (s *sqlStore) SetHidden(ctx context.Context, kind, id string, hidden bool) error {
+	var sqlStr string
+	switch kind {
+	case KindArticle:
+		sqlStr = "update article set hidden=? where id=?"
+	case KindComment:
+		sqlStr = "update comment set hidden=? where id=?"
+	default:
+		return errInvalidKind
+	}
+	res, err := s.db.ExecContext(ctx, sqlStr, hidden, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (s *sqlStore) GetBan(ctx context.Context, uid string) (*Ban, error) {
+	ban := &Ban{UId: uid}
+	sqlStr := "select actor_id, reason, ctime from user_ban where user_id=?"
+	err := s.db.QueryRowContext(ctx, sqlStr, uid).Scan(&ban.ActorID, &ban.Reason, &ban.Ctime)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotExist
+	}
+	return ban, err
+}
+
+func (s *sqlStore) SetBan(ctx context.Context, uid string, ban *Ban) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "delete from user_ban where user_id=?", uid); err != nil {
+			return err
+		}
+		if ban == nil {
+			return nil
+		}
+		sqlStr := "insert into user_ban (user_id, actor_id, reason, ctime) values (?, ?, ?, ?)"
+		_, err := tx.ExecContext(ctx, sqlStr, uid, ban.ActorID, ban.Reason, time.Now().UTC())
+		return err
+	})
+}
+
+func (s *sqlStore) InsertAudit(ctx context.Context, e *AuditEntry) error {
+	sqlStr := "insert into audit_log (actor_id, action, kind, target_id, detail, ctime) values (?, ?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, sqlStr, e.ActorID, e.Action, e.Kind, e.TargetID, e.Detail, time.Now().UTC())
+	return err
+}
+
+func (s *sqlStore) ListAudit(ctx context.Context, c *Cursor, limit int) (items []*AuditEntry, err error) {
+	sqlStr := "select id, actor_id, action, kind, target_id, detail, ctime from audit_log"
+	var args []any
+	if c != nil {
+		cond, condArgs := c.where(false)
+		sqlStr += " where " + cond
+		args = append(args, condArgs...)
+	}
+	sqlStr += " order by ctime desc, id desc limit ?"
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return []*AuditEntry{}, err
+	}
+	defer rows.Close()
+	items = []*AuditEntry{}
+	for rows.Next() {
+		e := &AuditEntry{}
+		if err = rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.Kind, &e.TargetID, &e.Detail, &e.Ctime); err != nil {
+			return []*AuditEntry{}, err
+		}
+		items = append(items, e)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+const notificationColumns = "id, user_id, actor_id, kind, article_id, comment_id, " +
+	"coalesce((select title from article where article.id = notification.article_id), ''), is_read, ctime"
+
+func (s *sqlStore) InsertNotification(ctx context.Context, n *Notification) (added bool, err error) {
+	articleId, _ := strconv.ParseInt(n.ArticleID, 10, 64)
+	commentId, _ := strconv.ParseInt(n.CommentID, 10, 64)
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		var count int
+		sqlStr := "select count(*) from notification where user_id=? and actor_id=? and kind=? and article_id=? and comment_id=? and is_read=0"
+		if err := tx.QueryRowContext(ctx, sqlStr, n.UId, n.ActorID, n.Kind, articleId, commentId).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		sqlStr = "insert into notification (user_id, actor_id, kind, article_id, comment_id, ctime) values (?, ?, ?, ?, ?, ?)"
+		_, err := tx.ExecContext(ctx, sqlStr, n.UId, n.ActorID, n.Kind, articleId, commentId, time.Now().UTC())
+		added = err == nil
+		return err
+	})
+	return
+}
+
+func (s *sqlStore) ListNotifications(ctx context.Context, uid string, c *Cursor, limit int) (items []*Notification, err error) {
+	sqlStr := "select " + notificationColumns + " from notification where user_id=?"
+	args := []any{uid}
+	if c != nil {
+		cond, condArgs := c.where(false)
+		sqlStr += " and " + cond
+		args = append(args, condArgs...)
+	}
+	sqlStr += " order by ctime desc, id desc limit ?"
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return []*Notification{}, err
+	}
+	defer rows.Close()
+	items = []*Notification{}
+	for rows.Next() {
+		n := &Notification{}
+		var articleId, commentId int64
+		err = rows.Scan(&n.ID, &n.UId, &n.ActorID, &n.Kind, &articleId, &commentId, &n.ArticleTitle, &n.Read, &n.Ctime)
+		if err != nil {
+			return []*Notification{}, err
+		}
+		if articleId != 0 {
+			n.ArticleID = strconv.FormatInt(articleId, 10)
+		}
+		if commentId != 0 {
+			n.CommentID = strconv.FormatInt(commentId, 10)
+		}
+		items = append(items, n)
+	}
+	return items, rows.Err()
+}
+
+func (s *sqlStore) CountUnread(ctx context.Context, uid string) (unread int, err error) {
+	sqlStr := "select count(*) from notification where user_id=? and is_read=0"
+	err = s.db.QueryRowContext(ctx, sqlStr, uid).Scan(&unread)
+	return
+}
+
+func (s *sqlStore) MarkRead(ctx context.Context, uid string, ids []string) error {
+	sqlStr := "update notification set is_read=1 where user_id=? and is_read=0"
+	args := []any{uid}
+	if len(ids) > 0 {
+		sqlStr += " and id in (" + placeholders(len(ids)) + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	_, err := s.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *sqlStore) GetPrefs(ctx context.Context, uid string) (*NotificationPrefs, error) {
+	prefs := DefaultPrefs
+	sqlStr := "select email_replies, email_digest from notification_pref where user_id=?"
+	err := s.db.QueryRowContext(ctx, sqlStr, uid).Scan(&prefs.EmailReplies, &prefs.EmailDigest)
+	if err != nil && err != sql.ErrNoRows {
+		return &DefaultPrefs, err
+	}
+	return &prefs, nil
+}
+
+func (s *sqlStore) SetPrefs(ctx context.Context, uid string, prefs *NotificationPrefs) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRowContext(ctx, "select count(*) from notification_pref where user_id=?", uid).Scan(&n); err != nil {
+			return err
+		}
+		sqlStr := "update notification_pref set email_replies=?, email_digest=?, mtime=? where user_id=?"
+		if n == 0 {
+			sqlStr = "insert into notification_pref (email_replies, email_digest, mtime, user_id) values (?, ?, ?, ?)"
+		}
+		_, err := tx.ExecContext(ctx, sqlStr, prefs.EmailReplies, prefs.EmailDigest, time.Now().UTC(), uid)
+		return err
+	})
+}
+
+func (s *sqlStore) ListDigestDue(ctx context.Context, before time.Time, limit int) (uids []string, err error) {
+	sqlStr := "select user_id from notification_pref where email_digest=1 and (digest_time is null or digest_time < ?) order by user_id limit ?"
+	rows, err := s.db.QueryContext(ctx, sqlStr, before.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var uid string
+		if err = rows.Scan(&uid); err != nil {
+			return nil, err
+		}
+		uids = append(uids, uid)
+	}
+	return uids, rows.Err()
+}
+
+func (s *sqlStore) SetDigestSent(ctx context.Context, uid string, t time.Time) error {
+	_, err := s.db.ExecContext(ctx, "update notification_pref set digest_time=? where user_id=?", t.UTC(), uid)
+	return err
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *sqlStore) GetRole(ctx context.Context, uid string) (role Role, err error) {
+	err = s.db.QueryRowContext(ctx, "select role from user_role where user_id=?", uid).Scan(&role)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotExist
+	}
+	return
+}
+
+func (s *sqlStore) SetRole(ctx context.Context, uid string, role Role) error {
+	if role == 0 {
+		_, err := s.db.ExecContext(ctx, "delete from user_role where user_id=?", uid)
+		return err
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRowContext(ctx, "select count(*) from user_role where user_id=?", uid).Scan(&n); err != nil {
+			return err
+		}
+		sqlStr := "update user_role set role=?, mtime=? where user_id=?"
+		if n == 0 {
+			sqlStr = "insert into user_role (role, mtime, user_id) values (?, ?, ?)"
+		}
+		_, err := tx.ExecContext(ctx, sqlStr, role, time.Now().UTC(), uid)
+		return err
+	})
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/goplus/community/internal/search"
+	"github.com/goplus/community/markdown"
+)
+
+// searchFields are the indexed fields of articles with their weights.
+var searchFields = []struct {
+	column string
+	weight int
+}{
+	{"title", 8},
+	{"tags", 4},
+	{"abstract", 2},
+	{"content", 1},
+}
+
+// snippetSize is the length of search snippets in runes.
+const snippetSize = 160
+
+// indexArticle updates the search index of article id.
+func indexArticle(ctx context.Context, tx *sql.Tx, id string) error {
+	fields := make([]any, len(searchFields))
+	columns := make([]string, len(searchFields))
+	for i, f := range searchFields {
+		fields[i] = new(string)
+		columns[i] = f.column
+	}
+	sqlStr := "select " + strings.Join(columns, ", ") + " from article where id=?"
+	err := tx.QueryRowContext(ctx, sqlStr, id).Scan(fields...)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "delete from search_index where article_id=?", id); err != nil {
+		return err
+	}
+
+	weights := make(map[string]int)
+	for i, f := range searchFields {
+		text := *fields[i].(*string)
+		if f.column == "content" {
+			text = markdown.PlainText(text)
+		}
+		for term, n := range search.IndexTerms(text) {
+			weights[term] += n * f.weight
+		}
+	}
+	terms := make([]string, 0, len(weights))
+	for term := range weights {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	// insert in batches
+	const batch = 100
+	for len(terms) > 0 {
+		n := len(terms)
+		if n > batch {
+			n = batch
+		}
+		args := make([]any, 0, 3*n)
+		for _, term := range terms[:n] {
+			args = append(args, term, id, weights[term])
+		}
+		sqlStr := "insert into search_index (term, article_id, weight) values " + strings.TrimSuffix(strings.Repeat("(?, ?, ?),", n), ",")
+		if _, err = tx.ExecContext(ctx, sqlStr, args...); err != nil {
+			return err
+		}
+		terms = terms[n:]
+	}
+	return nil
+}
+
+// backfillSearchIndex indexes the articles saved before the search index was
+// added.
+func backfillSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "select id from article")
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = indexArticle(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchJoin returns the join of article with the articles matching all the
+// terms of query and their tf-idf score, as column `score`. It returns an
+// empty join if no article may match.
+func (s *sqlStore) searchJoin(ctx context.Context, query string) (join string, args []any, err error) {
+	terms := search.QueryTerms(query)
+	if len(terms) == 0 {
+		return
+	}
+	termArgs := make([]any, len(terms))
+	for i, term := range terms {
+		termArgs[i] = term
+	}
+
+	// inverse document frequencies
+	var total int
+	if err = s.db.QueryRowContext(ctx, "select count(*) from article").Scan(&total); err != nil {
+		return
+	}
+	sqlStr := "select term, count(*) from search_index where term in (" + placeholders(len(terms)) + ") group by term"
+	rows, err := s.db.QueryContext(ctx, sqlStr, termArgs...)
+	if err != nil {
+		return
+	}
+	idf := make(map[string]float64)
+	for rows.Next() {
+		var term string
+		var df int
+		if err = rows.Scan(&term, &df); err != nil {
+			rows.Close()
+			return
+		}
+		idf[term] = math.Log(1 + float64(total)/float64(df))
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil || len(idf) < len(terms) {
+		return
+	}
+
+	var score strings.Builder
+	// rounded to compare them with the scores of cursors
+	score.WriteString("round(sum(case term")
+	for _, term := range terms {
+		score.WriteString(" when ? then weight * ?")
+		args = append(args, term, idf[term])
+	}
+	score.WriteString(" end), 6)")
+	args = append(args, termArgs...)
+	args = append(args, len(terms))
+	join = " join (select article_id, " + score.String() + " as score from search_index where term in (" +
+		placeholders(len(terms)) + ") group by article_id having count(*) = ?) r on r.article_id = article.id"
+	return
+}
+
+func (s *sqlStore) countSearch(ctx context.Context, filter *ArticleFilter) (total int, err error) {
+	join, args, err := s.searchJoin(ctx, filter.Search)
+	if err != nil || join == "" {
+		return
+	}
+	where, whereArgs := filter.where()
+	err = s.db.QueryRowContext(ctx, "select count(*) from article"+join+where, append(args, whereArgs...)...).Scan(&total)
+	return
+}
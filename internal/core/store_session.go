@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *sqlStore) InsertSession(ctx context.Context, sess *Session) error {
+	now := time.Now().UTC()
+	sqlStr := "insert into user_session (id, user_id, access_token, refresh_token, expiry, ctime, mtime) values (?, ?, ?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, sqlStr, sess.ID, sess.UId, sess.AccessToken, sess.RefreshToken, sess.Expiry.UTC(), now, now)
+	return err
+}
+
+func (s *sqlStore) GetSession(ctx context.Context, id string) (*Session, error) {
+	sess := &Session{ID: id}
+	sqlStr := "select user_id, access_token, refresh_token, expiry, ctime, mtime from user_session where id=?"
+	err := s.db.QueryRowContext(ctx, sqlStr, id).Scan(&sess.UId, &sess.AccessToken, &sess.RefreshToken, &sess.Expiry, &sess.Ctime, &sess.Mtime)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotExist
+	}
+	return sess, err
+}
+
+func (s *sqlStore) UpdateSession(ctx context.Context, sess *Session) error {
+	sqlStr := "update user_session set access_token=?, refresh_token=?, expiry=?, mtime=? where id=?"
+	res, err := s.db.ExecContext(ctx, sqlStr, sess.AccessToken, sess.RefreshToken, sess.Expiry.UTC(), time.Now().UTC(), sess.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (s *sqlStore) DeleteSession(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "delete from user_session where id=?", id)
+	return err
+}
+
+func (s *sqlStore) DeleteUserSessions(ctx context.Context, uid string) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "delete from user_session where user_id=?", uid)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *sqlStore) DeleteIdleSessions(ctx context.Context, t time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "delete from user_session where mtime<?", t.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,581 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goplus/community/internal/search"
+	"github.com/goplus/community/markdown"
+)
+
+type dialect int
+
+const (
+	dialectMySQL dialect = iota
+	dialectSQLite
+)
+
+// sqlStore implements Store on MySQL and SQLite. Both accept the same
+// statements with `?` placeholders.
+type sqlStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+func newSQLStore(db *sql.DB, d dialect) *sqlStore {
+	return &sqlStore{db: db, dialect: d}
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+const articleEntryColumns = "id, title, ctime, mtime, user_id, tags, abstract, cover, status, publish_at, like_count, bookmark_count, view_count, hidden"
+
+// scanArticleEntries scans the rows of articleEntryColumns. Search results
+// have their content and score too.
+func scanArticleEntries(rows *sql.Rows, query string) (items []*ArticleEntry, err error) {
+	defer rows.Close()
+	items = []*ArticleEntry{}
+	for rows.Next() {
+		article := &ArticleEntry{}
+		var publishAt sql.NullTime
+		var content string
+		dest := []any{&article.ID, &article.Title, &article.Ctime, &article.Mtime, &article.UId, &article.Tags, &article.Abstract, &article.Cover, &article.Status, &publishAt, &article.Likes, &article.Bookmarks, &article.Views, &article.Hidden}
+		if query != "" {
+			dest = append(dest, &content, &article.score)
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return []*ArticleEntry{}, err
+		}
+		article.PublishAt = publishAt.Time
+		if query != "" {
+			article.Snippet = search.Snippet(markdown.PlainText(content), query, snippetSize)
+		}
+		items = append(items, article)
+	}
+	return items, rows.Err()
+}
+
+// nullTime stores the zero time as null. Times are stored and compared in
+// UTC, which also drops the monotonic clock reading, because sqlite compares
+// them as text.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// where returns the where clause selecting the articles of filter, except
+// filter.Search which is matched by searchJoin.
+func (f *ArticleFilter) where() (clause string, args []any) {
+	var conds []string
+	if f.UId != "" {
+		conds = append(conds, "user_id = ?")
+		args = append(args, f.UId)
+	}
+	if f.Tag != "" {
+		conds = append(conds, "id in (select at.article_id from article_tag at join tag t on t.id = at.tag_id where t.name = ?)")
+		args = append(args, f.Tag)
+	}
+	if f.BookmarkedBy != "" {
+		conds = append(conds, "id in (select article_id from article_bookmark where user_id = ?)")
+		args = append(args, f.BookmarkedBy)
+	}
+	if f.FollowedBy != "" {
+		conds = append(conds, "user_id in (select followee_id from follow where user_id = ?)")
+		args = append(args, f.FollowedBy)
+	}
+	if len(f.Statuses) > 0 {
+		cond := "status in (" + placeholders(len(f.Statuses)) + ")"
+		for _, status := range f.Statuses {
+			args = append(args, status)
+		}
+		if f.Viewer != "" {
+			cond = "(" + cond + " or (status = ? and user_id = ?))"
+			args = append(args, StatusScheduled, f.Viewer)
+		}
+		conds = append(conds, cond)
+	}
+	if !f.Hidden {
+		conds = append(conds, "hidden = 0")
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " where " + strings.Join(conds, " and "), args
+}
+
+func (s *sqlStore) GetArticle(ctx context.Context, id string) (article *Article, htmlId string, err error) {
+	article = &Article{}
+	var publishAt sql.NullTime
+	sqlStr := "select id,title,user_id,cover,tags,abstract,content,html_id,ctime,mtime,status,publish_at,like_count,bookmark_count,view_count,hidden from article where id=?"
+	err = s.db.QueryRowContext(ctx, sqlStr, id).Scan(&article.ID, &article.Title, &article.UId, &article.Cover, &article.Tags, &article.Abstract, &article.Content, &htmlId, &article.Ctime, &article.Mtime, &article.Status, &publishAt, &article.Likes, &article.Bookmarks, &article.Views, &article.Hidden)
+	if err == sql.ErrNoRows {
+		return &Article{}, "", ErrNotExist
+	}
+	article.PublishAt = publishAt.Time
+	return
+}
+
+func (s *sqlStore) GetTransHtmlId(ctx context.Context, id string) (htmlId string, err error) {
+	sqlStr := "select trans_html_id from article where id=?"
+	err = s.db.QueryRowContext(ctx, sqlStr, id).Scan(&htmlId)
+	if err == sql.ErrNoRows {
+		return "", ErrNotExist
+	}
+	return
+}
+
+func (s *sqlStore) IsAuthor(ctx context.Context, uid, id string) (bool, error) {
+	sqlStr := "select id from article where id=? and user_id=?"
+	err := s.db.QueryRowContext(ctx, sqlStr, id, uid).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *sqlStore) CountArticles(ctx context.Context, filter *ArticleFilter) (total int, err error) {
+	if filter.Search != "" {
+		return s.countSearch(ctx, filter)
+	}
+	where, args := filter.where()
+	err = s.db.QueryRowContext(ctx, "select count(*) from article"+where, args...).Scan(&total)
+	return
+}
+
+func (s *sqlStore) ListArticles(ctx context.Context, filter *ArticleFilter, offset, limit int) (items []*ArticleEntry, err error) {
+	return s.listArticles(ctx, filter, nil, offset, limit)
+}
+
+func (s *sqlStore) PageArticles(ctx context.Context, filter *ArticleFilter, c *Cursor, limit int) (items []*ArticleEntry, err error) {
+	return s.listArticles(ctx, filter, c, 0, limit)
+}
+
+// listArticles lists the articles selected by filter in the listing order,
+// from offset or right after the cursor c if not nil.
+func (s *sqlStore) listArticles(ctx context.Context, filter *ArticleFilter, c *Cursor, offset, limit int) (items []*ArticleEntry, err error) {
+	columns := articleEntryColumns
+	order := []string{"ctime", "id"}
+	var join string
+	var args []any
+	if filter.Search != "" {
+		join, args, err = s.searchJoin(ctx, filter.Search)
+		if err != nil || join == "" {
+			return []*ArticleEntry{}, err
+		}
+		columns += ", content, r.score"
+		order = append([]string{"r.score"}, order...)
+	}
+	where, whereArgs := filter.where()
+	args = append(args, whereArgs...)
+	dir := " desc"
+	if c != nil {
+		cond, condArgs := c.where(filter.Search != "")
+		if where == "" {
+			where = " where " + cond
+		} else {
+			where += " and " + cond
+		}
+		args = append(args, condArgs...)
+		if c.Backward {
+			dir = " asc"
+		}
+	}
+	sqlStr := "select " + columns + " from article" + join + where + " order by " + strings.Join(order, dir+", ") + dir
+	if limit > 0 {
+		sqlStr += " limit ? offset ?"
+		args = append(args, limit, offset)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return []*ArticleEntry{}, err
+	}
+	items, err = scanArticleEntries(rows, filter.Search)
+	if c != nil && c.Backward {
+		// backward pages are read in reverse
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	return
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds.
+func (s *sqlStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
+
+// saveRevision records the current content of article id as a revision
+// saved by editor.
+func saveRevision(ctx context.Context, tx execer, id, editor string) error {
+	sqlStr := "insert into article_revision (article_id, user_id, title, tags, content, html_id, ctime) select id, ?, title, tags, content, html_id, mtime from article where id=?"
+	_, err := tx.ExecContext(ctx, sqlStr, editor, id)
+	return err
+}
+
+func (s *sqlStore) InsertArticle(ctx context.Context, article *Article, htmlId int64) (id string, err error) {
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		sqlStr := "insert into article (title, ctime, mtime, user_id, tags, abstract, cover, content, html_id, status, publish_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err := tx.ExecContext(ctx, sqlStr, article.Title, now, now, article.UId, article.Tags, article.Abstract, article.Cover, article.Content, htmlId, article.Status, nullTime(article.PublishAt))
+		if err != nil {
+			return err
+		}
+		idInt, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		id = strconv.FormatInt(idInt, 10)
+		if err = setArticleTags(ctx, tx, id, article.Tags); err != nil {
+			return err
+		}
+		if err = indexArticle(ctx, tx, id); err != nil {
+			return err
+		}
+		return saveRevision(ctx, tx, id, article.UId)
+	})
+	if err != nil {
+		return "", err
+	}
+	return
+}
+
+// publishCtime is the assignment of ctime changing the status of an article
+// to the parameter status at the parameter time: drafts and scheduled
+// articles are dated when they go live, so that they are listed on top
+// rather than at when they were drafted. It comes first in the set clause,
+// before status is assigned, as MySQL sees the values assigned earlier in
+// the clause.
+const publishCtime = "ctime=case when ?=? and status in (?, ?) then ? else ctime end"
+
+func publishCtimeArgs(status Status, now time.Time) []interface{} {
+	return []interface{}{status, StatusPublished, StatusDraft, StatusScheduled, now}
+}
+
+func (s *sqlStore) UpdateArticle(ctx context.Context, editor string, article *Article, htmlId int64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		sqlStr := "update article set " + publishCtime + ", title=?, mtime=?, tags=?, abstract=?, cover=?, content=?, html_id=?, status=?, publish_at=? where id=?"
+		args := append(publishCtimeArgs(article.Status, now), article.Title, now, article.Tags, article.Abstract, article.Cover, article.Content, htmlId, article.Status, nullTime(article.PublishAt), article.ID)
+		_, err := tx.ExecContext(ctx, sqlStr, args...)
+		if err != nil {
+			return err
+		}
+		if err = setArticleTags(ctx, tx, article.ID, article.Tags); err != nil {
+			return err
+		}
+		if err = indexArticle(ctx, tx, article.ID); err != nil {
+			return err
+		}
+		return saveRevision(ctx, tx, article.ID, editor)
+	})
+}
+
+func (s *sqlStore) UpdateTransArticle(ctx context.Context, article *Article, htmlId int64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		sqlStr := "update article set title=?, mtime=?, ctime=?, tags=?, abstract=?, cover=?, trans_content=?, trans_html_id=?, status=?, publish_at=? where id=?"
+		_, err := tx.ExecContext(ctx, sqlStr, article.Title, now, now, article.Tags, article.Abstract, article.Cover, article.Content, htmlId, article.Status, nullTime(article.PublishAt), article.ID)
+		if err != nil {
+			return err
+		}
+		if err = setArticleTags(ctx, tx, article.ID, article.Tags); err != nil {
+			return err
+		}
+		return indexArticle(ctx, tx, article.ID)
+	})
+}
+
+func (s *sqlStore) SetArticleStatus(ctx context.Context, id string, status Status) error {
+	now := time.Now().UTC()
+	sqlStr := "update article set " + publishCtime + ", status=?, mtime=? where id=?"
+	res, err := s.db.ExecContext(ctx, sqlStr, append(publishCtimeArgs(status, now), status, now, id)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (s *sqlStore) ScheduleArticle(ctx context.Context, id string, publishAt time.Time) error {
+	sqlStr := "update article set status=?, publish_at=?, mtime=? where id=?"
+	res, err := s.db.ExecContext(ctx, sqlStr, StatusScheduled, publishAt.UTC(), time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (s *sqlStore) PublishDue(ctx context.Context, now time.Time) (n int, err error) {
+	// scheduled articles are dated when they go live, see publishCtime
+	sqlStr := "update article set ctime=publish_at, status=? where status=? and publish_at<=?"
+	res, err := s.db.ExecContext(ctx, sqlStr, StatusPublished, StatusScheduled, now.UTC())
+	if err != nil {
+		return
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+func (s *sqlStore) UpdateContent(ctx context.Context, editor, id, content string, htmlId int64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		sqlStr := "update article set content=?, html_id=?, mtime=? where id=?"
+		_, err := tx.ExecContext(ctx, sqlStr, content, htmlId, time.Now().UTC(), id)
+		if err != nil {
+			return err
+		}
+		if err = indexArticle(ctx, tx, id); err != nil {
+			return err
+		}
+		return saveRevision(ctx, tx, id, editor)
+	})
+}
+
+func (s *sqlStore) DeleteArticle(ctx context.Context, uid, id string) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// get htmlIds of the article and its revisions
+	var htmlIds []string
+	sqlStr := "select html_id from article where id=? and user_id=? union select r.html_id from article_revision r join article a on a.id = r.article_id where a.id=? and a.user_id=?"
+	rows, err := tx.QueryContext(ctx, sqlStr, id, uid, id, uid)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var htmlId string
+		if err = rows.Scan(&htmlId); err != nil {
+			rows.Close()
+			return
+		}
+		htmlIds = append(htmlIds, htmlId)
+	}
+	rows.Close()
+	if err = deleteFiles(ctx, tx, uid, htmlIds); err != nil {
+		return
+	}
+
+	res, err := tx.ExecContext(ctx, "delete from article where id=? and user_id=?", id, uid)
+	if err != nil {
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = ErrNotExist
+		return
+	}
+	if _, err = tx.ExecContext(ctx, "delete from article_revision where article_id=?", id); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, "delete from article_tag where article_id=?", id); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, "delete from search_index where article_id=?", id); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, "delete from comment where article_id=?", id); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, "delete from article_like where article_id=?", id); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, "delete from article_bookmark where article_id=?", id); err != nil {
+		return
+	}
+	return tx.Commit()
+}
+
+// setArticleTags replaces the tags of article id by the tags parsed from tags.
+func setArticleTags(ctx context.Context, tx *sql.Tx, id, tags string) error {
+	if _, err := tx.ExecContext(ctx, "delete from article_tag where article_id=?", id); err != nil {
+		return err
+	}
+	for _, name := range ParseTags(tags) {
+		var tagId int64
+		err := tx.QueryRowContext(ctx, "select id from tag where name=?", name).Scan(&tagId)
+		if err == sql.ErrNoRows {
+			var res sql.Result
+			res, err = tx.ExecContext(ctx, "insert into tag (name) values (?)", name)
+			if err == nil {
+				tagId, err = res.LastInsertId()
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, "insert into article_tag (article_id, tag_id) values (?, ?)", id, tagId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) ListTags(ctx context.Context, statuses []Status) (tags []*Tag, err error) {
+	sqlStr := "select t.name, count(*) as n from tag t join article_tag at on at.tag_id = t.id join article a on a.id = at.article_id where a.hidden = 0"
+	args := make([]any, 0, len(statuses))
+	if len(statuses) > 0 {
+		sqlStr += " and a.status in (" + placeholders(len(statuses)) + ")"
+		for _, status := range statuses {
+			args = append(args, status)
+		}
+	}
+	sqlStr += " group by t.name order by n desc, t.name"
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return []*Tag{}, err
+	}
+	defer rows.Close()
+	tags = []*Tag{}
+	for rows.Next() {
+		tag := &Tag{}
+		if err = rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return []*Tag{}, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+const revisionColumns = "id, article_id, user_id, title, tags, content, html_id, ctime"
+
+func scanRevision(row interface{ Scan(dest ...any) error }) (*Revision, error) {
+	rev := &Revision{}
+	err := row.Scan(&rev.ID, &rev.ArticleID, &rev.UId, &rev.Title, &rev.Tags, &rev.Content, &rev.HtmlId, &rev.Ctime)
+	return rev, err
+}
+
+func (s *sqlStore) ListRevisions(ctx context.Context, id string) (items []*Revision, err error) {
+	sqlStr := "select " + revisionColumns + " from article_revision where article_id=? order by id desc"
+	rows, err := s.db.QueryContext(ctx, sqlStr, id)
+	if err != nil {
+		return []*Revision{}, err
+	}
+	defer rows.Close()
+	items = []*Revision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return []*Revision{}, err
+		}
+		items = append(items, rev)
+	}
+	return items, rows.Err()
+}
+
+func (s *sqlStore) GetRevision(ctx context.Context, id string) (*Revision, error) {
+	sqlStr := "select " + revisionColumns + " from article_revision where id=?"
+	rev, err := scanRevision(s.db.QueryRowContext(ctx, sqlStr, id))
+	if err == sql.ErrNoRows {
+		return &Revision{}, ErrNotExist
+	}
+	return rev, err
+}
+
+func (s *sqlStore) SaveFile(ctx context.Context, uid string, file *File) (id int64, err error) {
+	now := time.Now().UTC()
+	sqlStr := "insert into file (file_key,format,size,user_id,create_at,update_at) values (?,?,?,?,?,?)"
+	res, err := s.db.ExecContext(ctx, sqlStr, file.FileKey, file.Format, file.Size, uid, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *sqlStore) GetFileKey(ctx context.Context, id string) (fileKey string, err error) {
+	err = s.db.QueryRowContext(ctx, "select file_key from file where id = ?", id).Scan(&fileKey)
+	if err == sql.ErrNoRows {
+		return "", ErrNotExist
+	}
+	return
+}
+
+func (s *sqlStore) GetFileOwner(ctx context.Context, id string) (uid string, err error) {
+	err = s.db.QueryRowContext(ctx, "select user_id from file where id = ?", id).Scan(&uid)
+	if err == sql.ErrNoRows {
+		return "", ErrNotExist
+	}
+	return
+}
+
+func (s *sqlStore) GetMediaArticle(ctx context.Context, id string) (articleId string, old bool, err error) {
+	sqlStr := "select id, 0 from article where html_id=? or trans_html_id=? union all select article_id, 1 from article_revision where html_id=? order by 2 limit 1"
+	err = s.db.QueryRowContext(ctx, sqlStr, id, id, id).Scan(&articleId, &old)
+	if err == sql.ErrNoRows {
+		return "", false, ErrNotExist
+	}
+	return
+}
+
+func (s *sqlStore) DeleteFile(ctx context.Context, uid, id string) (deleted bool, err error) {
+	res, err := s.db.ExecContext(ctx, "delete from file where user_id = ? and id = ?", uid, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sqlStore) DeleteFiles(ctx context.Context, uid string, ids []string) error {
+	return deleteFiles(ctx, s.db, uid, ids)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func deleteFiles(ctx context.Context, db execer, uid string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, uid)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	sqlStr := "delete from file where user_id = ? and id in (" + placeholders(len(ids)) + ")"
+	_, err := db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// placeholders returns n comma separated `?`.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
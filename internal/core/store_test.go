@@ -0,0 +1,515 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) Store {
+	store, err := OpenStore("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Migrate(context.TODO(), LatestVersion); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestOpenStore(t *testing.T) {
+	if _, err := OpenStore("oracle", ""); err == nil {
+		t.Errorf("OpenStore(oracle) returned nil error")
+	}
+}
+
+func TestStoreArticles(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+
+	titles := []string{"Go+ 101", "Test A", "Go+ classfile", "Test B", "Test C", "yap"}
+	for i, title := range titles {
+		uid := "1"
+		if i%2 == 1 {
+			uid = "2"
+		}
+		article := &Article{ArticleEntry: ArticleEntry{Title: title, UId: uid, Status: StatusPublished}, Content: title}
+		id, err := store.InsertArticle(todo, article, int64(i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strconv.Itoa(i + 1); id != want {
+			t.Fatalf("InsertArticle(%s) returned id %s, expected: %s", title, id, want)
+		}
+	}
+
+	total, err := store.CountArticles(todo, &ArticleFilter{})
+	if err != nil || total != len(titles) {
+		t.Errorf("CountArticles() returned %d, %v, expected: %d", total, err, len(titles))
+	}
+	total, err = store.CountArticles(todo, &ArticleFilter{Search: "Test"})
+	if err != nil || total != 3 {
+		t.Errorf("CountArticles(Test) returned %d, %v, expected: 3", total, err)
+	}
+
+	if err = store.SetArticleStatus(todo, "1", StatusDraft); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.SetArticleStatus(todo, "100", StatusDraft); err != ErrNotExist {
+		t.Errorf("SetArticleStatus(100) returned err: %v, expected: %v", err, ErrNotExist)
+	}
+
+	published := []Status{StatusPublished}
+	tests := []struct {
+		filter      ArticleFilter
+		offset      int
+		limit       int
+		expectedLen int
+	}{
+		{ArticleFilter{}, 0, 5, 5},
+		{ArticleFilter{}, 5, 5, 1},
+		{ArticleFilter{}, 6, 5, 0},
+		{ArticleFilter{}, 0, 0, 6},
+		{ArticleFilter{Search: "Go+"}, 0, 5, 2},
+		{ArticleFilter{Search: "none"}, 0, 5, 0},
+		{ArticleFilter{UId: "2"}, 0, 0, 3},
+		{ArticleFilter{Statuses: published}, 0, 0, 5},
+		{ArticleFilter{Search: "Go+", Statuses: published}, 0, 5, 1},
+		{ArticleFilter{UId: "1", Statuses: []Status{StatusDraft, StatusArchived}}, 0, 5, 1},
+	}
+	for _, tt := range tests {
+		items, err := store.ListArticles(todo, &tt.filter, tt.offset, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != tt.expectedLen {
+			t.Errorf("ListArticles(%+v, %d, %d) returned %d items, expected: %d", tt.filter, tt.offset, tt.limit, len(items), tt.expectedLen)
+		}
+	}
+
+	article, htmlId, err := store.GetArticle(todo, "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "Go+ classfile" || htmlId != "3" {
+		t.Errorf("GetArticle(3) returned %s, %s, expected: Go+ classfile, 3", article.Title, htmlId)
+	}
+	if _, _, err = store.GetArticle(todo, "100"); err != ErrNotExist {
+		t.Errorf("GetArticle(100) returned err: %v, expected: %v", err, ErrNotExist)
+	}
+
+	if ok, err := store.IsAuthor(todo, "1", "3"); !ok || err != nil {
+		t.Errorf("IsAuthor(1, 3) returned %t, %v, expected: true", ok, err)
+	}
+	if ok, err := store.IsAuthor(todo, "2", "3"); ok || err != nil {
+		t.Errorf("IsAuthor(2, 3) returned %t, %v, expected: false", ok, err)
+	}
+}
+
+func TestStoreUpdateArticle(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+
+	article := &Article{ArticleEntry: ArticleEntry{Title: "Test", UId: "1"}, Content: "md"}
+	id, err := store.InsertArticle(todo, article, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	article.ID = id
+	article.Title = "Test Updated"
+	article.Content = "md updated"
+	if err = store.UpdateArticle(todo, "1", article, 2); err != nil {
+		t.Fatal(err)
+	}
+	article.Content = "translated"
+	if err = store.UpdateTransArticle(todo, article, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	got, htmlId, err := store.GetArticle(todo, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Test Updated" || got.Content != "md updated" || htmlId != "2" {
+		t.Errorf("GetArticle(%s) returned %+v, html %s", id, got, htmlId)
+	}
+	transId, err := store.GetTransHtmlId(todo, id)
+	if err != nil || transId != "3" {
+		t.Errorf("GetTransHtmlId(%s) returned %s, %v, expected: 3", id, transId, err)
+	}
+
+	if err = store.UpdateContent(todo, "1", id, "content", 4); err != nil {
+		t.Fatal(err)
+	}
+	got, htmlId, _ = store.GetArticle(todo, id)
+	if got.Content != "content" || htmlId != "4" {
+		t.Errorf("GetArticle(%s) returned content %s, html %s, expected: content, 4", id, got.Content, htmlId)
+	}
+}
+
+func TestStoreDeleteArticle(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+
+	htmlId, err := store.SaveFile(todo, "1", &File{FileKey: "key", Format: "text/html", Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := store.InsertArticle(todo, &Article{ArticleEntry: ArticleEntry{Title: "Test", UId: "1"}}, htmlId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.DeleteArticle(todo, "2", id); err != ErrNotExist {
+		t.Errorf("DeleteArticle(2, %s) returned err: %v, expected: %v", id, err, ErrNotExist)
+	}
+	if err = store.DeleteArticle(todo, "1", id); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = store.GetArticle(todo, id); err != ErrNotExist {
+		t.Errorf("GetArticle(%s) returned err: %v, expected: %v", id, err, ErrNotExist)
+	}
+	if _, err = store.GetFileKey(todo, "1"); err != ErrNotExist {
+		t.Errorf("GetFileKey(1) returned err: %v, expected: %v", err, ErrNotExist)
+	}
+	if revs, err := store.ListRevisions(todo, id); err != nil || len(revs) != 0 {
+		t.Errorf("ListRevisions(%s) returned %d revisions, %v, expected: 0", id, len(revs), err)
+	}
+}
+
+func TestStoreRevisions(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+
+	article := &Article{ArticleEntry: ArticleEntry{Title: "Test", UId: "1", Tags: "a"}, Content: "v1"}
+	id, err := store.InsertArticle(todo, article, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// revisions are credited to their editors, not the author
+	article.ID = id
+	article.Content = "v2"
+	if err = store.UpdateArticle(todo, "2", article, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.UpdateContent(todo, "3", id, "v3", 3); err != nil {
+		t.Fatal(err)
+	}
+	// translations are not revisions
+	if err = store.UpdateTransArticle(todo, article, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	revs, err := store.ListRevisions(todo, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 3 {
+		t.Fatalf("ListRevisions(%s) returned %d revisions, expected: 3", id, len(revs))
+	}
+	for i, want := range []string{"v3", "v2", "v1"} {
+		rev := revs[i]
+		if rev.Content != want || rev.HtmlId != strconv.Itoa(3-i) || rev.ArticleID != id || rev.UId != strconv.Itoa(3-i) || rev.Tags != "a" {
+			t.Errorf("revision %d is %+v, expected content: %s", i, rev, want)
+		}
+	}
+
+	rev, err := store.GetRevision(todo, revs[1].ID)
+	if err != nil || rev.Content != "v2" {
+		t.Errorf("GetRevision(%s) returned %+v, %v, expected content: v2", revs[1].ID, rev, err)
+	}
+	if _, err = store.GetRevision(todo, "100"); err != ErrNotExist {
+		t.Errorf("GetRevision(100) returned err: %v, expected: %v", err, ErrNotExist)
+	}
+}
+
+func TestStoreFiles(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+
+	var ids []string
+	for _, key := range []string{"a", "b", "c"} {
+		id, err := store.SaveFile(todo, "1", &File{FileKey: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	if key, err := store.GetFileKey(todo, ids[1]); err != nil || key != "b" {
+		t.Errorf("GetFileKey(%s) returned %s, %v, expected: b", ids[1], key, err)
+	}
+	if deleted, err := store.DeleteFile(todo, "2", ids[0]); deleted || err != nil {
+		t.Errorf("DeleteFile(2, %s) returned %t, %v, expected: false", ids[0], deleted, err)
+	}
+	if deleted, err := store.DeleteFile(todo, "1", ids[0]); !deleted || err != nil {
+		t.Errorf("DeleteFile(1, %s) returned %t, %v, expected: true", ids[0], deleted, err)
+	}
+	if err := store.DeleteFiles(todo, "1", ids[1:]); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if _, err := store.GetFileKey(todo, id); err != ErrNotExist {
+			t.Errorf("GetFileKey(%s) returned err: %v, expected: %v", id, err, ErrNotExist)
+		}
+	}
+}
+
+func TestStoreSchedule(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+
+	publishAt := time.Now().Add(time.Hour)
+	article := &Article{ArticleEntry: ArticleEntry{Title: "Test", UId: "1", Status: StatusScheduled, PublishAt: publishAt}}
+	id, err := store.InsertArticle(todo, article, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := store.GetArticle(todo, id)
+	if err != nil || !got.PublishAt.Equal(publishAt) {
+		t.Errorf("GetArticle(%s) returned publish at %v, %v, expected: %v", id, got.PublishAt, err, publishAt)
+	}
+
+	tests := []struct {
+		viewer      string
+		expectedLen int
+	}{
+		{"", 0},
+		{"2", 0},
+		{"1", 1},
+	}
+	for _, tt := range tests {
+		filter := &ArticleFilter{Statuses: []Status{StatusPublished}, Viewer: tt.viewer}
+		items, err := store.ListArticles(todo, filter, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != tt.expectedLen {
+			t.Errorf("ListArticles(viewer %s) returned %d items, expected: %d", tt.viewer, len(items), tt.expectedLen)
+		}
+	}
+
+	if n, err := store.PublishDue(todo, time.Now()); err != nil || n != 0 {
+		t.Errorf("PublishDue(now) returned %d, %v, expected: 0", n, err)
+	}
+	if n, err := store.PublishDue(todo, publishAt.Add(time.Second)); err != nil || n != 1 {
+		t.Errorf("PublishDue(publishAt) returned %d, %v, expected: 1", n, err)
+	}
+	if got, _, _ = store.GetArticle(todo, id); got.Status != StatusPublished {
+		t.Errorf("GetArticle(%s) returned status %v, expected: %v", id, got.Status, StatusPublished)
+	}
+	// the article is listed at when it went live, not when it was drafted
+	if !got.Ctime.Truncate(time.Second).Equal(publishAt.UTC().Truncate(time.Second)) {
+		t.Errorf("GetArticle(%s) returned ctime %v after PublishDue, expected: %v", id, got.Ctime, publishAt)
+	}
+
+	if err = store.ScheduleArticle(todo, "100", publishAt); err != ErrNotExist {
+		t.Errorf("ScheduleArticle(100) returned err: %v, expected: %v", err, ErrNotExist)
+	}
+}
+
+func TestStorePublishCtime(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+	db := store.(*sqlStore).db
+
+	weekAgo := time.Now().UTC().Add(-7 * 24 * time.Hour).Truncate(time.Second)
+	article := &Article{ArticleEntry: ArticleEntry{Title: "Test", UId: "1", Status: StatusDraft}}
+	id, err := store.InsertArticle(todo, article, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctime := func() time.Time {
+		got, _, err := store.GetArticle(todo, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.Ctime
+	}
+	backdate := func() {
+		if _, err := db.ExecContext(todo, "update article set ctime=? where id=?", weekAgo, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// publishing a draft dates it now
+	backdate()
+	if err = store.SetArticleStatus(todo, id, StatusPublished); err != nil {
+		t.Fatal(err)
+	}
+	if c := ctime(); time.Since(c) > time.Minute {
+		t.Errorf("SetArticleStatus(published) of a draft left ctime %v, expected now", c)
+	}
+
+	// articles which were live keep their date
+	backdate()
+	for _, status := range []Status{StatusUnlisted, StatusPublished} {
+		if err = store.SetArticleStatus(todo, id, status); err != nil {
+			t.Fatal(err)
+		}
+		if c := ctime(); !c.Equal(weekAgo) {
+			t.Errorf("SetArticleStatus(%v) changed ctime to %v, expected: %v", status, c, weekAgo)
+		}
+	}
+
+	// drafts being edited are dated when they are saved as published
+	article.ID = id
+	for _, status := range []Status{StatusDraft, StatusPublished} {
+		backdate()
+		if err = store.SetArticleStatus(todo, id, StatusDraft); err != nil {
+			t.Fatal(err)
+		}
+		article.Status = status
+		if err = store.UpdateArticle(todo, "1", article, 0); err != nil {
+			t.Fatal(err)
+		}
+		if c := ctime(); status == StatusDraft && !c.Equal(weekAgo) || status == StatusPublished && time.Since(c) > time.Minute {
+			t.Errorf("UpdateArticle(%v) of a draft left ctime %v", status, c)
+		}
+	}
+}
+
+func TestStoreTags(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+
+	var ids []string
+	for _, tags := range []string{"Go+,yap", "yap", "Go+"} {
+		article := &Article{ArticleEntry: ArticleEntry{Title: "Test", UId: "1", Tags: tags, Status: StatusPublished}}
+		id, err := store.InsertArticle(todo, article, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		article.ID = id
+		ids = append(ids, id)
+	}
+	// retag the last one
+	article := &Article{ArticleEntry: ArticleEntry{ID: ids[2], Title: "Test", Tags: "classfile", Status: StatusPublished}}
+	if err := store.UpdateArticle(todo, "1", article, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tag         string
+		expectedLen int
+	}{
+		{"Go+", 1},
+		{"go+", 1},
+		{"yap", 2},
+		{"classfile", 1},
+		{"none", 0},
+	}
+	for _, tt := range tests {
+		items, err := store.ListArticles(todo, &ArticleFilter{Tag: tt.tag}, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != tt.expectedLen {
+			t.Errorf("ListArticles(tag %s) returned %d items, expected: %d", tt.tag, len(items), tt.expectedLen)
+		}
+	}
+
+	if err := store.DeleteArticle(todo, "1", ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := store.ListTags(todo, []Status{StatusPublished})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0].Name != "classfile" || tags[1].Name != "yap" || tags[1].Count != 1 {
+		t.Errorf("ListTags() returned %v, expected: [classfile 1] [yap 1]", tags)
+	}
+}
+
+func TestStoreSearch(t *testing.T) {
+	todo := context.TODO()
+	store := newTestStore(t)
+
+	articles := []*Article{
+		{ArticleEntry: ArticleEntry{Title: "Classfile 入门", Tags: "Go+"}, Content: "本文介绍 yap 框架。"},
+		{ArticleEntry: ArticleEntry{Title: "Web 开发", Abstract: "yap"}, Content: "# 用 Go+ 写网站\n\n使用 **classfile** 和 yap 框架开发 web 应用。"},
+		{ArticleEntry: ArticleEntry{Title: "Draft"}, Content: "yap classfile"},
+	}
+	for i, article := range articles {
+		article.UId = "1"
+		article.Status = StatusPublished
+		if i == 2 {
+			article.Status = StatusDraft
+		}
+		if _, err := store.InsertArticle(todo, article, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	published := []Status{StatusPublished}
+	tests := []struct {
+		query       string
+		expectedIds []string
+	}{
+		{"classfile", []string{"1", "2"}}, // title first
+		{"YAP", []string{"2", "1"}},       // abstract first
+		{"框架", []string{"2", "1"}},        // same score, newest first
+		{"yap 框架开发", []string{"2"}},
+		{"网站", []string{"2"}},
+		{"go", []string{"1", "2"}},
+		{"none", nil},
+		{"+", nil},
+	}
+	for _, tt := range tests {
+		filter := &ArticleFilter{Search: tt.query, Statuses: published}
+		items, err := store.ListArticles(todo, filter, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.expectedIds) {
+			t.Errorf("ListArticles(search %q) returned %v, expected: %v", tt.query, ids, tt.expectedIds)
+		}
+		total, err := store.CountArticles(todo, filter)
+		if err != nil || total != len(tt.expectedIds) {
+			t.Errorf("CountArticles(search %q) returned %d, %v, expected: %d", tt.query, total, err, len(tt.expectedIds))
+		}
+	}
+
+	items, _ := store.ListArticles(todo, &ArticleFilter{Search: "网站"}, 0, 10)
+	if len(items) != 1 || !strings.Contains(items[0].Snippet, "<mark>网站</mark>") || strings.Contains(items[0].Snippet, "#") {
+		t.Errorf("ListArticles(search 网站) returned snippet %v", items)
+	}
+
+	// the index follows updates and deletes
+	articles[0].ID = "1"
+	articles[0].Title = "Updated"
+	articles[0].Content = "nothing"
+	articles[0].Tags = ""
+	if err := store.UpdateArticle(todo, "1", articles[0], 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteArticle(todo, "1", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if total, _ := store.CountArticles(todo, &ArticleFilter{Search: "classfile"}); total != 1 {
+		t.Errorf("CountArticles(search classfile) returned %d after update, expected: 1", total)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goplus/community/internal/feed"
+	"github.com/goplus/community/markdown"
+)
+
+// feedSize is the number of articles in syndication feeds.
+const feedSize = 20
+
+// maxCachedFeeds bounds the number of feeds kept by feedCache.
+const maxCachedFeeds = 1000
+
+// feedCache keeps the feeds built by SyndicationFeed, so that the articles of
+// a feed are read and rendered again only when its items change.
+type feedCache struct {
+	mu    sync.Mutex
+	feeds map[string]*cachedFeed // by author, "" for the latest articles
+}
+
+type cachedFeed struct {
+	version string // of the items, see feedVersion
+	feed    *feed.Feed
+}
+
+func newFeedCache() *feedCache {
+	return &feedCache{feeds: make(map[string]*cachedFeed)}
+}
+
+// get returns the feed of author if it's of version, or nil.
+func (c *feedCache) get(author, version string) *feed.Feed {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached := c.feeds[author]; cached != nil && cached.version == version {
+		return cached.feed
+	}
+	return nil
+}
+
+func (c *feedCache) put(author, version string, f *feed.Feed) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.feeds[author]; !ok && len(c.feeds) >= maxCachedFeeds {
+		c.feeds = make(map[string]*cachedFeed)
+	}
+	c.feeds[author] = &cachedFeed{version, f}
+}
+
+// feedVersion returns the version of the feed of items, which changes when
+// the items are listed, edited or published.
+func feedVersion(items []*ArticleEntry) string {
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(item.ID + ":" + strconv.FormatInt(item.Mtime.UnixNano(), 36) + ":" + strconv.FormatInt(item.Ctime.UnixNano(), 36) + ":" + item.User.Name + ",")
+	}
+	return b.String()
+}
+
+// SyndicationFeed returns the feed of the latest listed articles, or of
+// those written by author if not empty. Links are relative to the site if
+// SiteURL isn't configured. The feed is built again only when its items
+// change, and must not be modified.
+func (p *Community) SyndicationFeed(ctx context.Context, author string) (f *feed.Feed, err error) {
+	f = &feed.Feed{
+		Title:       "Go+ Community",
+		Link:        p.siteURL + "/",
+		Description: "The latest articles of Go+ Community",
+	}
+	var items []*ArticleEntry
+	if author == "" {
+		items, _, _, err = p.ListArticle(ctx, MarkBegin, feedSize, "", "")
+	} else {
+		users, _ := p.users.get([]string{author})
+		user := users[author]
+		if user == nil {
+			return nil, ErrNotExist
+		}
+		link := p.siteURL + "/user/" + url.PathEscape(author)
+		f.Title = user.Name + " - " + f.Title
+		f.Link = link
+		f.Description = "The latest articles of " + user.Name + " on Go+ Community"
+		f.Author = &feed.Person{Name: user.Name, URI: link}
+		items, _, _, err = p.GetArticlesByUid(ctx, author, "", MarkBegin, feedSize)
+	}
+	if err == io.EOF {
+		return f, nil
+	} else if err != nil {
+		return nil, err
+	}
+	version := feedVersion(items)
+	if cached := p.feeds.get(author, version); cached != nil {
+		return cached, nil
+	}
+	for _, item := range items {
+		published := item.Ctime
+		if !item.PublishAt.IsZero() {
+			published = item.PublishAt
+		}
+		f.Items = append(f.Items, &feed.Item{
+			Title:      item.Title,
+			Link:       p.siteURL + "/p/" + item.ID,
+			Summary:    item.Abstract,
+			Content:    p.feedContent(ctx, item.ID),
+			Image:      item.Cover,
+			Author:     feed.Person{Name: item.User.Name, URI: p.siteURL + "/user/" + url.PathEscape(item.UId)},
+			Categories: ParseTags(item.Tags),
+			Published:  published,
+			Updated:    item.Mtime,
+		})
+	}
+	p.feeds.put(author, version, f)
+	return f, nil
+}
+
+// feedContent returns the sanitized html of article id, or "" if it can't be
+// rendered.
+func (p *Community) feedContent(ctx context.Context, id string) string {
+	article, _, err := p.store.GetArticle(ctx, id)
+	if err != nil {
+		p.xLog.Warn("feed content error:", err)
+		return ""
+	}
+	html, err := markdown.Render(article.Content)
+	if err != nil {
+		p.xLog.Warn("feed content error:", err)
+		return ""
+	}
+	return markdown.Sanitize(html)
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSyndicationFeed(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	community.siteURL = "https://community.example.com"
+
+	for _, uid := range []string{"1", "2", "1"} {
+		id := putTestArticle(t, community, uid, "Test")
+		community.SetArticleStatus(todo, uid, id, StatusPublished)
+	}
+	putTestArticle(t, community, "1", "Draft")
+
+	f, err := community.SyndicationFeed(todo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Items) != 3 || f.Author != nil {
+		t.Fatalf("SyndicationFeed() returned %d items, author %v, expected: 3, nil", len(f.Items), f.Author)
+	}
+	item := f.Items[0]
+	if !strings.HasPrefix(item.Link, "https://community.example.com/p/") || item.Content != "<p>This is a test article.</p>\n" ||
+		item.Author.Name != "user1" || item.Updated.IsZero() || len(item.Categories) != 1 {
+		t.Errorf("SyndicationFeed() returned item %+v", item)
+	}
+
+	// the feed is built again only when its items change
+	if cached, _ := community.SyndicationFeed(todo, ""); cached != f {
+		t.Errorf("SyndicationFeed() built the feed again for the same items")
+	}
+	article, _, _ := community.store.GetArticle(todo, item.Link[strings.LastIndexByte(item.Link, '/')+1:])
+	article.Content = "This is an edit."
+	if _, err = community.PutArticle(todo, article.UId, "", article); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = community.SyndicationFeed(todo, ""); err != nil || f.Items[0].Content != "<p>This is an edit.</p>\n" {
+		t.Errorf("SyndicationFeed() after an edit returned item %+v, %v", f.Items[0], err)
+	}
+
+	f, err = community.SyndicationFeed(todo, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Items) != 2 || f.Author == nil || f.Author.URI != "https://community.example.com/user/1" {
+		t.Errorf("SyndicationFeed(1) returned %d items, author %v, expected: 2, user1", len(f.Items), f.Author)
+	}
+	if f, err = community.SyndicationFeed(todo, "3"); err != nil || len(f.Items) != 0 {
+		t.Errorf("SyndicationFeed(3) returned %v, %v, expected: an empty feed", f, err)
+	}
+	if _, err = community.SyndicationFeed(todo, "404"); err != ErrNotExist {
+		t.Errorf("SyndicationFeed(404) returned err: %v, expected: %v", err, ErrNotExist)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTagLen is the maximal length of a tag in runes.
+const maxTagLen = 64
+
+// maxTagsLen is the maximal length in runes of the tags of an article joined
+// by commas, as the tags columns are varchar(255).
+const maxTagsLen = 255
+
+// Tag is a tag of articles.
+type Tag struct {
+	Name  string
+	Count int // number of listed articles with the tag
+}
+
+// ParseTags splits tags separated by commas or semicolons. Tags are trimmed
+// and truncated to 64 runes, empty and duplicated (ignoring case) ones are
+// dropped, and so are those beyond 255 runes of tags joined by commas.
+func ParseTags(tags string) (names []string) {
+	seen := make(map[string]bool)
+	joined := -1 // the length of names joined by commas
+	fields := strings.FieldsFunc(tags, func(r rune) bool {
+		return r == ',' || r == ';' || r == '，' || r == '；'
+	})
+	for _, name := range fields {
+		name = strings.Join(strings.Fields(name), " ")
+		n := utf8.RuneCountInString(name)
+		if n > maxTagLen {
+			name, n = string([]rune(name)[:maxTagLen]), maxTagLen
+		}
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		if joined+1+n > maxTagsLen {
+			break
+		}
+		seen[key] = true
+		joined += 1 + n
+		names = append(names, name)
+	}
+	return
+}
+
+// ListTags lists the tags of listed articles, the most used first.
+func (p *Community) ListTags(ctx context.Context) (tags []*Tag, err error) {
+	return p.store.ListTags(ctx, listedStatuses)
+}
+
+// ArticlesByTag lists articles with tag from a position. The scheduled
+// articles of viewer are listed too.
+func (p *Community) ArticlesByTag(ctx context.Context, tag, from string, limit int, viewer string) (items []*ArticleEntry, prev, next string, err error) {
+	filter := &ArticleFilter{Tag: tag, Statuses: listedStatuses, Viewer: viewer}
+	return p.listArticles(ctx, filter, from, limit)
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		tags     string
+		expected []string
+	}{
+		{"", nil},
+		{" , ;", nil},
+		{"Go+", []string{"Go+"}},
+		{"Go+, yap ,classfile", []string{"Go+", "yap", "classfile"}},
+		{"Go+;go+,GO+", []string{"Go+"}},
+		{"教程，Go+；  web   framework ", []string{"教程", "Go+", "web framework"}},
+		{strings.Repeat("a", 70), []string{strings.Repeat("a", 64)}},
+		// 3 tags of 64 runes and one of 60 fill the 255 runes of the column, and
+		// the tags beyond them are dropped
+		{strings.Repeat("a", 64) + "," + strings.Repeat("b", 64) + "," + strings.Repeat("c", 64) + "," + strings.Repeat("d", 61) + ",e",
+			[]string{strings.Repeat("a", 64), strings.Repeat("b", 64), strings.Repeat("c", 64)}},
+		{strings.Repeat("a", 64) + "," + strings.Repeat("b", 64) + "," + strings.Repeat("c", 64) + "," + strings.Repeat("d", 60) + ",e",
+			[]string{strings.Repeat("a", 64), strings.Repeat("b", 64), strings.Repeat("c", 64), strings.Repeat("d", 60)}},
+	}
+	for _, tt := range tests {
+		if got := ParseTags(tt.tags); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ParseTags(%q) = %q, expected: %q", tt.tags, got, tt.expected)
+		}
+	}
+}
+
+func TestListTags(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+
+	for _, tags := range []string{"Go+, yap", "go+,Go+", "draft"} {
+		article := &Article{ArticleEntry: ArticleEntry{Title: "Test", Tags: tags}}
+		if tags != "draft" {
+			article.Status = StatusPublished
+		}
+		id, err := community.PutArticle(todo, "1", "", article)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _, _ := community.store.GetArticle(todo, id); got.Tags != strings.Join(ParseTags(tags), ",") {
+			t.Errorf("PutArticle(%q) saved tags %q", tags, got.Tags)
+		}
+	}
+
+	tags, err := community.ListTags(todo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Tag{{"Go+", 2}, {"yap", 1}}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("ListTags() returned %v, expected: %v", tags, want)
+	}
+
+	items, _, next, err := community.ArticlesByTag(todo, "draft", MarkBegin, 10, "")
+	if err != io.EOF || len(items) != 0 || next != MarkEnd {
+		t.Errorf("ArticlesByTag(draft) returned %d items, %s, %v, expected: 0, %s, %v", len(items), next, err, MarkEnd, io.EOF)
+	}
+}
@@ -1,10 +1,6 @@
 package core
 
 import (
-	"github.com/goplus/yap"
-	"net/http"
-	"os"
-
 	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
 )
 
@@ -14,26 +10,16 @@ type User struct {
 	Name     string
 	Password string
 	Avatar   string
+	Email    string `json:"-"` // private, for notifications
+	Role     Role   `json:"-"` // role from casdoor, see Community.Role
 }
 
 type UserClaim casdoorsdk.Claims
 type UserInfo casdoorsdk.User
 
-// Init casdoor parser
-func CasdoorConfigInit() {
-	endPoint := os.Getenv("GOP_CASDOOR_ENDPOINT")
-	clientID := os.Getenv("GOP_CASDOOR_CLIENTID")
-	clientSecret := os.Getenv("GOP_CASDOOR_CLIENTSECRET")
-	certificate := os.Getenv("GOP_CASDOOR_CERTIFICATE")
-	organizationName := os.Getenv("GOP_CASDOOR_ORGANIZATIONNAME")
-	applicationName := os.Getenv("GOP_CASDOOR_APPLICATONNAME")
-
-	casdoorsdk.InitConfig(endPoint, clientID, clientSecret, certificate, organizationName, applicationName)
-}
-
 // GetUser return author by token
 func (p *Community) GetUser(token string) (user *User, err error) {
-	claim, err := casdoorsdk.ParseJwtToken(token)
+	claim, err := p.idp.ParseJwtToken(token)
 	if err != nil {
 		p.xLog.Error(err)
 		return &User{}, ErrNotExist
@@ -48,7 +34,7 @@ func (p *Community) GetUser(token string) (user *User, err error) {
 
 // ParseJwtToken return user id by token
 func (p *Community) ParseJwtToken(token string) (userId string, err error) {
-	claim, err := casdoorsdk.ParseJwtToken(token)
+	claim, err := p.idp.ParseJwtToken(token)
 	if err != nil {
 		p.xLog.Error(err)
 		return "", ErrNotExist
@@ -58,7 +44,7 @@ func (p *Community) ParseJwtToken(token string) (userId string, err error) {
 
 // GetUserClaim get user（full） by token
 func (p *Community) GetUserClaim(uid string) (claim *casdoorsdk.User, err error) {
-	claim, err = casdoorsdk.GetUserByUserId(uid)
+	claim, err = p.idp.GetUserByUserId(uid)
 	if err != nil {
 		p.xLog.Error(err)
 		return &casdoorsdk.User{}, ErrNotExist
@@ -68,57 +54,29 @@ func (p *Community) GetUserClaim(uid string) (claim *casdoorsdk.User, err error)
 
 // GetUserById get user by uid
 func (p *Community) GetUserById(uid string) (user *User, err error) {
-	claim, err := casdoorsdk.GetUserByUserId(uid)
+	users, err := p.users.get([]string{uid})
 	if err != nil {
 		p.xLog.Error(err)
 		return &User{}, ErrNotExist
 	}
-	user = &User{
-		Name:   claim.Name,
-		Avatar: claim.Avatar,
-		Id:     claim.Id,
-	}
-	return
+	return users[uid], nil
 }
 
-// UpdateUserById update user by uid
-func (p *Community) UpdateUserById(uid string, user *casdoorsdk.User) (res bool, err error) {
-	res, err = casdoorsdk.UpdateUserById(uid, user)
-	return
+// GetUsersByIds gets users by uids, which are looked up once per cache ttl.
+// Users failed to look up are missing in the result, and err is the first
+// failure.
+func (p *Community) GetUsersByIds(uids []string) (users map[string]*User, err error) {
+	return p.users.get(uids)
 }
 
-func SetToken(ctx *yap.Context) (err error) {
-	code := ctx.URL.Query().Get("code")
-	state := ctx.URL.Query().Get("state")
-	token, err := casdoorsdk.GetOAuthToken(code, state)
-	if err != nil {
-		return err
-	}
-	cookie := http.Cookie{
-		Name:   "token",
-		Value:  token.AccessToken,
-		Path:   "/",
-		MaxAge: 3600,
-	}
-	http.SetCookie(ctx.ResponseWriter, &cookie)
-	return err
-}
-
-func GetToken(ctx *yap.Context) (token *http.Cookie, err error) {
-	tokenCookie, err := ctx.Request.Cookie("token")
-	if err != nil {
-		return tokenCookie, err
-	}
-	return tokenCookie, err
+// placeholderUser stands for user uid when the user can't be looked up.
+func placeholderUser(uid string) User {
+	return User{Id: uid, Name: "Unknown user"}
 }
 
-func RemoveToken(ctx *yap.Context) (err error) {
-	tokenCookie, err := ctx.Request.Cookie("token")
-	if err != nil {
-		return err
-	}
-	// Delete token
-	tokenCookie.MaxAge = -1
-	http.SetCookie(ctx.ResponseWriter, tokenCookie)
-	return err
+// UpdateUserById update user by uid
+func (p *Community) UpdateUserById(uid string, user *casdoorsdk.User) (res bool, err error) {
+	res, err = p.idp.UpdateUserById(uid, user)
+	p.users.invalidate(uid)
+	return
 }
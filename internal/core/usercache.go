@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// missTTL caps how long a userCache remembers users it failed to fetch, so
+// unknown authors aren't looked up on every listing, while users failed to
+// fetch for an outage are soon retried.
+const missTTL = 30 * time.Second
+
+// userCache caches the user profiles fetched from the identity provider, so
+// listings look each author up once per ttl instead of once per article.
+type userCache struct {
+	fetch func(uids []string) (map[string]*User, error)
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]userEntry
+}
+
+// userEntry is a cached user, or the error of failing to fetch it if user is
+// nil.
+type userEntry struct {
+	user    *User
+	err     error
+	expires time.Time
+}
+
+func newUserCache(fetch func(uids []string) (map[string]*User, error), ttl time.Duration) *userCache {
+	return &userCache{fetch: fetch, ttl: ttl, entries: make(map[string]userEntry)}
+}
+
+// fetchUser returns the fetch of a userCache getting the profiles of users
+// from the identity provider in one lookup.
+func fetchUser(idp IdentityProvider) func(uids []string) (map[string]*User, error) {
+	return func(uids []string) (map[string]*User, error) {
+		claims, err := idp.GetUsersByUserIds(uids)
+		if err != nil {
+			return nil, err
+		}
+		users := make(map[string]*User, len(claims))
+		for _, claim := range claims {
+			users[claim.Id] = &User{Name: claim.Name, Avatar: claim.Avatar, Id: claim.Id, Email: claim.Email, Role: casdoorRole(claim)}
+		}
+		return users, nil
+	}
+}
+
+// get returns the users of uids, fetching the missing and expired ones in one
+// batch. Users failed to fetch are left out, and err is the first of their
+// errors: ErrNotExist for users there are none of.
+func (c *userCache) get(uids []string) (users map[string]*User, err error) {
+	users = make(map[string]*User, len(uids))
+	var misses []string
+	seen := make(map[string]bool, len(uids))
+	now := time.Now()
+	c.mu.Lock()
+	for _, uid := range uids {
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		if e, ok := c.entries[uid]; ok && now.Before(e.expires) {
+			if e.user != nil {
+				users[uid] = e.user
+			} else if err == nil {
+				err = e.err
+			}
+			continue
+		}
+		misses = append(misses, uid)
+	}
+	c.mu.Unlock()
+	if len(misses) == 0 {
+		return
+	}
+
+	fetched, ferr := c.fetch(misses)
+	now = time.Now()
+	missTTL := missTTL
+	if c.ttl < missTTL {
+		missTTL = c.ttl
+	}
+	c.mu.Lock()
+	for uid, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, uid)
+		}
+	}
+	for _, uid := range misses {
+		if user, ok := fetched[uid]; ok && ferr == nil {
+			users[uid] = user
+			c.entries[uid] = userEntry{user: user, expires: now.Add(c.ttl)}
+			continue
+		}
+		e := ferr
+		if e == nil {
+			e = ErrNotExist
+		}
+		if err == nil {
+			err = e
+		}
+		c.entries[uid] = userEntry{err: e, expires: now.Add(missTTL)}
+	}
+	c.mu.Unlock()
+	return
+}
+
+// invalidate drops the cached profile of user uid.
+func (c *userCache) invalidate(uid string) {
+	c.mu.Lock()
+	delete(c.entries, uid)
+	c.mu.Unlock()
+}
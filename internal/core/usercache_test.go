@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUserCache(t *testing.T) {
+	var batches [][]string
+	cache := newUserCache(func(uids []string) (map[string]*User, error) {
+		batches = append(batches, uids)
+		return fetchTestUsers(uids)
+	}, time.Minute)
+
+	users, err := cache.get([]string{"1", "2", "1", "404"})
+	if err != ErrNotExist {
+		t.Errorf("get() returned err: %v, expected: %v", err, ErrNotExist)
+	}
+	if len(users) != 2 || users["1"].Name != "user1" || users["2"].Name != "user2" {
+		t.Errorf("get() returned %v, expected users 1 and 2", users)
+	}
+	want := [][]string{{"1", "2", "404"}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("get() fetched %v, expected: %v", batches, want)
+	}
+
+	// cached users aren't fetched again, nor are missing ones for a while
+	if _, err := cache.get([]string{"1", "2", "404"}); err != ErrNotExist {
+		t.Errorf("get() again returned err: %v, expected: %v", err, ErrNotExist)
+	}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("get() again fetched %v, expected: %v", batches, want)
+	}
+
+	cache.invalidate("1")
+	cache.get([]string{"1", "2"})
+	want = append(want, []string{"1"})
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("get() after invalidate fetched %v, expected: %v", batches, want)
+	}
+
+	// expired users are fetched again, and pruned once others are fetched
+	cache.ttl = 0
+	cache.invalidate("2")
+	cache.get([]string{"2"})
+	cache.get([]string{"2"})
+	want = append(want, []string{"2"}, []string{"2"})
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("get() after expiry fetched %v, expected: %v", batches, want)
+	}
+	cache.get([]string{"3"})
+	if _, ok := cache.entries["2"]; ok {
+		t.Errorf("get() kept the expired entry of user 2")
+	}
+
+	// failures are remembered as briefly as misses
+	cache = newUserCache(func(uids []string) (map[string]*User, error) {
+		return nil, errors.New("casdoor is down")
+	}, time.Minute)
+	cache.get([]string{"1"})
+	if e := cache.entries["1"]; e.err == nil || time.Until(e.expires) > missTTL {
+		t.Errorf("get() cached failure %v, expected to expire within %v", e, missTTL)
+	}
+	if _, err := cache.get([]string{"1"}); err == nil {
+		t.Errorf("get() of a cached failure returned nil error")
+	}
+}
+
+func TestAddAuthors(t *testing.T) {
+	todo := context.TODO()
+	community := newTestCommunity(t)
+	// user 404 may still write, though its profile is gone
+	community.store.SetRole(todo, "404", RoleAuthor)
+
+	for _, uid := range []string{"1", "404"} {
+		article := &Article{ArticleEntry: ArticleEntry{Title: "Test", Status: StatusPublished}}
+		if _, err := community.PutArticle(todo, uid, "", article); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, _, _, err := community.ListArticle(todo, MarkBegin, 10, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []User
+	for _, item := range items {
+		got = append(got, item.User)
+	}
+	want := []User{placeholderUser("404"), {Id: "1", Name: "user1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListArticle() returned authors %v, expected: %v", got, want)
+	}
+
+	article, err := community.Article(todo, items[0].ID)
+	if err != nil || article.User != want[0] {
+		t.Errorf("Article() returned author %v, %v, expected: %v", article.User, err, want[0])
+	}
+}
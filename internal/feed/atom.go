@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Updated string       `xml:"updated"`
+	Links   []atomLink   `xml:"link"`
+	Author  *atomPerson  `xml:"author,omitempty"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func encodeAtom(w io.Writer, f *Feed, self string) error {
+	feed := &atomFeed{
+		ID:      self,
+		Title:   f.Title,
+		Updated: atomTime(f.Updated()),
+		Links: []atomLink{
+			{Rel: "alternate", Href: f.Link, Type: "text/html"},
+			{Rel: "self", Href: self, Type: "application/atom+xml"},
+		},
+	}
+	if f.Author != nil {
+		feed.Author = &atomPerson{f.Author.Name, f.Author.URI}
+	}
+	for _, item := range f.Items {
+		entry := &atomEntry{
+			ID:        item.ID,
+			Title:     item.Title,
+			Links:     []atomLink{{Rel: "alternate", Href: item.Link, Type: "text/html"}},
+			Published: atomTime(item.Published),
+			Updated:   atomTime(item.Updated),
+			Author:    atomPerson{item.Author.Name, item.Author.URI},
+		}
+		if item.Image != "" {
+			entry.Links = append(entry.Links, atomLink{Rel: "enclosure", Href: item.Image})
+		}
+		for _, c := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{c})
+		}
+		if item.Summary != "" {
+			entry.Summary = &atomText{"text", item.Summary}
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{"html", item.Content}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return encodeXML(w, feed)
+}
+
+func encodeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package feed encodes syndication feeds as Atom, RSS 2.0 and JSON Feed.
+package feed
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Person is the author of a feed or an item.
+type Person struct {
+	Name string
+	URI  string // profile page
+}
+
+// Item is an entry of a feed.
+type Item struct {
+	ID         string // unique and permanent, the Link if empty
+	Title      string
+	Link       string
+	Summary    string // plain text
+	Content    string // html
+	Image      string // url of the cover image
+	Author     Person
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+}
+
+// Feed is a list of items, newest first. Links may be relative to the site
+// serving the feed.
+type Feed struct {
+	Title       string
+	Link        string // home page
+	Description string
+	Author      *Person // nil unless the feed has a single author
+	Items       []*Item
+}
+
+// Format is the encoding of a feed.
+type Format int
+
+const (
+	Atom Format = iota
+	RSS
+	JSON
+)
+
+// ContentType returns the media type of format.
+func (f Format) ContentType() string {
+	switch f {
+	case RSS:
+		return "application/rss+xml; charset=utf-8"
+	case JSON:
+		return "application/feed+json; charset=utf-8"
+	}
+	return "application/atom+xml; charset=utf-8"
+}
+
+// Updated returns when an item of f was last updated.
+func (f *Feed) Updated() (updated time.Time) {
+	for _, item := range f.Items {
+		if item.Updated.After(updated) {
+			updated = item.Updated
+		}
+	}
+	return
+}
+
+// Encode writes f in format to w. self is the url of the feed.
+func (f *Feed) Encode(w io.Writer, format Format, self string) error {
+	switch format {
+	case RSS:
+		return encodeRSS(w, f, self)
+	case JSON:
+		return encodeJSON(w, f, self)
+	}
+	return encodeAtom(w, f, self)
+}
+
+// Serve writes f in format as the response to r, which is conditional on
+// the ETag and the Last-Modified time of the feed. Relative links are
+// resolved against base, the absolute URL of the site. Shared caches may
+// keep the response only if shared, which must be false if base is taken
+// from the request, whose Host may be forged.
+func Serve(w http.ResponseWriter, r *http.Request, base string, shared bool, f *Feed, format Format) {
+	self, err := url.Parse(base + r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	abs := f.resolve(self)
+	var buf bytes.Buffer
+	if err := abs.Encode(&buf, format, self.String()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sum := sha1.Sum(buf.Bytes())
+	header := w.Header()
+	header.Set("Content-Type", format.ContentType())
+	header.Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	if shared {
+		header.Set("Cache-Control", "public, max-age=300")
+	} else {
+		header.Set("Cache-Control", "private, max-age=300")
+	}
+	// http.ServeContent answers If-None-Match and If-Modified-Since
+	http.ServeContent(w, r, "", f.Updated(), bytes.NewReader(buf.Bytes()))
+}
+
+// resolve returns a copy of f with the links resolved against base.
+func (f *Feed) resolve(base *url.URL) *Feed {
+	abs := func(link string) string {
+		if link == "" {
+			return ""
+		}
+		u, err := base.Parse(link)
+		if err != nil {
+			return link
+		}
+		return u.String()
+	}
+	ret := *f
+	ret.Link = abs(f.Link)
+	if f.Author != nil {
+		ret.Author = &Person{f.Author.Name, abs(f.Author.URI)}
+	}
+	ret.Items = make([]*Item, len(f.Items))
+	for i, item := range f.Items {
+		it := *item
+		it.Link = abs(item.Link)
+		it.Image = abs(item.Image)
+		it.Author.URI = abs(item.Author.URI)
+		if it.ID == "" {
+			it.ID = it.Link
+		}
+		ret.Items[i] = &it
+	}
+	return &ret
+}
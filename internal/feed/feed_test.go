@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	published := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &Feed{
+		Title:       "Go+ Community",
+		Link:        "/",
+		Description: "Articles",
+		Items: []*Item{
+			{
+				Title:      "Hello <Go+>",
+				Link:       "/p/1",
+				Summary:    "An abstract",
+				Content:    "<p>Some ]]> html</p>",
+				Image:      "https://example.com/cover.png",
+				Author:     Person{"alice", "/user/1"},
+				Categories: []string{"go", "gop"},
+				Published:  published,
+				Updated:    published.Add(time.Hour),
+			},
+			{
+				Title:     "Older",
+				Link:      "/p/2",
+				Author:    Person{"bob", "/user/2"},
+				Published: published.Add(-time.Hour),
+				Updated:   published.Add(-time.Hour),
+			},
+		},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	f := testFeed().resolve(&url.URL{Scheme: "https", Host: "example.com", Path: "/feed.xml"})
+	if f.Items[0].Link != "https://example.com/p/1" || f.Items[0].ID != f.Items[0].Link {
+		t.Fatalf("resolve() returned item %+v", f.Items[0])
+	}
+
+	var b strings.Builder
+	if err := f.Encode(&b, Atom, "https://example.com/feed.xml"); err != nil {
+		t.Fatal(err)
+	}
+	var atom atomFeed
+	if err := xml.Unmarshal([]byte(b.String()), &atom); err != nil {
+		t.Fatal(err)
+	}
+	if len(atom.Entries) != 2 || atom.Updated != "2024-01-02T04:04:05Z" {
+		t.Fatalf("Atom feed: %s", b.String())
+	}
+	if e := atom.Entries[0]; e.Title != "Hello <Go+>" || e.Content.Body != "<p>Some ]]> html</p>" || e.Author.Name != "alice" || len(e.Categories) != 2 {
+		t.Errorf("Atom entry: %+v", e)
+	}
+
+	b.Reset()
+	if err := f.Encode(&b, RSS, "https://example.com/rss.xml"); err != nil {
+		t.Fatal(err)
+	}
+	var rssFeed struct {
+		Items []struct {
+			Title   string `xml:"title"`
+			Link    string `xml:"link"`
+			Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal([]byte(b.String()), &rssFeed); err != nil {
+		t.Fatal(err)
+	}
+	if len(rssFeed.Items) != 2 || rssFeed.Items[0].Content != "<p>Some ]]> html</p>" || rssFeed.Items[0].PubDate != "Tue, 02 Jan 2024 03:04:05 +0000" {
+		t.Errorf("RSS feed: %s", b.String())
+	}
+
+	b.Reset()
+	if err := f.Encode(&b, JSON, "https://example.com/feed.json"); err != nil {
+		t.Fatal(err)
+	}
+	var jf jsonFeed
+	if err := json.Unmarshal([]byte(b.String()), &jf); err != nil {
+		t.Fatal(err)
+	}
+	if jf.FeedURL != "https://example.com/feed.json" || len(jf.Items) != 2 || jf.Items[0].Image != "https://example.com/cover.png" || jf.Items[1].ContentHTML != "" {
+		t.Errorf("JSON feed: %s", b.String())
+	}
+}
+
+func TestServe(t *testing.T) {
+	f := testFeed()
+	w := httptest.NewRecorder()
+	Serve(w, httptest.NewRequest("GET", "/feed.json", nil), "https://example.com", true, f, JSON)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Last-Modified") != "Tue, 02 Jan 2024 04:04:05 GMT" {
+		t.Fatalf("Serve() responded %d, header: %v", w.Code, w.Header())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != JSON.ContentType() {
+		t.Errorf("Serve() responded Content-Type: %s", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=300" {
+		t.Errorf("Serve() responded Cache-Control: %s", cc)
+	}
+	if !strings.Contains(w.Body.String(), `"https://example.com/p/1"`) {
+		t.Errorf("Serve() didn't resolve the links: %s", w.Body.String())
+	}
+
+	r := httptest.NewRequest("GET", "/feed.json", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	Serve(w, r, "https://example.com", true, f, JSON)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Serve() with If-None-Match responded %d, expected: 304", w.Code)
+	}
+
+	r = httptest.NewRequest("GET", "/feed.json", nil)
+	r.Header.Set("If-Modified-Since", "Tue, 02 Jan 2024 04:04:05 GMT")
+	w = httptest.NewRecorder()
+	Serve(w, r, "https://example.com", true, f, JSON)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Serve() with If-Modified-Since responded %d, expected: 304", w.Code)
+	}
+
+	// a base taken from the request isn't for shared caches
+	w = httptest.NewRecorder()
+	Serve(w, httptest.NewRequest("GET", "/feed.json", nil), "https://forged.example.com", false, f, JSON)
+	if cc := w.Header().Get("Cache-Control"); cc != "private, max-age=300" {
+		t.Errorf("Serve() of an unshared feed responded Cache-Control: %s", cc)
+	}
+
+	f.Items[1].Updated = time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	r = httptest.NewRequest("GET", "/feed.json", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	Serve(w, r, "https://example.com", true, f, JSON)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("Serve() of an updated feed responded %d, ETag: %s", w.Code, w.Header().Get("ETag"))
+	}
+}
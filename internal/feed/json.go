@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package feed
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonFeed is a feed in JSON Feed 1.1, see https://jsonfeed.org/version/1.1.
+type jsonFeed struct {
+	Version     string        `json:"version"`
+	Title       string        `json:"title"`
+	HomePageURL string        `json:"home_page_url,omitempty"`
+	FeedURL     string        `json:"feed_url"`
+	Description string        `json:"description,omitempty"`
+	Authors     []*jsonAuthor `json:"authors,omitempty"`
+	Items       []*jsonItem   `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type jsonItem struct {
+	ID            string        `json:"id"`
+	URL           string        `json:"url"`
+	Title         string        `json:"title"`
+	ContentHTML   string        `json:"content_html,omitempty"`
+	Summary       string        `json:"summary,omitempty"`
+	Image         string        `json:"image,omitempty"`
+	DatePublished string        `json:"date_published"`
+	DateModified  string        `json:"date_modified"`
+	Authors       []*jsonAuthor `json:"authors"`
+	Tags          []string      `json:"tags,omitempty"`
+}
+
+func encodeJSON(w io.Writer, f *Feed, self string) error {
+	feed := &jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     self,
+		Description: f.Description,
+		Items:       []*jsonItem{},
+	}
+	if f.Author != nil {
+		feed.Authors = []*jsonAuthor{{f.Author.Name, f.Author.URI}}
+	}
+	for _, item := range f.Items {
+		feed.Items = append(feed.Items, &jsonItem{
+			ID:            item.ID,
+			URL:           item.Link,
+			Title:         item.Title,
+			ContentHTML:   item.Content,
+			Summary:       item.Summary,
+			Image:         item.Image,
+			DatePublished: item.Published.UTC().Format(time.RFC3339),
+			DateModified:  item.Updated.UTC().Format(time.RFC3339),
+			Authors:       []*jsonAuthor{{item.Author.Name, item.Author.URI}},
+			Tags:          item.Categories,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(feed)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type rss struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	DCNS      string     `xml:"xmlns:dc,attr"`
+	MediaNS   string     `xml:"xmlns:media,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	Self          rssSelf    `xml:"atom:link"`
+	LastBuildDate string     `xml:"lastBuildDate,omitempty"`
+	Items         []*rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+type rssMedia struct {
+	URL    string `xml:"url,attr"`
+	Medium string `xml:"medium,attr"`
+}
+
+type rssItem struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	GUID        rssGUID   `xml:"guid"`
+	Description string    `xml:"description,omitempty"`
+	Content     *rssCDATA `xml:"content:encoded,omitempty"`
+	Creator     string    `xml:"dc:creator"`
+	PubDate     string    `xml:"pubDate"`
+	Categories  []string  `xml:"category"`
+	Media       *rssMedia `xml:"media:content,omitempty"`
+}
+
+type rssCDATA struct {
+	Body string `xml:",cdata"`
+}
+
+func rssTime(t time.Time) string {
+	return t.UTC().Format(time.RFC1123Z)
+}
+
+func encodeRSS(w io.Writer, f *Feed, self string) error {
+	feed := &rss{
+		Version:   "2.0",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		MediaNS:   "http://search.yahoo.com/mrss/",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+			Self:        rssSelf{self, "self", "application/rss+xml"},
+		},
+	}
+	if updated := f.Updated(); !updated.IsZero() {
+		feed.Channel.LastBuildDate = rssTime(updated)
+	}
+	for _, item := range f.Items {
+		it := &rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{item.ID == item.Link, item.ID},
+			Description: item.Summary,
+			Creator:     item.Author.Name,
+			PubDate:     rssTime(item.Published),
+			Categories:  item.Categories,
+		}
+		if item.Content != "" {
+			it.Content = &rssCDATA{item.Content}
+		}
+		if item.Image != "" {
+			it.Media = &rssMedia{item.Image, "image"}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, it)
+	}
+	return encodeXML(w, feed)
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// DirMailer drops emails as .eml files into a directory instead of sending
+// them, for development and tests.
+type DirMailer struct {
+	Dir  string
+	From string // the default sender
+
+	n atomic.Int64
+}
+
+// Send writes msg into a new file of m.Dir.
+func (m *DirMailer) Send(ctx context.Context, msg *Message) error {
+	if msg.From == "" {
+		withFrom := *msg
+		withFrom.From = m.From
+		msg = &withFrom
+	}
+	now := time.Now()
+	data, err := msg.Bytes(now)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(m.Dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%d.eml", now.UnixNano(), m.n.Add(1))
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0644)
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mail
+
+import (
+	"context"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer sends emails through an SMTP server.
+type SMTPMailer struct {
+	Addr string    // host:port of the server
+	Auth smtp.Auth // nil if the server needs no authentication
+	From string    // the default sender
+}
+
+// Send sends msg. The connection to the server isn't canceled with ctx.
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if msg.From == "" {
+		withFrom := *msg
+		withFrom.From = m.From
+		msg = &withFrom
+	}
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	data, err := msg.Bytes(time.Now())
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.Addr, m.Auth, from.Address, []string{to.Address}, data)
+}
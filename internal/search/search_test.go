@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndexTerms(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected map[string]int
+	}{
+		{"", map[string]int{}},
+		{"Go+ go, GO!", map[string]int{"go": 3}},
+		{"yap_app v1.2", map[string]int{"yap_app": 1, "v1": 1, "2": 1}},
+		{"教程", map[string]int{"教": 1, "程": 1, "教程": 1}},
+		{"Go+教程", map[string]int{"go": 1, "教": 1, "程": 1, "教程": 1}},
+		{"入门入门", map[string]int{"入": 2, "门": 2, "入门": 2, "门入": 1}},
+		{strings.Repeat("a", 70), map[string]int{strings.Repeat("a", MaxTermLen): 1}},
+	}
+	for _, tt := range tests {
+		if got := IndexTerms(tt.text); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("IndexTerms(%q) = %v, expected: %v", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestQueryTerms(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", nil},
+		{" +-* ", nil},
+		{"Go+ go", []string{"go"}},
+		{"classfile 教程", []string{"classfile", "教程"}},
+		{"快速入门", []string{"快速", "速入", "入门"}},
+		{"库", []string{"库"}},
+	}
+	for _, tt := range tests {
+		if got := QueryTerms(tt.query); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("QueryTerms(%q) = %q, expected: %q", tt.query, got, tt.expected)
+		}
+	}
+}
+
+func TestQueryTermsAreIndexed(t *testing.T) {
+	text := "Go+ 是一门面向工程、STEM 教育和数据科学的编程语言"
+	terms := IndexTerms(text)
+	for _, query := range []string{"go", "编程语言", "数据", "学", "stem 教育"} {
+		for _, term := range QueryTerms(query) {
+			if terms[term] == 0 {
+				t.Errorf("term %q of query %q is not indexed", term, query)
+			}
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		text, query string
+		size        int
+		expected    string
+	}{
+		{"hello world", "none", 20, "hello world"},
+		{"Hello  <b>World</b>", "world", 30, "Hello &lt;b&gt;<mark>World</mark>&lt;/b&gt;"},
+		{"a b c d e f g h i j k l m n", "k", 8, "…j <mark>k</mark> l m …"},
+		{"Go+ 的教程和 Go+ 的文档", "go 教程", 30, "<mark>Go</mark>+ 的<mark>教程</mark>和 <mark>Go</mark>+ 的文档"},
+		{"0123456789", "9", 4, "…678<mark>9</mark>"},
+	}
+	for _, tt := range tests {
+		if got := Snippet(tt.text, tt.query, tt.size); got != tt.expected {
+			t.Errorf("Snippet(%q, %q, %d) = %q, expected: %q", tt.text, tt.query, tt.size, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Snippet returns about size runes of text around the first match of query,
+// as html in which the matches are wrapped in <mark>. It returns the
+// beginning of text if nothing matches.
+func Snippet(text, query string, size int) string {
+	src := []rune(strings.Join(strings.Fields(text), " "))
+	lower := make([]rune, len(src))
+	for i, r := range src {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var words [][]rune
+	for _, r := range splitRuns(query) {
+		words = append(words, []rune(r.text))
+	}
+	// marked[i] is true if src[i] is in a match
+	marked := make([]bool, len(src))
+	first := -1
+	for _, w := range words {
+		for i := 0; i+len(w) <= len(lower); i++ {
+			if runesEqual(lower[i:i+len(w)], w) {
+				for j := i; j < i+len(w); j++ {
+					marked[j] = true
+				}
+				if first < 0 || i < first {
+					first = i
+				}
+			}
+		}
+	}
+
+	start := 0
+	if first > size/4 {
+		start = first - size/4
+	}
+	end := start + size
+	if end > len(src) {
+		end = len(src)
+		if start = end - size; start < 0 {
+			start = 0
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		s := html.EscapeString(string(src[i:j]))
+		if marked[i] {
+			b.WriteString("<mark>" + s + "</mark>")
+		} else {
+			b.WriteString(s)
+		}
+		i = j
+	}
+	if end < len(src) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package search tokenizes text for the full-text index of articles and
+// highlights the matches of queries.
+//
+// Words of alphabetic scripts are lowercased. Runs of CJK characters, which
+// are not separated by spaces, are indexed as single characters and
+// overlapping bigrams, and queried by bigrams, so that a query matches any
+// part of a sentence without a dictionary.
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxTermLen is the maximal length of a term in bytes. Longer words are
+// truncated.
+const MaxTermLen = 64
+
+// isCJK reports whether r is written without spaces between words.
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// run is a word or a run of CJK characters.
+type run struct {
+	text string
+	cjk  bool
+}
+
+// splitRuns splits lowercased text into words and CJK runs.
+func splitRuns(text string) (runs []run) {
+	text = strings.ToLower(text)
+	start, cjk := -1, false
+	flush := func(end int) {
+		if start >= 0 {
+			runs = append(runs, run{text[start:end], cjk})
+			start = -1
+		}
+	}
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			if start >= 0 && !cjk {
+				flush(i)
+			}
+			if start < 0 {
+				start, cjk = i, true
+			}
+		case isWordRune(r):
+			if start >= 0 && cjk {
+				flush(i)
+			}
+			if start < 0 {
+				start, cjk = i, false
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return
+}
+
+func truncate(term string) string {
+	if len(term) <= MaxTermLen {
+		return term
+	}
+	for i := MaxTermLen; i > 0; i-- {
+		if utf8.RuneStart(term[i]) {
+			return term[:i]
+		}
+	}
+	return ""
+}
+
+// IndexTerms returns the terms of text to index with their frequency.
+func IndexTerms(text string) map[string]int {
+	terms := make(map[string]int)
+	for _, r := range splitRuns(text) {
+		if !r.cjk {
+			terms[truncate(r.text)]++
+			continue
+		}
+		chars := []rune(r.text)
+		for i, c := range chars {
+			terms[string(c)]++
+			if i+1 < len(chars) {
+				terms[string(chars[i:i+2])]++
+			}
+		}
+	}
+	return terms
+}
+
+// QueryTerms returns the distinct terms of a query. An article matches the
+// query if it has all of them.
+func QueryTerms(query string) (terms []string) {
+	seen := make(map[string]bool)
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	for _, r := range splitRuns(query) {
+		if !r.cjk {
+			add(truncate(r.text))
+			continue
+		}
+		chars := []rune(r.text)
+		if len(chars) == 1 {
+			add(r.text)
+		}
+		for i := 0; i+1 < len(chars); i++ {
+			add(string(chars[i : i+2]))
+		}
+	}
+	return
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sitemap encodes sitemaps and sitemap indexes, see
+// https://www.sitemaps.org/protocol.html.
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// MaxURLs is the maximum number of URLs in a sitemap.
+const MaxURLs = 50000
+
+const xmlns = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URL is a page of a sitemap, or a sitemap of an index. Locations may be
+// relative to the site serving the sitemap.
+type URL struct {
+	Loc     string
+	LastMod time.Time // zero if unknown
+}
+
+// Sitemap lists the pages of a site.
+type Sitemap struct {
+	URLs []URL
+}
+
+// Index lists the sitemaps of a site.
+type Index struct {
+	Sitemaps []URL
+}
+
+type xmlURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type xmlURLSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	URLs    []xmlURL `xml:"url"`
+}
+
+type xmlIndex struct {
+	XMLName  xml.Name `xml:"sitemapindex"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Sitemaps []xmlURL `xml:"sitemap"`
+}
+
+// resolve returns the urls resolved against base, in the xml format.
+func resolve(urls []URL, base *url.URL) []xmlURL {
+	ret := make([]xmlURL, len(urls))
+	for i, u := range urls {
+		ret[i].Loc = u.Loc
+		if loc, err := base.Parse(u.Loc); err == nil {
+			ret[i].Loc = loc.String()
+		}
+		if !u.LastMod.IsZero() {
+			ret[i].LastMod = u.LastMod.UTC().Format(time.RFC3339)
+		}
+	}
+	return ret
+}
+
+// Encode writes s to w, resolving its locations against base.
+func (s *Sitemap) Encode(w io.Writer, base *url.URL) error {
+	return encodeXML(w, &xmlURLSet{Xmlns: xmlns, URLs: resolve(s.URLs, base)})
+}
+
+// Encode writes idx to w, resolving its locations against base.
+func (idx *Index) Encode(w io.Writer, base *url.URL) error {
+	return encodeXML(w, &xmlIndex{Xmlns: xmlns, Sitemaps: resolve(idx.Sitemaps, base)})
+}
+
+// Encoder is a Sitemap or an Index.
+type Encoder interface {
+	Encode(w io.Writer, base *url.URL) error
+}
+
+// Serve writes v as the response to r. Relative locations are resolved
+// against base, the absolute URL of the site. Shared caches may keep the
+// response only if shared, see feed.Serve.
+func Serve(w http.ResponseWriter, r *http.Request, base string, shared bool, v Encoder) {
+	self, err := url.Parse(base + r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := v.Encode(&buf, self); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if shared {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+	} else {
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+	}
+	w.Write(buf.Bytes())
+}
+
+func encodeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sitemap
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	s := &Sitemap{URLs: []URL{
+		{Loc: "/"},
+		{Loc: "/p/1", LastMod: time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))},
+		{Loc: "https://other.example.com/p/2"},
+	}}
+	w := httptest.NewRecorder()
+	Serve(w, httptest.NewRequest("GET", "/sitemap.xml?page=1", nil), "https://example.com", true, s)
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/</loc>
+  </url>
+  <url>
+    <loc>https://example.com/p/1</loc>
+    <lastmod>2024-01-01T19:04:05Z</lastmod>
+  </url>
+  <url>
+    <loc>https://other.example.com/p/2</loc>
+  </url>
+</urlset>
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("Serve(sitemap) wrote:\n%s\nexpected:\n%s", got, want)
+	}
+
+	idx := &Index{Sitemaps: []URL{{Loc: "/sitemap.xml?page=1"}, {Loc: "/sitemap.xml?page=2"}}}
+	w = httptest.NewRecorder()
+	Serve(w, httptest.NewRequest("GET", "/sitemap.xml", nil), "https://example.com", false, idx)
+	if got := w.Body.String(); !strings.Contains(got, "<sitemapindex") || !strings.Contains(got, "<loc>https://example.com/sitemap.xml?page=2</loc>") {
+		t.Errorf("Serve(index) wrote:\n%s", got)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/xml; charset=utf-8" {
+		t.Errorf("Serve() responded Content-Type: %s", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "private, max-age=3600" {
+		t.Errorf("Serve() of an unshared sitemap responded Cache-Control: %s", cc)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package markdown
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// LangGop is the canonical language name of Go+ fenced code blocks.
+const LangGop = "gop"
+
+// gopAliases are the info strings recognized as Go+ code.
+var gopAliases = map[string]bool{
+	"gop":    true,
+	"go+":    true,
+	"goplus": true,
+}
+
+// IsGop reports whether lang names a Go+ fenced code block.
+func IsGop(lang string) bool {
+	return gopAliases[strings.ToLower(lang)]
+}
+
+// gopCodeRenderer renders fenced code blocks. Go+ blocks are normalized to
+// LangGop and marked so that the front-end can highlight and run them the same
+// way cherry-markdown does in the editor.
+type gopCodeRenderer struct {
+	html.Config
+}
+
+func (r *gopCodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *gopCodeRenderer) renderFencedCodeBlock(
+	w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.FencedCodeBlock)
+	if !entering {
+		w.WriteString("</code></pre>\n")
+		return ast.WalkContinue, nil
+	}
+	lang := string(n.Language(source))
+	if IsGop(lang) {
+		w.WriteString(`<pre class="language-` + LangGop + `" data-lang="` + LangGop + `"><code class="language-` + LangGop + `">`)
+	} else if lang != "" {
+		w.WriteString(`<pre><code class="language-`)
+		r.Writer.Write(w, []byte(lang))
+		w.WriteString(`">`)
+	} else {
+		w.WriteString("<pre><code>")
+	}
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		r.Writer.RawWrite(w, line.Value(source))
+	}
+	return ast.WalkContinue, nil
+}
+
+type gopExtension struct{}
+
+// Gop is a goldmark extension that adds Go+ specific blocks.
+var Gop goldmark.Extender = gopExtension{}
+
+func (gopExtension) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&gopCodeRenderer{Config: html.NewConfig()}, 100),
+	))
+}
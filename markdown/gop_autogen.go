@@ -1,8 +1,24 @@
 package markdown
+
+import (
+	"bytes"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+var md = goldmark.New(goldmark.WithExtensions(extension.GFM, extension.Footnote, Gop), goldmark.WithParserOptions(parser.WithAutoHeadingID()))
 // Render renders a markdown text into html.
 //
-//line markdown/render.gop:20:1
-func Render(md string) (html string, err error) {
-//line markdown/render.gop:21:1
-	return md, nil
+//line markdown/render.gop:33:1
+func Render(src string) (html string, err error) {
+//line markdown/render.gop:34:1
+	var buf bytes.Buffer
+//line markdown/render.gop:35:1
+	if err = md.Convert([]byte(src), &buf); err != nil {
+//line markdown/render.gop:36:1
+		return
+	}
+//line markdown/render.gop:38:1
+	return buf.String(), nil
 }
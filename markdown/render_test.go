@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		md   string
+		want string
+	}{
+		{"heading", "# Hello", `<h1 id="hello">Hello</h1>`},
+		{"table", "| a | b |\n| - | - |\n| 1 | 2 |", "<td>1</td>"},
+		{"tasklist", "- [x] done", `<input checked="" disabled="" type="checkbox"`},
+		{"strikethrough", "~~old~~", "<del>old</del>"},
+		{"autolink", "see https://goplus.org", `<a href="https://goplus.org">https://goplus.org</a>`},
+		{"footnote", "text[^1]\n\n[^1]: note", `<div class="footnotes" role="doc-endnotes">`},
+		{"gop", "```gop\nprintln \"hi\"\n```", `<pre class="language-gop" data-lang="gop"><code class="language-gop">println &quot;hi&quot;`},
+		{"go+ alias", "```go+\necholn 1\n```", `<code class="language-gop">echoln 1`},
+		{"go", "```go\nfmt.Println()\n```", `<pre><code class="language-go">fmt.Println()`},
+		{"raw html", "<script>alert(1)</script>", "<!-- raw HTML omitted -->"},
+	}
+	for _, tt := range tests {
+		html, err := Render(tt.md)
+		if err != nil {
+			t.Fatalf("%s: Render(%q) returned error: %v", tt.name, tt.md, err)
+		}
+		if !strings.Contains(html, tt.want) {
+			t.Errorf("%s: Render(%q) = %q, expected to contain %q", tt.name, tt.md, html, tt.want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"", ""},
+		{"# Title\n\nSome *emphasis* and `code`.", "Title\nSome emphasis and code."},
+		{"[link](https://goplus.org) ![img](a.png)", "link img"},
+		{"- a\n- b", "a\nb"},
+		{"```gop\necho \"hi\"\n```", "echo \"hi\""},
+		{"line one\nline two", "line one line two"},
+		{"<div>html</div>\n\ntext", "text"},
+	}
+	for _, tt := range tests {
+		if got := PlainText(tt.src); got != tt.expected {
+			t.Errorf("PlainText(%q) = %q, expected: %q", tt.src, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package markdown
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags lists the tags kept by Sanitize together with the attributes
+// allowed on each of them. It covers the markup produced by goldmark and
+// cherry-markdown.
+var allowedTags = map[string][]string{
+	"a":          {"href", "name", "target", "rel"},
+	"abbr":       nil,
+	"audio":      {"src", "controls", "loop", "muted"},
+	"b":          nil,
+	"blockquote": {"cite"},
+	"br":         nil,
+	"caption":    nil,
+	"code":       nil,
+	"col":        {"span", "width"},
+	"colgroup":   {"span", "width"},
+	"dd":         nil,
+	"del":        nil,
+	"details":    {"open"},
+	"div":        nil,
+	"dl":         nil,
+	"dt":         nil,
+	"em":         nil,
+	"figcaption": nil,
+	"figure":     nil,
+	"font":       {"color", "size"},
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"hr":         nil,
+	"i":          nil,
+	"img":        {"src", "alt", "width", "height"},
+	"input":      {"type", "checked", "disabled"},
+	"ins":        nil,
+	"kbd":        nil,
+	"li":         nil,
+	"mark":       nil,
+	"ol":         {"start", "type"},
+	"p":          nil,
+	"pre":        nil,
+	"q":          {"cite"},
+	"s":          nil,
+	"section":    nil,
+	"small":      nil,
+	"source":     {"src", "type"},
+	"span":       nil,
+	"strike":     nil,
+	"strong":     nil,
+	"sub":        nil,
+	"summary":    nil,
+	"sup":        nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"colspan", "rowspan"},
+	"tfoot":      nil,
+	"th":         {"colspan", "rowspan", "scope"},
+	"thead":      nil,
+	"tr":         nil,
+	"u":          nil,
+	"ul":         nil,
+	"video":      {"src", "controls", "poster", "width", "height", "loop", "muted"},
+}
+
+// globalAttrs are allowed on every allowed tag.
+var globalAttrs = map[string]bool{
+	"class": true,
+	"id":    true,
+	"title": true,
+	"lang":  true,
+	"dir":   true,
+	"align": true,
+	"style": true,
+	"role":  true,
+}
+
+// droppedTags are removed together with everything inside them. The value
+// reports whether the tag has content, that is, whether an end tag follows.
+var droppedTags = map[string]bool{
+	"applet":   true,
+	"embed":    false,
+	"frame":    false,
+	"frameset": true,
+	"head":     true,
+	"iframe":   true,
+	"math":     true,
+	"noscript": true,
+	"object":   true,
+	"script":   true,
+	"select":   true,
+	"style":    true,
+	"svg":      true,
+	"template": true,
+	"textarea": true,
+	"title":    true,
+}
+
+// voidTags never have content or an end tag.
+var voidTags = map[string]bool{
+	"br":     true,
+	"col":    true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"source": true,
+}
+
+// urlAttrs hold URLs and are checked against the allowed schemes.
+var urlAttrs = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"cite":   true,
+	"poster": true,
+}
+
+var (
+	rxSafeScheme  = regexp.MustCompile(`^(?i)(https?|mailto):`)
+	rxDataImage   = regexp.MustCompile(`^(?i)data:image/(png|gif|jpe?g|webp);base64,[a-z0-9+/=]+$`)
+	rxUnsafeStyle = regexp.MustCompile(`(?i)(expression|javascript|vbscript|behavior|binding|@import|url\s*\()`)
+)
+
+// Sanitize filters an untrusted html fragment through an allowlist. Scripts,
+// event handlers, unsafe URLs and unknown tags are removed; the text of unknown
+// tags is kept.
+func Sanitize(src string) string {
+	var b strings.Builder
+	var stack []string
+	skip := 0 // depth inside a dropped tag
+	z := html.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break // io.EOF or malformed input, either way we are done
+		}
+		tok := z.Token()
+		name := strings.ToLower(tok.Data)
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if content, ok := droppedTags[name]; ok {
+				if content && tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			attrs, ok := allowedTags[name]
+			if !ok {
+				continue
+			}
+			if name == "input" && !isCheckbox(tok.Attr) {
+				continue
+			}
+			b.WriteByte('<')
+			b.WriteString(name)
+			writeAttrs(&b, name, attrs, tok.Attr)
+			b.WriteByte('>')
+			if !voidTags[name] && tt == html.StartTagToken {
+				stack = append(stack, name)
+			} else if !voidTags[name] {
+				b.WriteString("</" + name + ">")
+			}
+		case html.EndTagToken:
+			if content, ok := droppedTags[name]; ok {
+				if content && skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			// close up to the matching open tag, ignore stray end tags
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == name {
+					for j := len(stack) - 1; j >= i; j-- {
+						b.WriteString("</" + stack[j] + ">")
+					}
+					stack = stack[:i]
+					break
+				}
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+		}
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		b.WriteString("</" + stack[i] + ">")
+	}
+	return b.String()
+}
+
+func writeAttrs(b *strings.Builder, tag string, allowed []string, attrs []html.Attribute) {
+	blank := false
+	for _, attr := range attrs {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" || !isAllowedAttr(key, allowed) {
+			continue
+		}
+		val := attr.Val
+		switch {
+		case urlAttrs[key]:
+			if !isSafeURL(tag, key, val) {
+				continue
+			}
+		case key == "style":
+			if rxUnsafeStyle.MatchString(val) {
+				continue
+			}
+		case key == "target":
+			if val != "_blank" {
+				continue
+			}
+			blank = true
+		case key == "rel":
+			continue // set below for _blank links
+		}
+		b.WriteByte(' ')
+		b.WriteString(key)
+		b.WriteString(`="`)
+		b.WriteString(html.EscapeString(val))
+		b.WriteByte('"')
+	}
+	if blank {
+		b.WriteString(` rel="noopener noreferrer"`)
+	}
+}
+
+func isAllowedAttr(key string, allowed []string) bool {
+	if globalAttrs[key] || strings.HasPrefix(key, "data-") || strings.HasPrefix(key, "aria-") {
+		return true
+	}
+	for _, a := range allowed {
+		if a == key {
+			return true
+		}
+	}
+	return false
+}
+
+func isCheckbox(attrs []html.Attribute) bool {
+	for _, attr := range attrs {
+		if strings.ToLower(attr.Key) == "type" {
+			return strings.ToLower(strings.TrimSpace(attr.Val)) == "checkbox"
+		}
+	}
+	return false
+}
+
+// isSafeURL reports whether u may be used as the value of attribute key of tag.
+// Relative URLs, fragments and http(s)/mailto URLs are allowed; images may also
+// be inline base64 data.
+func isSafeURL(tag, key, u string) bool {
+	// browsers ignore control characters and spaces inside the scheme
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u)
+	i := strings.IndexAny(u, ":/?#")
+	if i < 0 || u[i] != ':' {
+		return true // relative URL
+	}
+	if rxSafeScheme.MatchString(u) {
+		return true
+	}
+	return tag == "img" && key == "src" && rxDataImage.MatchString(u)
+}
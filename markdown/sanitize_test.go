@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeXSS(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`<script>alert(1)</script>hi`, `hi`},
+		{`<SCRIPT SRC=//evil.js></SCRIPT>`, ``},
+		{`<img src=x onerror=alert(1)>`, `<img src="x">`},
+		{`<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href=" java&#x09;script:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="vbscript:msgbox(1)">x</a>`, `<a>x</a>`},
+		{`<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `<a>x</a>`},
+		{`<img src="data:image/svg+xml;base64,PHN2Zz4=">`, `<img>`},
+		{`<svg onload=alert(1)><circle/></svg>ok`, `ok`},
+		{`<iframe src="https://evil.com"></iframe>`, ``},
+		{`<object data="x"><embed src="x"></object>after`, `after`},
+		{`<style>body{display:none}</style>`, ``},
+		{`<p style="background:url(javascript:alert(1))">x</p>`, `<p>x</p>`},
+		{`<p style="width: expression(alert(1))">x</p>`, `<p>x</p>`},
+		{`<div onclick="alert(1)" onmouseover="alert(1)">x</div>`, `<div>x</div>`},
+		{`<input type="text" value="x"><input type="checkbox" checked onfocus=alert(1) autofocus>`, `<input type="checkbox" checked="">`},
+		{`<form action="/logout"><button>x</button></form>`, `x`},
+		{`<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`, ``},
+		{`<base href="https://evil.com/">`, ``},
+		{`<a href="/p/1" target="_blank" rel="opener">x</a>`, `<a href="/p/1" target="_blank" rel="noopener noreferrer">x</a>`},
+		{`<b><i>x</b>`, `<b><i>x</i></b>`},
+		{`</div>x`, `x`},
+		{`"><script>alert(1)</script>`, `&#34;&gt;`},
+		{`<p title="&quot;><script>alert(1)</script>">x</p>`, `<p title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;">x</p>`},
+		{`<!-- <script>alert(1)</script> -->x`, `x`},
+		{`<video src="https://goplus.org/v.mp4" controls onplay="alert(1)"></video>`, `<video src="https://goplus.org/v.mp4" controls=""></video>`},
+	}
+	for _, tt := range tests {
+		if got := Sanitize(tt.src); got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, expected: %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeKeepsMarkup(t *testing.T) {
+	tests := []string{
+		// goldmark
+		`<h1 id="hello">Hello</h1>`,
+		`<pre class="language-gop" data-lang="gop"><code class="language-gop">println &#34;hi&#34;</code></pre>`,
+		`<table><thead><tr><th align="left">a</th></tr></thead><tbody><tr><td>1</td></tr></tbody></table>`,
+		`<ul><li><input checked="" disabled="" type="checkbox"> done</li></ul>`,
+		`<p>text<sup id="fnref:1"><a href="#fn:1" class="footnote-ref" role="doc-noteref">1</a></sup></p>`,
+		`<p><a href="mailto:go@goplus.org">mail</a> <img src="/getMedia/1" alt="cover"></p>`,
+		// cherry-markdown
+		`<div data-type="codeBlock" data-lines="1"><pre><code class="language-go wrap">x</code></pre></div>`,
+		`<p data-sign="abc" data-lines="1"><span style="color:#ff0000">red</span> <mark>mark</mark></p>`,
+		`<img src="data:image/png;base64,iVBORw0KGgo=">`,
+	}
+	for _, src := range tests {
+		if got := Sanitize(src); got != src {
+			t.Errorf("Sanitize(%q) = %q, expected unchanged", src, got)
+		}
+	}
+}
+
+func TestSanitizeRendered(t *testing.T) {
+	html, err := Render("# Title\n\n```gop\nprintln \"<b>\"\n```\n\n[x](javascript:alert(1))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Sanitize(html)
+	if strings.Contains(got, "javascript:") {
+		t.Errorf("Sanitize(Render(...)) = %q, expected no javascript: url", got)
+	}
+	if !strings.Contains(got, `<code class="language-gop">println &#34;&lt;b&gt;&#34;`) {
+		t.Errorf("Sanitize(Render(...)) = %q, expected gop code to be kept", got)
+	}
+}